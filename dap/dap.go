@@ -0,0 +1,759 @@
+// Package dap implements a Debug Adapter Protocol server for the NES CPU, so
+// editors such as VS Code can debug 6502 assembly running on a Console.
+//
+// Breakpoints are set by source file and line, using the line information in
+// a ca65/ld65 debug info file (see symbols.DebugInfo), or by address. The CPU
+// registers are shown as variables, and the zero page, stack and RAM are
+// exposed as memory references for the client's memory views.
+//
+// https://microsoft.github.io/debug-adapter-protocol/specification
+package dap
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	"github.com/skip2/nes/nes"
+	"github.com/skip2/nes/symbols"
+)
+
+// The only thread.
+const threadID = 1
+
+// Variable references.
+const (
+	registersReference = 1
+	memoryReference    = 2
+)
+
+// Server serves the Debug Adapter Protocol for a Console.
+type Server struct {
+	console      *nes.Console
+	debugger     *nes.Debugger
+	disassembler *nes.Disassembler
+
+	// Source line information, may be nil.
+	info *symbols.DebugInfo
+
+	conn *connection
+
+	stopOnEntry bool
+
+	// Debugger breakpoint IDs for each source file path, and for instruction
+	// breakpoints.
+	sourceBreakpoints      map[string][]int
+	instructionBreakpoints []int
+
+	// Execution in progress in another goroutine, if running. The result is
+	// sent to results.
+	running   bool
+	operation func() (*nes.Stop, error)
+	results   chan result
+}
+
+// The result of a debugger run function.
+type result struct {
+	stop *nes.Stop
+	err  error
+}
+
+// NewServer returns a Server for console. A Debugger is attached to console.
+//
+// info provides source line information, and labels name addresses. Either
+// may be nil. If labels is nil, the labels in info are used. info is made
+// bank-aware using console's cartridge.
+func NewServer(console *nes.Console, info *symbols.DebugInfo, labels nes.Labeler) *Server {
+	if info != nil {
+		info.PRGOffset = console.Cart.PRGOffset
+		info.Labels.PRGOffset = console.Cart.PRGOffset
+
+		if labels == nil {
+			labels = info.Labels
+		}
+	}
+
+	return &Server{
+		console:           console,
+		debugger:          nes.NewDebugger(console),
+		disassembler:      nes.NewDisassembler(console.Bus.Peek, labels),
+		info:              info,
+		sourceBreakpoints: make(map[string][]int),
+		results:           make(chan result, 1),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr, and serves one client
+// connection at a time.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, and serves them one at a time.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		err = s.ServeConn(conn)
+		conn.Close()
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+}
+
+// ServeConn serves a single client connection, until the client disconnects
+// or the connection is closed. Execution is stopped when ServeConn returns.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	s.conn = &connection{writer: conn}
+
+	requests := make(chan *request)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		reader := bufio.NewReader(conn)
+
+		for {
+			req, err := readRequest(reader)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	defer s.interrupt()
+
+	for {
+		select {
+		case req := <-requests:
+			if s.handle(req) {
+				return s.conn.err
+			}
+		case r := <-s.results:
+			s.stopped(r)
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+// Starts running operation in another goroutine.
+func (s *Server) resume(operation func() (*nes.Stop, error)) {
+	s.running = true
+	s.operation = operation
+
+	go func() {
+		stop, err := operation()
+		s.results <- result{stop, err}
+	}()
+}
+
+// Stops a running operation. Returns true if it was paused, and so should be
+// resumed afterwards. If it stopped by itself, the client is told.
+func (s *Server) interrupt() bool {
+	if !s.running {
+		return false
+	}
+
+	s.debugger.Pause()
+	r := <-s.results
+
+	if r.err == nil && r.stop.Reason == nes.StopPause {
+		s.running = false
+		return true
+	}
+
+	s.stopped(r)
+	return false
+}
+
+// Sends the stopped event for the result of a run.
+func (s *Server) stopped(r result) {
+	s.running = false
+
+	// A pause requested as the run stopped by itself would otherwise stop the
+	// next run.
+	s.debugger.CancelPause()
+
+	body := map[string]interface{}{
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	}
+
+	if r.err != nil {
+		body["reason"] = "exception"
+		body["description"] = "CPU error"
+		body["text"] = r.err.Error()
+		s.conn.event("stopped", body)
+		return
+	}
+
+	switch r.stop.Reason {
+	case nes.StopBreakpoint:
+		body["reason"] = "breakpoint"
+		body["hitBreakpointIds"] = []int{r.stop.Breakpoint.ID}
+	case nes.StopWatchpoint:
+		body["reason"] = "data breakpoint"
+		body["description"] = r.stop.String()
+	case nes.StopPause:
+		body["reason"] = "pause"
+	default:
+		body["reason"] = "step"
+	}
+
+	s.conn.event("stopped", body)
+}
+
+// Handles a request. Returns true if the client disconnected.
+func (s *Server) handle(req *request) bool {
+	var body interface{}
+	var err error
+
+	switch req.Command {
+	case "initialize":
+		body = map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsInstructionBreakpoints":   true,
+			"supportsSteppingGranularity":      true,
+			"supportsSetVariable":              true,
+			"supportsReadMemoryRequest":        true,
+			"supportsWriteMemoryRequest":       true,
+		}
+
+		s.conn.respond(req, body, nil)
+		s.conn.event("initialized", nil)
+		return false
+	case "launch", "attach":
+		var args struct {
+			StopOnEntry bool `json:"stopOnEntry"`
+		}
+
+		err = decode(req, &args)
+		s.stopOnEntry = args.StopOnEntry
+	case "configurationDone":
+		s.conn.respond(req, nil, nil)
+
+		if s.stopOnEntry {
+			s.conn.event("stopped", map[string]interface{}{
+				"reason":            "entry",
+				"threadId":          threadID,
+				"allThreadsStopped": true,
+			})
+		} else {
+			s.resume(s.debugger.Continue)
+		}
+		return false
+	case "disconnect", "terminate":
+		s.interrupt()
+		s.conn.respond(req, nil, nil)
+		return true
+	case "threads":
+		body = map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "CPU"}},
+		}
+	case "pause":
+		if s.running {
+			s.debugger.Pause()
+		}
+	case "setBreakpoints", "setInstructionBreakpoints":
+		paused := s.interrupt()
+
+		if req.Command == "setBreakpoints" {
+			body, err = s.setBreakpoints(req)
+		} else {
+			body, err = s.setInstructionBreakpoints(req)
+		}
+
+		if paused {
+			s.resume(s.operation)
+		}
+	default:
+		if s.running {
+			err = errors.New("the program is running")
+			break
+		}
+
+		body, err = s.handleStopped(req)
+	}
+
+	s.conn.respond(req, body, err)
+
+	return false
+}
+
+// Handles requests which are only valid while stopped.
+func (s *Server) handleStopped(req *request) (interface{}, error) {
+	var cpu *nes.CPU = s.console.CPU
+
+	switch req.Command {
+	case "continue":
+		s.resume(s.debugger.Continue)
+		return map[string]interface{}{"allThreadsContinued": true}, nil
+	case "next", "stepIn", "stepOut":
+		var args struct {
+			Granularity string `json:"granularity"`
+		}
+
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+
+		var step func() (*nes.Stop, error) = s.debugger.StepInto
+		switch {
+		case req.Command == "stepOut":
+			step = s.debugger.StepOut
+		case req.Command == "next":
+			step = s.debugger.StepOver
+		}
+
+		if args.Granularity == "instruction" || req.Command == "stepOut" {
+			s.resume(step)
+		} else {
+			s.resume(func() (*nes.Stop, error) {
+				return s.stepLine(step)
+			})
+		}
+		return nil, nil
+	case "stackTrace":
+		return map[string]interface{}{
+			"stackFrames": []*stackFrame{s.frame()},
+			"totalFrames": 1,
+		}, nil
+	case "scopes":
+		return map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Registers", "variablesReference": registersReference, "expensive": false},
+				{"name": "Memory", "variablesReference": memoryReference, "expensive": false},
+			},
+		}, nil
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+
+		var variables []*variable
+		switch args.VariablesReference {
+		case registersReference:
+			variables = []*variable{
+				{Name: "A", Value: fmt.Sprintf("$%02X", cpu.A)},
+				{Name: "X", Value: fmt.Sprintf("$%02X", cpu.X)},
+				{Name: "Y", Value: fmt.Sprintf("$%02X", cpu.Y)},
+				{Name: "SP", Value: fmt.Sprintf("$%02X", cpu.SP), MemoryReference: fmt.Sprintf("0x%04X", 0x100+uint16(cpu.SP))},
+				{Name: "PC", Value: fmt.Sprintf("$%04X", cpu.PC), MemoryReference: fmt.Sprintf("0x%04X", cpu.PC)},
+				{Name: "P", Value: fmt.Sprintf("$%02X", cpu.P())},
+			}
+		case memoryReference:
+			variables = []*variable{
+				{Name: "Zero Page", Value: "$0000-$00FF", MemoryReference: "0x0000"},
+				{Name: "Stack", Value: "$0100-$01FF", MemoryReference: "0x0100"},
+				{Name: "RAM", Value: "$0000-$07FF", MemoryReference: "0x0000"},
+			}
+		default:
+			return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+		}
+
+		return map[string]interface{}{"variables": variables}, nil
+	case "setVariable":
+		return s.setVariable(req)
+	case "readMemory":
+		return s.readMemory(req)
+	case "writeMemory":
+		return s.writeMemory(req)
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+// Runs step repeatedly until execution reaches a different source line, or a
+// line without line information.
+func (s *Server) stepLine(step func() (*nes.Stop, error)) (*nes.Stop, error) {
+	var cpu *nes.CPU = s.console.CPU
+
+	start, ok := s.lineAt(cpu.PC)
+
+	for {
+		stop, err := step()
+		if err != nil || stop.Reason != nes.StopStep || !ok {
+			return stop, err
+		}
+
+		line, ok := s.lineAt(cpu.PC)
+		if !ok || line.File != start.File || line.Line != start.Line {
+			return stop, nil
+		}
+	}
+}
+
+// Returns the source line for the code at address, if known.
+func (s *Server) lineAt(address uint16) (symbols.Line, bool) {
+	if s.info == nil {
+		return symbols.Line{}, false
+	}
+
+	return s.info.LineAt(address)
+}
+
+type source struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type stackFrame struct {
+	ID                          int     `json:"id"`
+	Name                        string  `json:"name"`
+	Source                      *source `json:"source,omitempty"`
+	Line                        int     `json:"line"`
+	Column                      int     `json:"column"`
+	InstructionPointerReference string  `json:"instructionPointerReference"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+// Returns the stack frame for the current PC.
+func (s *Server) frame() *stackFrame {
+	var pc uint16 = s.console.CPU.PC
+	var instruction *nes.DisassembledInstruction = s.disassembler.Disassemble(pc)
+
+	f := &stackFrame{
+		ID:                          1,
+		Name:                        fmt.Sprintf("$%04X: %s", pc, instruction.Assembly()),
+		InstructionPointerReference: fmt.Sprintf("0x%04X", pc),
+	}
+
+	if instruction.Label != "" {
+		f.Name = instruction.Label + ": " + instruction.Assembly()
+	}
+
+	if line, ok := s.lineAt(pc); ok {
+		f.Source = &source{Name: filepath.Base(line.File), Path: line.File}
+		f.Line = line.Line
+		f.Column = 1
+	}
+
+	return f
+}
+
+type breakpoint struct {
+	ID                   int     `json:"id,omitempty"`
+	Verified             bool    `json:"verified"`
+	Message              string  `json:"message,omitempty"`
+	Source               *source `json:"source,omitempty"`
+	Line                 int     `json:"line,omitempty"`
+	InstructionReference string  `json:"instructionReference,omitempty"`
+}
+
+// Replaces the breakpoints in a source file.
+func (s *Server) setBreakpoints(req *request) (interface{}, error) {
+	var args struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	var path string = args.Source.Path
+	for _, id := range s.sourceBreakpoints[path] {
+		s.debugger.Delete(id)
+	}
+	delete(s.sourceBreakpoints, path)
+
+	var result []*breakpoint
+	for _, b := range args.Breakpoints {
+		r := &breakpoint{Source: &args.Source, Line: b.Line}
+		result = append(result, r)
+
+		if s.info == nil {
+			r.Message = "no debug info loaded"
+			continue
+		}
+
+		lines := s.info.FindLine(path, b.Line)
+		if lines == nil {
+			r.Message = "no code at or after this line"
+			continue
+		}
+
+		for _, line := range lines {
+			added, err := s.addBreakpoint(line.Address, b.Condition)
+			if err != nil {
+				r.Message = err.Error()
+				break
+			}
+
+			// Only break while the line's bank is mapped.
+			if line.PRGOffset != -1 {
+				var line symbols.Line = line
+				var condition func(c *nes.CPU) bool = added.Condition
+
+				added.Condition = func(c *nes.CPU) bool {
+					return s.info.Mapped(line) && (condition == nil || condition(c))
+				}
+			}
+
+			if r.ID == 0 {
+				r.ID = added.ID
+			}
+			s.sourceBreakpoints[path] = append(s.sourceBreakpoints[path], added.ID)
+		}
+
+		r.Verified = r.Message == ""
+		r.Line = lines[0].Line
+		r.InstructionReference = fmt.Sprintf("0x%04X", lines[0].Address)
+	}
+
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// Replaces the breakpoints set by address.
+func (s *Server) setInstructionBreakpoints(req *request) (interface{}, error) {
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+			Condition            string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	for _, id := range s.instructionBreakpoints {
+		s.debugger.Delete(id)
+	}
+	s.instructionBreakpoints = nil
+
+	var result []*breakpoint
+	for _, b := range args.Breakpoints {
+		r := &breakpoint{}
+		result = append(result, r)
+
+		address, err := parseMemoryReference(b.InstructionReference, b.Offset)
+		if err == nil {
+			var added *nes.Breakpoint
+			added, err = s.addBreakpoint(address, b.Condition)
+			if err == nil {
+				r.ID = added.ID
+				r.Verified = true
+				r.InstructionReference = fmt.Sprintf("0x%04X", address)
+				s.instructionBreakpoints = append(s.instructionBreakpoints, added.ID)
+			}
+		}
+
+		if err != nil {
+			r.Message = err.Error()
+		}
+	}
+
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// Adds a breakpoint at address, with an optional condition, e.g. "A == $10".
+func (s *Server) addBreakpoint(address uint16, conditionText string) (*nes.Breakpoint, error) {
+	var condition func(c *nes.CPU) bool
+	if conditionText != "" {
+		var err error
+		condition, err = nes.ParseCondition(conditionText)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b := s.debugger.AddBreakpoint(address, condition)
+	if conditionText != "" {
+		b.ConditionText = "if " + conditionText
+	}
+
+	return b, nil
+}
+
+// Sets a register.
+func (s *Server) setVariable(req *request) (interface{}, error) {
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	if args.VariablesReference != registersReference {
+		return nil, errors.New("only registers may be set")
+	}
+
+	value, err := nes.ParseNumber(args.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cpu *nes.CPU = s.console.CPU
+	var register *byte
+
+	switch args.Name {
+	case "A":
+		register = &cpu.A
+	case "X":
+		register = &cpu.X
+	case "Y":
+		register = &cpu.Y
+	case "SP":
+		register = &cpu.SP
+	case "PC":
+		cpu.PC = value
+		return map[string]interface{}{"value": fmt.Sprintf("$%04X", value)}, nil
+	case "P":
+		if value > 0xFF {
+			return nil, fmt.Errorf("%s is out of range", args.Value)
+		}
+
+		cpu.SetP(byte(value))
+		return map[string]interface{}{"value": fmt.Sprintf("$%02X", cpu.P())}, nil
+	default:
+		return nil, fmt.Errorf("unknown register %q", args.Name)
+	}
+
+	if value > 0xFF {
+		return nil, fmt.Errorf("%s is out of range", args.Value)
+	}
+
+	*register = byte(value)
+
+	return map[string]interface{}{"value": fmt.Sprintf("$%02X", value)}, nil
+}
+
+// Reads CPU memory, without side effects.
+func (s *Server) readMemory(req *request) (interface{}, error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	address, err := parseMemoryReference(args.MemoryReference, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Count < 0 {
+		return nil, fmt.Errorf("invalid count %d", args.Count)
+	}
+
+	var count int = args.Count
+	if int(address)+count > 0x10000 {
+		count = 0x10000 - int(address)
+	}
+
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = s.console.Bus.Peek(address + uint16(i))
+	}
+
+	return map[string]interface{}{
+		"address":         fmt.Sprintf("0x%04X", address),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": args.Count - count,
+	}, nil
+}
+
+// Writes CPU memory, on the CPU bus.
+func (s *Server) writeMemory(req *request) (interface{}, error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Data            string `json:"data"`
+	}
+
+	if err := decode(req, &args); err != nil {
+		return nil, err
+	}
+
+	address, err := parseMemoryReference(args.MemoryReference, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if int(address)+len(data) > 0x10000 {
+		data = data[:0x10000-int(address)]
+	}
+
+	for i, value := range data {
+		s.console.Bus.Write(address+uint16(i), value)
+	}
+
+	return map[string]interface{}{"bytesWritten": len(data)}, nil
+}
+
+// Parses a memory reference such as "0x8000", plus offset, into an address.
+func parseMemoryReference(reference string, offset int) (uint16, error) {
+	base, err := strconv.ParseInt(reference, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory reference %q", reference)
+	}
+
+	address := base + int64(offset)
+	if address < 0 || address > 0xFFFF {
+		return 0, fmt.Errorf("address %X is out of range", address)
+	}
+
+	return uint16(address), nil
+}
+
+// Decodes the arguments of req into args.
+func decode(req *request, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(req.Arguments, args)
+}
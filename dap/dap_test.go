@@ -0,0 +1,255 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/skip2/nes/nes"
+	"github.com/skip2/nes/symbols"
+)
+
+// LDX #$00; loop: INX; STX $10; JMP loop
+var testProgram = []byte{0xA2, 0x00, 0xE8, 0x86, 0x10, 0x4C, 0x02, 0x80}
+
+// Debug info for testProgram, on lines 3-6 of src/main.s.
+const testDebugInfo = `version	major=2,minor=0
+file	id=0,name="src/main.s",size=120,mtime=0x5F5E1000,mod=0
+line	id=0,file=0,line=3,span=0
+line	id=1,file=0,line=4,span=1
+line	id=2,file=0,line=5,span=2
+line	id=3,file=0,line=6,span=3
+seg	id=0,name="CODE",start=0x008000,size=0x0008,addrsize=absolute,type=ro,oname="main.nes",ooffs=16
+span	id=0,seg=0,start=0,size=2
+span	id=1,seg=0,start=2,size=1
+span	id=2,seg=0,start=3,size=2
+span	id=3,seg=0,start=5,size=3
+sym	id=0,name="loop",addrsize=absolute,scope=0,def=1,val=0x8002,seg=0,type=lab
+`
+
+// A DAP client connected to a Server over TCP.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+// A response or event received by a testClient.
+type testMessage struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newTestClient(t *testing.T) (*testClient, *nes.Console) {
+	cart := nes.NewCartridge(2, 1, 1)
+	copy(cart.PRG[0], testProgram)
+	cart.PRG[1][0x3FFC] = 0x00
+	cart.PRG[1][0x3FFD] = 0x80
+	cart.Mapper = nes.NewMapper0(cart)
+
+	console := nes.NewConsole(cart)
+
+	info, err := symbols.ReadDebugInfo(strings.NewReader(testDebugInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %s\n", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go NewServer(console, info, nil).Serve(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %s\n", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}, console
+}
+
+// Sends a request, and returns the body of the successful response. Events
+// received before the response are discarded.
+func (c *testClient) request(command string, arguments interface{}) json.RawMessage {
+	c.t.Helper()
+
+	c.send(command, arguments)
+
+	m := c.response()
+	if !m.Success {
+		c.t.Fatalf("%s failed: %s\n", command, m.Message)
+	}
+
+	return m.Body
+}
+
+// Sends a request which is expected to fail.
+func (c *testClient) requestFailure(command string, arguments interface{}) {
+	c.t.Helper()
+
+	c.send(command, arguments)
+
+	if m := c.response(); m.Success {
+		c.t.Fatalf("%s succeeded, expected failure\n", command)
+	}
+}
+
+// Sends a request.
+func (c *testClient) send(command string, arguments interface{}) {
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": arguments,
+	})
+	fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// Waits for the response to the last request. Events received before it are
+// discarded.
+func (c *testClient) response() *testMessage {
+	c.t.Helper()
+
+	for {
+		m := c.read()
+		if m.Type == "response" && m.RequestSeq == c.seq {
+			return m
+		}
+	}
+}
+
+// Waits for an event, and returns its body.
+func (c *testClient) event(name string) json.RawMessage {
+	c.t.Helper()
+
+	for {
+		m := c.read()
+		if m.Type == "event" && m.Event == name {
+			return m.Body
+		}
+	}
+}
+
+func (c *testClient) read() *testMessage {
+	c.t.Helper()
+
+	data, err := readMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("reading message: %s\n", err)
+	}
+
+	var m testMessage
+	if err = json.Unmarshal(data, &m); err != nil {
+		c.t.Fatalf("decoding message: %s\n", err)
+	}
+
+	return &m
+}
+
+// Waits for a stopped event, and checks its reason.
+func (c *testClient) expectStopped(reason string) {
+	c.t.Helper()
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(c.event("stopped"), &body)
+
+	if body.Reason != reason {
+		c.t.Fatalf("stopped with reason %q, expected %q\n", body.Reason, reason)
+	}
+}
+
+// Checks the source line of the current stack frame.
+func (c *testClient) expectLine(line int) {
+	c.t.Helper()
+
+	var body struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	json.Unmarshal(c.request("stackTrace", map[string]interface{}{"threadId": threadID}), &body)
+
+	if len(body.StackFrames) != 1 || body.StackFrames[0].Line != line ||
+		body.StackFrames[0].Source == nil || body.StackFrames[0].Source.Path != "src/main.s" {
+		c.t.Fatalf("stackTrace=%+v, expected src/main.s line %d\n", body.StackFrames, line)
+	}
+}
+
+func TestServer(t *testing.T) {
+	client, console := newTestClient(t)
+
+	client.request("initialize", map[string]interface{}{"adapterID": "nes"})
+	client.event("initialized")
+	client.request("launch", map[string]interface{}{"stopOnEntry": true})
+
+	var breakpoints struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	json.Unmarshal(client.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "/home/user/game/src/main.s"},
+		"breakpoints": []map[string]interface{}{{"line": 5}, {"line": 100}},
+	}), &breakpoints)
+
+	if len(breakpoints.Breakpoints) != 2 || !breakpoints.Breakpoints[0].Verified ||
+		breakpoints.Breakpoints[0].Line != 5 || breakpoints.Breakpoints[1].Verified {
+		t.Fatalf("setBreakpoints=%+v, expected line 5 verified, line 100 not\n", breakpoints.Breakpoints)
+	}
+
+	client.request("configurationDone", nil)
+	client.expectStopped("entry")
+	client.expectLine(3)
+
+	client.request("continue", map[string]interface{}{"threadId": threadID})
+	client.expectStopped("breakpoint")
+	client.expectLine(5)
+
+	var variables struct {
+		Variables []variable `json:"variables"`
+	}
+	json.Unmarshal(client.request("variables", map[string]interface{}{"variablesReference": registersReference}), &variables)
+	if len(variables.Variables) != 6 || variables.Variables[1].Name != "X" || variables.Variables[1].Value != "$01" {
+		t.Fatalf("variables=%+v, expected X=$01\n", variables.Variables)
+	}
+
+	client.request("next", map[string]interface{}{"threadId": threadID})
+	client.expectStopped("step")
+	client.expectLine(6)
+
+	var memory struct {
+		Address string `json:"address"`
+		Data    string `json:"data"`
+	}
+	json.Unmarshal(client.request("readMemory", map[string]interface{}{"memoryReference": "0x0000", "offset": 0x10, "count": 1}), &memory)
+	if memory.Address != "0x0010" || memory.Data != "AQ==" {
+		t.Fatalf("readMemory=%+v, expected 0x0010 AQ==\n", memory)
+	}
+
+	client.requestFailure("readMemory", map[string]interface{}{"memoryReference": "0x0000", "count": -1})
+
+	client.request("setVariable", map[string]interface{}{"variablesReference": registersReference, "name": "A", "value": "$42"})
+	if console.CPU.A != 0x42 {
+		t.Fatalf("setVariable: A=%02X, expected 42\n", console.CPU.A)
+	}
+
+	// Clear the breakpoints, run freely, and pause.
+	client.request("setBreakpoints", map[string]interface{}{
+		"source": map[string]interface{}{"path": "/home/user/game/src/main.s"},
+	})
+	client.request("continue", map[string]interface{}{"threadId": threadID})
+	client.request("pause", map[string]interface{}{"threadId": threadID})
+	client.expectStopped("pause")
+
+	client.request("disconnect", nil)
+}
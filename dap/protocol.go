@@ -0,0 +1,121 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A request from the client.
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// Reads a request, framed by a Content-Length header.
+func readRequest(r *bufio.Reader) (*request, error) {
+	body, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var req request
+	if err = json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("dap: %s", err)
+	}
+
+	return &req, nil
+}
+
+// Reads the body of a message, framed by a Content-Length header.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("dap: invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// A connection writes responses and events. It may be used from multiple
+// goroutines.
+type connection struct {
+	writer io.Writer
+
+	mutex sync.Mutex
+	seq   int
+	err   error
+}
+
+// Sends a response to req. If err is non-nil the request failed.
+func (c *connection) respond(req *request, body interface{}, err error) {
+	r := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		r.Message = err.Error()
+	}
+
+	c.send(func(seq int) interface{} {
+		r.Seq = seq
+		return r
+	})
+}
+
+// Sends an event.
+func (c *connection) event(name string, body interface{}) {
+	c.send(func(seq int) interface{} {
+		return &event{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// Sends the message returned by message, which is passed the next sequence
+// number.
+func (c *connection) send(message func(seq int) interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	c.seq++
+	data, err := json.Marshal(message(c.seq))
+	if err == nil {
+		_, err = fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+
+	c.err = err
+}
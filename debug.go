@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/nes/nes"
+)
+
+const debugHelp = `Commands:
+  s, step                  Execute one instruction.
+  n, next                  Execute one instruction, stepping over JSR.
+  o, out                   Run until the current subroutine returns.
+  c, continue              Run until a breakpoint or watchpoint is hit.
+  scanline N               Run to the start of scanline N.
+  frame N                  Run until the PPU frame counter reaches N.
+  b, break ADDR [if COND]  Set a breakpoint, e.g. "b $C000 if A == $10".
+  w, watch [ppu] rwx START [END]
+                           Set a watchpoint, e.g. "w rw $0300 $03FF".
+  d, delete ID             Delete a breakpoint or watchpoint.
+  l, list                  List breakpoints and watchpoints.
+  r, regs                  Show the CPU and PPU registers.
+  u, disasm [ADDR] [N]     Disassemble N instructions (default: 10 at PC).
+  m, mem [ppu] ADDR [LEN]  Show memory, without side effects.
+  q, quit                  Exit.
+
+Ctrl+C pauses a running command.
+`
+
+// runDebugger runs an interactive debugger REPL for console, reading commands
+// from in and writing output to out. labels are used in disassembly, and may
+// be nil.
+func runDebugger(console *nes.Console, labels nes.Labeler, in io.Reader, out io.Writer) error {
+	var debugger *nes.Debugger = nes.NewDebugger(console)
+	var disassembler *nes.Disassembler = nes.NewDisassembler(console.Bus.Peek, labels)
+	var scanner *bufio.Scanner = bufio.NewScanner(in)
+
+	fmt.Fprint(out, console.CPU)
+	fmt.Fprintln(out, disassembler.Disassemble(console.CPU.PC))
+
+	for {
+		fmt.Fprint(out, "(nes) ")
+
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+
+		var stop *nes.Stop
+		var err error
+
+		switch args[0] {
+		case "s", "step":
+			stop, err = interruptible(debugger, debugger.StepInto)
+		case "n", "next":
+			stop, err = interruptible(debugger, debugger.StepOver)
+		case "o", "out":
+			stop, err = interruptible(debugger, debugger.StepOut)
+		case "c", "continue":
+			stop, err = interruptible(debugger, debugger.Continue)
+		case "scanline", "frame":
+			if len(args) != 2 {
+				err = fmt.Errorf("usage: %s N", args[0])
+				break
+			}
+
+			var n uint64
+			n, err = strconv.ParseUint(args[1], 10, 64)
+			if err != nil {
+				break
+			}
+
+			stop, err = interruptible(debugger, func() (*nes.Stop, error) {
+				if args[0] == "scanline" {
+					return debugger.RunToScanline(int(n))
+				}
+
+				return debugger.RunToFrame(n)
+			})
+		case "b", "break":
+			err = debugBreak(debugger, args[1:], out)
+		case "w", "watch":
+			err = debugWatch(debugger, args[1:], out)
+		case "d", "delete":
+			var id int
+			if len(args) == 2 {
+				id, err = strconv.Atoi(args[1])
+			}
+
+			if err != nil || !debugger.Delete(id) {
+				err = fmt.Errorf("no breakpoint or watchpoint %q", strings.Join(args[1:], " "))
+			}
+		case "l", "list":
+			for _, b := range debugger.Breakpoints() {
+				fmt.Fprintf(out, "%d: break $%04X %s\n", b.ID, b.Address, b.ConditionText)
+			}
+			for _, w := range debugger.Watchpoints() {
+				fmt.Fprintf(out, "%d: watch %s %s $%04X-$%04X\n", w.ID, w.Space, w.Access, w.Start, w.End)
+			}
+		case "r", "regs":
+			fmt.Fprint(out, console.CPU)
+			fmt.Fprintln(out, console.PPU)
+		case "m", "mem":
+			err = debugMem(console, args[1:], out)
+		case "u", "disasm":
+			err = debugDisasm(console, disassembler, args[1:], out)
+		case "q", "quit":
+			return nil
+		default:
+			fmt.Fprint(out, debugHelp)
+		}
+
+		if err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+		} else if stop != nil {
+			fmt.Fprintln(out, stop)
+			fmt.Fprint(out, console.CPU)
+			fmt.Fprintln(out, disassembler.Disassemble(console.CPU.PC))
+		}
+	}
+}
+
+// Runs run, pausing the debugger if SIGINT (Ctrl+C) is received meanwhile.
+func interruptible(debugger *nes.Debugger, run func() (*nes.Stop, error)) (*nes.Stop, error) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		select {
+		case <-interrupts:
+			debugger.Pause()
+		case <-done:
+		}
+	}()
+
+	stop, err := run()
+
+	signal.Stop(interrupts)
+	close(done)
+	<-finished
+
+	// In case the interrupt came as the run stopped by itself.
+	debugger.CancelPause()
+
+	return stop, err
+}
+
+func debugBreak(debugger *nes.Debugger, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: break ADDR [if COND]")
+	}
+
+	address, err := nes.ParseNumber(args[0])
+	if err != nil {
+		return err
+	}
+
+	var condition func(c *nes.CPU) bool
+	var conditionText string
+	if len(args) > 2 && args[1] == "if" {
+		conditionText = strings.Join(args[2:], " ")
+		condition, err = nes.ParseCondition(conditionText)
+		if err != nil {
+			return err
+		}
+	}
+
+	b := debugger.AddBreakpoint(address, condition)
+	if conditionText != "" {
+		b.ConditionText = "if " + conditionText
+	}
+
+	fmt.Fprintf(out, "breakpoint %d @ $%04X\n", b.ID, b.Address)
+
+	return nil
+}
+
+func debugWatch(debugger *nes.Debugger, args []string, out io.Writer) error {
+	var space nes.AddressSpace = nes.CPUAddressSpace
+	if len(args) > 0 && args[0] == "ppu" {
+		space = nes.PPUAddressSpace
+		args = args[1:]
+	}
+
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: watch [ppu] rwx START [END]")
+	}
+
+	var access nes.AccessType
+	for _, r := range args[0] {
+		switch r {
+		case 'r':
+			access |= nes.AccessRead
+		case 'w':
+			access |= nes.AccessWrite
+		case 'x':
+			access |= nes.AccessExecute
+		default:
+			return fmt.Errorf("invalid access type %q", args[0])
+		}
+	}
+
+	start, err := nes.ParseNumber(args[1])
+	if err != nil {
+		return err
+	}
+
+	var end uint16 = start
+	if len(args) == 3 {
+		end, err = nes.ParseNumber(args[2])
+		if err != nil {
+			return err
+		}
+	}
+
+	w := debugger.AddWatchpoint(space, start, end, access)
+	fmt.Fprintf(out, "watchpoint %d @ %s $%04X-$%04X\n", w.ID, w.Space, w.Start, w.End)
+
+	return nil
+}
+
+func debugMem(console *nes.Console, args []string, out io.Writer) error {
+	var peek func(address uint16) byte = console.Bus.Peek
+	if len(args) > 0 && args[0] == "ppu" {
+		peek = console.PPU.Peek
+		args = args[1:]
+	}
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: mem [ppu] ADDR [LEN]")
+	}
+
+	address, err := nes.ParseNumber(args[0])
+	if err != nil {
+		return err
+	}
+
+	var length uint16 = 16
+	if len(args) == 2 {
+		length, err = nes.ParseNumber(args[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < int(length); i += 16 {
+		fmt.Fprintf(out, "%04X ", address+uint16(i))
+
+		for j := i; j < i+16 && j < int(length); j++ {
+			fmt.Fprintf(out, " %02X", peek(address+uint16(j)))
+		}
+
+		fmt.Fprintln(out)
+	}
+
+	return nil
+}
+
+func debugDisasm(console *nes.Console, disassembler *nes.Disassembler, args []string, out io.Writer) error {
+	var address uint16 = console.CPU.PC
+	var count uint16 = 10
+	var err error
+
+	if len(args) > 2 {
+		return fmt.Errorf("usage: disasm [ADDR] [N]")
+	}
+
+	if len(args) >= 1 {
+		address, err = nes.ParseNumber(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(args) == 2 {
+		count, err = nes.ParseNumber(args[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := uint16(0); i < count; i++ {
+		instruction := disassembler.Disassemble(address)
+		if instruction.Label != "" {
+			fmt.Fprintf(out, "%s:\n", instruction.Label)
+		}
+
+		fmt.Fprintln(out, instruction)
+		address += uint16(len(instruction.Bytes))
+	}
+
+	return nil
+}
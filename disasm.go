@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skip2/nes/nes"
+	"github.com/skip2/nes/symbols"
+)
+
+// runDisasm implements the "disasm" command, which disassembles the PRG banks
+// of a ROM file.
+func runDisasm(args []string) error {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	bank := flags.Int("bank", -1, "16k PRG bank to disassemble (default all)")
+	origin := flags.String("origin", "", "CPU address of the bank (default $C000 for the last bank, else $8000)")
+	labelsFilename := flags.String("labels", "", "comma separated label files (ld65 -Ln or --dbgfile .dbg, FCEUX .nl or Mesen .mlb)")
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: nes disasm [options] FILENAME.ROM")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	cart, err := nes.LoadCartridge(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var labels nes.Labeler
+	var table *symbols.Table
+
+	if *labelsFilename != "" {
+		table, err = symbols.Load(strings.Split(*labelsFilename, ",")...)
+		if err != nil {
+			return err
+		}
+		labels = table
+	}
+
+	for i := range cart.PRG {
+		if *bank != -1 && *bank != i {
+			continue
+		}
+
+		var address uint16 = 0x8000
+		if i == len(cart.PRG)-1 {
+			address = 0xC000
+		}
+
+		if *origin != "" {
+			address, err = nes.ParseNumber(*origin)
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("; PRG bank %d @ $%04X\n", i, address)
+
+		// Find the labels for this bank.
+		if table != nil {
+			var bankOffset int = i * 0x4000
+			var origin uint16 = address
+
+			table.PRGOffset = func(address uint16) int {
+				if address < origin || int(address)-int(origin) >= 0x4000 {
+					return -1
+				}
+
+				return bankOffset + int(address-origin)
+			}
+		}
+
+		for _, instruction := range nes.DisassembleBytes(cart.PRG[i], address, labels) {
+			if instruction.Label != "" {
+				fmt.Printf("%s:\n", instruction.Label)
+			}
+
+			fmt.Println(instruction)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skip2/nes/nes"
+)
+
+// runDumpPPU implements the "dump-ppu" command, which runs a ROM file without
+// the GUI and saves the PPU viewers (nametables, pattern tables, palette RAM,
+// OAM and the event timing of the last frame) as PNG images.
+func runDumpPPU(args []string) error {
+	flags := flag.NewFlagSet("dump-ppu", flag.ExitOnError)
+	frame := flags.Int("frame", 60, "number of the frame to dump the PPU after, from 1")
+	paletteName := flags.String("palette", "", "colour palette: a .pal file, or one of "+strings.Join(nes.PaletteNames(), ", "))
+	patternPalette := flags.Int("pattern-palette", 0, "palette RAM palette for the pattern tables: 0-3 background, 4-7 sprites")
+	dir := flags.String("dir", ".", "directory to write nametables.png, pattern0.png, pattern1.png, palette.png, oam.png and events.png to")
+	regionName := flags.String("region", "auto", regionUsage)
+	listEvents := flags.Bool("events", false, "also list the last frame's PPU events")
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: nes dump-ppu [options] FILENAME.ROM")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *frame < 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	var palette *nes.Palette = nes.DefaultPalette()
+	if *paletteName != "" {
+		var err error
+		palette, err = loadPalette(*paletteName)
+		if err != nil {
+			return err
+		}
+	}
+
+	cart, err := nes.LoadCartridge(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var console *nes.Console = nes.NewConsole(cart)
+
+	if err := setRegion(console, *regionName); err != nil {
+		return err
+	}
+
+	var ppu *nes.PPU = console.PPU
+	var events *nes.EventViewer = nes.NewEventViewer(console)
+
+	_, err = runFrames(console, *frame)
+	if err != nil {
+		return err
+	}
+
+	images := []struct {
+		filename string
+		img      *image.RGBA
+	}{
+		{"nametables.png", ppu.NametablesImage(palette)},
+		{"pattern0.png", ppu.PatternTableImage(0, *patternPalette, palette)},
+		{"pattern1.png", ppu.PatternTableImage(1, *patternPalette, palette)},
+		{"palette.png", ppu.PaletteRAMImage(palette)},
+		{"oam.png", ppu.OAMImage(palette)},
+		{"events.png", events.Image()},
+	}
+
+	for _, i := range images {
+		var img *image.RGBA = i.img
+
+		err = writeFile(filepath.Join(*dir, i.filename), func(w io.Writer) error {
+			return png.Encode(w, img)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *listEvents {
+		for _, event := range events.Events {
+			fmt.Println(event)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package filter upscales frames for display, with filters designed for pixel
+// art.
+//
+// The following filters are supported:
+// - nearest neighbour, at any integer scale
+// - Scale2x and Scale3x (AdvanceMAME)
+// - Smooth2x and Smooth3x, edge blending with hqx's colour thresholds
+// - xBR at 2x
+//
+// hq2x and hq3x are not implemented: Smooth2x and Smooth3x are not substitutes
+// for them, as they don't use hqx's interpolation rules for each of the 256
+// patterns of similar neighbours.
+//
+// Filters work on any *image.RGBA, e.g. the frames returned by
+// nes.Console.Step.
+package filter
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// A Filter upscales images.
+type Filter interface {
+	// Scale returns the factor by which the filter scales images.
+	Scale() int
+
+	// Apply returns src upscaled. The image may be reused by later calls.
+	Apply(src *image.RGBA) *image.RGBA
+}
+
+// Filters, by name.
+var filters = map[string]func() Filter{
+	"nearest2x": func() Filter { return Nearest(2) },
+	"nearest3x": func() Filter { return Nearest(3) },
+	"nearest4x": func() Filter { return Nearest(4) },
+	"scale2x":   Scale2x,
+	"scale3x":   Scale3x,
+	"smooth2x":  Smooth2x,
+	"smooth3x":  Smooth3x,
+	"xbr":       XBR,
+}
+
+// Names returns the names of the filters, for New.
+func Names() []string {
+	var names []string
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// New returns the filter name, e.g. "scale2x". See Names.
+func New(name string) (Filter, error) {
+	filter, ok := filters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+
+	return filter(), nil
+}
+
+// A filter which computes each block of scale x scale output pixels from the
+// 3x3 neighbourhood of an input pixel, given row by row:
+//
+//	A B C
+//	D E F
+//	G H I
+//
+// Pixels beyond the edges repeat the edge pixels.
+type kernelFilter struct {
+	scale  int
+	kernel func(n *[9]uint32, out []uint32)
+
+	dst *image.RGBA
+}
+
+func (f *kernelFilter) Scale() int {
+	return f.scale
+}
+
+func (f *kernelFilter) Apply(src *image.RGBA) *image.RGBA {
+	var width int = src.Rect.Dx()
+	var height int = src.Rect.Dy()
+	var dst *image.RGBA = output(&f.dst, width*f.scale, height*f.scale)
+
+	var n [9]uint32
+	var out []uint32 = make([]uint32, f.scale*f.scale)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					n[(dy+1)*3+dx+1] = pixel(src, x+dx, y+dy)
+				}
+			}
+
+			f.kernel(&n, out)
+
+			for oy := 0; oy < f.scale; oy++ {
+				for ox := 0; ox < f.scale; ox++ {
+					setPixel(dst, x*f.scale+ox, y*f.scale+oy, out[oy*f.scale+ox])
+				}
+			}
+		}
+	}
+
+	return dst
+}
+
+// Nearest returns a filter which scales images by an integer factor, by
+// repeating each pixel.
+func Nearest(scale int) Filter {
+	return &kernelFilter{
+		scale: scale,
+		kernel: func(n *[9]uint32, out []uint32) {
+			for i := range out {
+				out[i] = n[4]
+			}
+		},
+	}
+}
+
+// Returns *dst if it's an image of width x height, or else a new image which
+// is stored in *dst.
+func output(dst **image.RGBA, width int, height int) *image.RGBA {
+	if *dst == nil || (*dst).Rect.Dx() != width || (*dst).Rect.Dy() != height {
+		*dst = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	return *dst
+}
+
+// Returns the pixel at (x, y) relative to the top left of img as 0xRRGGBBAA.
+// Coordinates beyond the edges are clamped.
+func pixel(img *image.RGBA, x int, y int) uint32 {
+	if x < 0 {
+		x = 0
+	} else if x >= img.Rect.Dx() {
+		x = img.Rect.Dx() - 1
+	}
+
+	if y < 0 {
+		y = 0
+	} else if y >= img.Rect.Dy() {
+		y = img.Rect.Dy() - 1
+	}
+
+	var i int = y*img.Stride + x*4
+	var p []uint8 = img.Pix[i : i+4]
+
+	return uint32(p[0])<<24 | uint32(p[1])<<16 | uint32(p[2])<<8 | uint32(p[3])
+}
+
+// Sets the pixel at (x, y) relative to the top left of img to 0xRRGGBBAA.
+func setPixel(img *image.RGBA, x int, y int, colour uint32) {
+	var i int = y*img.Stride + x*4
+	var p []uint8 = img.Pix[i : i+4]
+
+	p[0] = uint8(colour >> 24)
+	p[1] = uint8(colour >> 16)
+	p[2] = uint8(colour >> 8)
+	p[3] = uint8(colour)
+}
+
+// Returns the weighted average of colours.
+func blend(colours []uint32, weights []int) uint32 {
+	var sums [4]int
+	var total int
+
+	for i, colour := range colours {
+		for channel := range sums {
+			sums[channel] += int(colour>>uint(24-channel*8)&0xFF) * weights[i]
+		}
+		total += weights[i]
+	}
+
+	var result uint32
+	for channel, sum := range sums {
+		result |= uint32(sum/total) << uint(24-channel*8)
+	}
+
+	return result
+}
@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Returns an image from rows of characters, '#' for white and '.' for black.
+func testImage(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetRGBA(x, y, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+			} else {
+				img.SetRGBA(x, y, color.RGBA{0x00, 0x00, 0x00, 0xFF})
+			}
+		}
+	}
+
+	return img
+}
+
+func TestFilterSizes(t *testing.T) {
+	src := testImage("#..", ".#.", "..#", "...")
+
+	for _, name := range Names() {
+		f, err := New(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := f.Apply(src)
+		if dst.Rect.Dx() != 3*f.Scale() || dst.Rect.Dy() != 4*f.Scale() {
+			t.Errorf("%s: output is %v, expected %dx%d\n", name, dst.Rect, 3*f.Scale(), 4*f.Scale())
+		}
+	}
+
+	if _, err := New("unknown"); err == nil {
+		t.Errorf("found unknown filter\n")
+	}
+}
+
+func TestScale2x(t *testing.T) {
+	src := testImage(
+		"##.",
+		"#..",
+		"...",
+	)
+
+	expected := testImage(
+		"####..",
+		"###...",
+		"###...",
+		"#.....",
+		"......",
+		"......",
+	)
+
+	dst := Scale2x().Apply(src)
+
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			if dst.RGBAAt(x, y) != expected.RGBAAt(x, y) {
+				t.Errorf("pixel (%d, %d) is %v, expected %v\n", x, y, dst.RGBAAt(x, y), expected.RGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestSmoothingFilters(t *testing.T) {
+	// A diagonal edge between white and black.
+	src := testImage(
+		"####",
+		"###.",
+		"##..",
+		"#...",
+	)
+
+	for _, f := range []Filter{Smooth2x(), Smooth3x(), XBR()} {
+		dst := f.Apply(src)
+		var scale int = f.Scale()
+
+		// Flat areas are unchanged.
+		if dst.RGBAAt(0, 0).R != 0xFF || dst.RGBAAt(4*scale-1, 4*scale-1).R != 0x00 {
+			t.Errorf("scale %d: flat area changed\n", scale)
+		}
+
+		// The corner of a pixel on the edge is blended.
+		var r uint8 = dst.RGBAAt(3*scale-1, 2*scale-1).R
+		if r == 0x00 || r == 0xFF {
+			t.Errorf("scale %d: edge not blended\n", scale)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package filter
+
+// Scale2x returns a filter which doubles the size of images with the Scale2x
+// (EPX) algorithm, which extends diagonal edges without adding colours.
+//
+// http://www.scale2x.it/algorithm
+func Scale2x() Filter {
+	return &kernelFilter{scale: 2, kernel: scale2x}
+}
+
+// Scale3x returns a filter which triples the size of images with the Scale3x
+// algorithm.
+//
+// http://www.scale2x.it/algorithm
+func Scale3x() Filter {
+	return &kernelFilter{scale: 3, kernel: scale3x}
+}
+
+func scale2x(n *[9]uint32, out []uint32) {
+	b, d, e, f, h := n[1], n[3], n[4], n[5], n[7]
+
+	for i := range out {
+		out[i] = e
+	}
+
+	if b != h && d != f {
+		if d == b {
+			out[0] = d
+		}
+		if b == f {
+			out[1] = f
+		}
+		if d == h {
+			out[2] = d
+		}
+		if h == f {
+			out[3] = f
+		}
+	}
+}
+
+func scale3x(n *[9]uint32, out []uint32) {
+	a, b, c, d, e, f, g, h, i := n[0], n[1], n[2], n[3], n[4], n[5], n[6], n[7], n[8]
+
+	for k := range out {
+		out[k] = e
+	}
+
+	if b == h || d == f {
+		return
+	}
+
+	if d == b {
+		out[0] = d
+	}
+	if (d == b && e != c) || (b == f && e != a) {
+		out[1] = b
+	}
+	if b == f {
+		out[2] = f
+	}
+	if (d == b && e != g) || (d == h && e != a) {
+		out[3] = d
+	}
+	if (b == f && e != i) || (h == f && e != c) {
+		out[5] = f
+	}
+	if d == h {
+		out[6] = d
+	}
+	if (d == h && e != i) || (h == f && e != g) {
+		out[7] = h
+	}
+	if h == f {
+		out[8] = f
+	}
+}
@@ -0,0 +1,131 @@
+package filter
+
+// Smooth2x returns a filter which doubles the size of images, blending pixels
+// across edges found by comparing neighbours in YUV.
+//
+// It uses hqx's colour thresholds, but not its table of blending rules for the
+// 256 patterns of similar neighbours: the rules are derived from each corner's
+// neighbours instead, so output differs from hq2x's.
+func Smooth2x() Filter {
+	return &kernelFilter{scale: 2, kernel: smooth2x}
+}
+
+// Smooth3x returns a filter which triples the size of images, as Smooth2x.
+func Smooth3x() Filter {
+	return &kernelFilter{scale: 3, kernel: smooth3x}
+}
+
+// Corners of the 3x3 neighbourhood, with the neighbours sharing each corner
+// with the centre: corner, then the vertical and horizontal neighbour.
+var smoothCorners = [4][3]int{
+	{0, 1, 3}, // Top left: A, B, D.
+	{2, 1, 5}, // Top right: C, B, F.
+	{6, 7, 3}, // Bottom left: G, H, D.
+	{8, 7, 5}, // Bottom right: I, H, F.
+}
+
+// Returns the colour of the corner of the centre pixel nearest neighbour
+// corner, and true if it is on an edge across the corner.
+func smoothCorner(n *[9]uint32, corner int) (uint32, bool) {
+	var e uint32 = n[4]
+	var c, p, q uint32 = n[smoothCorners[corner][0]], n[smoothCorners[corner][1]], n[smoothCorners[corner][2]]
+
+	switch {
+	case yuvDiffer(e, p) && yuvDiffer(e, q) && !yuvDiffer(p, q):
+		// Diagonal edge.
+		return blend([]uint32{e, p, q}, []int{2, 1, 1}), true
+	case yuvDiffer(e, p) && !yuvDiffer(e, q):
+		return blend([]uint32{e, p}, []int{3, 1}), false
+	case yuvDiffer(e, q) && !yuvDiffer(e, p):
+		return blend([]uint32{e, q}, []int{3, 1}), false
+	case yuvDiffer(e, c) && !yuvDiffer(e, p) && !yuvDiffer(e, q):
+		// Only the corner differs.
+		return blend([]uint32{e, c}, []int{3, 1}), false
+	}
+
+	return e, false
+}
+
+func smooth2x(n *[9]uint32, out []uint32) {
+	for corner := range smoothCorners {
+		out[corner], _ = smoothCorner(n, corner)
+	}
+}
+
+func smooth3x(n *[9]uint32, out []uint32) {
+	var e uint32 = n[4]
+	var corners [4]uint32
+	var edges [4]bool
+
+	for corner := range smoothCorners {
+		corners[corner], edges[corner] = smoothCorner(n, corner)
+	}
+
+	out[0], out[2], out[6], out[8] = corners[0], corners[1], corners[2], corners[3]
+	out[4] = e
+
+	// Edge centres, with the corners on either side.
+	sides := [4]struct {
+		out, neighbour, corner1, corner2 int
+	}{
+		{1, 1, 0, 1}, // Top: B.
+		{3, 3, 0, 2}, // Left: D.
+		{5, 5, 1, 3}, // Right: F.
+		{7, 7, 2, 3}, // Bottom: H.
+	}
+
+	for _, side := range sides {
+		var neighbour uint32 = n[side.neighbour]
+
+		switch {
+		case !yuvDiffer(e, neighbour):
+			out[side.out] = e
+		case edges[side.corner1] && edges[side.corner2]:
+			out[side.out] = blend([]uint32{e, neighbour}, []int{3, 1})
+		default:
+			out[side.out] = blend([]uint32{e, neighbour}, []int{7, 1})
+		}
+	}
+}
+
+// Returns the Y, U and V components of a 0xRRGGBBAA colour.
+func yuv(colour uint32) (int, int, int) {
+	r := int(colour >> 24 & 0xFF)
+	g := int(colour >> 16 & 0xFF)
+	b := int(colour >> 8 & 0xFF)
+
+	y := (299*r + 587*g + 114*b) / 1000
+	u := (-169*r - 331*g + 500*b) / 1000
+	v := (500*r - 419*g - 81*b) / 1000
+
+	return y, u, v
+}
+
+// Returns true if a and b are visibly different colours, using hqx's
+// thresholds in YUV.
+func yuvDiffer(a uint32, b uint32) bool {
+	if a == b {
+		return false
+	}
+
+	ay, au, av := yuv(a)
+	by, bu, bv := yuv(b)
+
+	return abs(ay-by) > 0x30 || abs(au-bu) > 0x07 || abs(av-bv) > 0x06
+}
+
+// Returns a weighted distance between a and b in YUV.
+func yuvDistance(a uint32, b uint32) int {
+	ay, au, av := yuv(a)
+	by, bu, bv := yuv(b)
+
+	return 48*abs(ay-by) + 7*abs(au-bu) + 6*abs(av-bv)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
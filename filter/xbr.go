@@ -0,0 +1,78 @@
+package filter
+
+import "image"
+
+// XBR returns a filter which doubles the size of images with Hyllian's xBR
+// algorithm (level 1). At each corner of a pixel, weighted colour distances
+// across its 5x5 neighbourhood decide whether an edge runs across the corner,
+// and if so the corner is blended with the nearest neighbouring colour.
+func XBR() Filter {
+	return &xbrFilter{}
+}
+
+type xbrFilter struct {
+	dst *image.RGBA
+}
+
+func (f *xbrFilter) Scale() int {
+	return 2
+}
+
+func (f *xbrFilter) Apply(src *image.RGBA) *image.RGBA {
+	var width int = src.Rect.Dx()
+	var height int = src.Rect.Dy()
+	var dst *image.RGBA = output(&f.dst, width*2, height*2)
+
+	// Corners, by the direction of the corner from the centre.
+	corners := [4][2]int{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for i, corner := range corners {
+				var sx, sy int = corner[0], corner[1]
+
+				// Returns the pixel at an offset from the centre, with the
+				// corner treated as the bottom right.
+				at := func(dx int, dy int) uint32 {
+					return pixel(src, x+sx*dx, y+sy*dy)
+				}
+
+				setPixel(dst, x*2+i%2, y*2+i/2, xbrCorner(at))
+			}
+		}
+	}
+
+	return dst
+}
+
+// Returns the colour of the bottom right corner of the centre pixel, given the
+// pixels at offsets from the centre:
+//
+//	   A1 B1 C1
+//	A0  A  B  C C4
+//	D0  D  E  F F4
+//	G0  G  H  I I4
+//	   G5 H5 I5
+func xbrCorner(at func(dx int, dy int) uint32) uint32 {
+	e, f, h, i := at(0, 0), at(1, 0), at(0, 1), at(1, 1)
+	b, c, d, g := at(0, -1), at(1, -1), at(-1, 0), at(-1, 1)
+	f4, h5, i4, i5 := at(2, 0), at(0, 2), at(2, 1), at(1, 2)
+
+	// Colour variation along the F-H diagonal, and along the E-I diagonal.
+	// Less variation along F-H means an edge runs across the corner.
+	var alongFH int = yuvDistance(e, c) + yuvDistance(e, g) + yuvDistance(i, f4) + yuvDistance(i, h5) +
+		4*yuvDistance(h, f)
+	var alongEI int = yuvDistance(h, d) + yuvDistance(h, i5) + yuvDistance(f, i4) + yuvDistance(f, b) +
+		4*yuvDistance(e, i)
+
+	if alongFH < alongEI && e != f && e != h {
+		var nearest uint32 = h
+		if yuvDistance(e, f) <= yuvDistance(e, h) {
+			nearest = f
+		}
+
+		return blend([]uint32{e, nearest}, []int{1, 1})
+	}
+
+	return e
+}
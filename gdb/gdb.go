@@ -0,0 +1,546 @@
+// Package gdb implements a GDB remote serial protocol server for the NES CPU,
+// so a GDB compatible debugger front end can attach to a running Console.
+//
+// The register file is exposed as A, X, Y, SP, PC and P, in that order. All
+// registers are 8 bits, except PC which is 16 bits (little endian). Target
+// memory is the CPU address space ($0000-$FFFF). Memory reads have no side
+// effects, memory writes are performed on the CPU bus.
+//
+// Software and hardware breakpoints (Z0/Z1), and write, read and access
+// watchpoints (Z2/Z3/Z4) are supported, as are single-step and continue.
+// Execution may be interrupted by the client with Ctrl-C.
+//
+// https://sourceware.org/gdb/onlinedocs/gdb/Remote-Protocol.html
+package gdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/nes/nes"
+)
+
+// Target description, sent in response to qXfer:features:read.
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.nesdev.6502">
+    <reg name="a" bitsize="8" type="uint8" regnum="0"/>
+    <reg name="x" bitsize="8" type="uint8"/>
+    <reg name="y" bitsize="8" type="uint8"/>
+    <reg name="sp" bitsize="8" type="uint8"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+    <reg name="p" bitsize="8" type="uint8"/>
+  </feature>
+</target>
+`
+
+// Maximum size of packets sent or received, as advertised in qSupported.
+const packetSize = 0x4000
+
+// Signal numbers used in stop replies.
+const (
+	sigILL  = 4
+	sigTRAP = 5
+)
+
+// Server serves the GDB remote serial protocol for a Console.
+type Server struct {
+	console  *nes.Console
+	debugger *nes.Debugger
+
+	// Breakpoint and watchpoint IDs, keyed by GDB Z packet type and address.
+	points map[string]int
+
+	noAck bool
+}
+
+// NewServer returns a Server for console. A Debugger is attached to console.
+func NewServer(console *nes.Console) *Server {
+	return &Server{
+		console:  console,
+		debugger: nes.NewDebugger(console),
+		points:   make(map[string]int),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr, and serves one client
+// connection at a time.
+func (s *Server) ListenAndServe(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, and serves them one at a time.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		err = s.ServeConn(conn)
+		conn.Close()
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+}
+
+// A packet or interrupt request received from the client.
+type message struct {
+	data      string
+	interrupt bool
+	corrupt   bool // True if the packet's checksum was wrong.
+	err       error
+}
+
+// ServeConn serves a single client connection, until the client detaches or
+// the connection is closed.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	messages := make(chan message)
+	done := make(chan struct{})
+	defer close(done)
+
+	go s.readMessages(bufio.NewReader(conn), messages, done)
+
+	s.noAck = false
+
+	for m := range messages {
+		if m.err != nil {
+			return m.err
+		}
+
+		if m.interrupt {
+			continue
+		}
+
+		// Ask for the packet to be resent. In no-ack mode, it is dropped.
+		if m.corrupt {
+			if !s.noAck {
+				if _, err := conn.Write([]byte("-")); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !s.noAck {
+			if _, err := conn.Write([]byte("+")); err != nil {
+				return err
+			}
+		}
+
+		var reply string
+		var detach bool
+
+		switch {
+		case strings.HasPrefix(m.data, "c"):
+			reply = s.resume(s.debugger.Continue, messages)
+		case strings.HasPrefix(m.data, "s"):
+			reply = s.resume(s.debugger.StepInto, messages)
+		case m.data == "D" || strings.HasPrefix(m.data, "D;"):
+			reply = "OK"
+			detach = true
+		case m.data == "k":
+			return nil
+		default:
+			reply = s.handle(m.data)
+		}
+
+		if err := s.send(conn, reply); err != nil {
+			return err
+		}
+
+		if detach {
+			return nil
+		}
+	}
+
+	return io.EOF
+}
+
+// Reads packets and interrupt requests from r, until an error occurs or done
+// is closed.
+func (s *Server) readMessages(r *bufio.Reader, messages chan<- message, done <-chan struct{}) {
+	defer close(messages)
+
+	for {
+		var m message
+
+		b, err := r.ReadByte()
+		switch {
+		case err != nil:
+			m.err = err
+		case b == 0x03:
+			m.interrupt = true
+		case b == '$':
+			m.data, m.corrupt, m.err = readPacket(r)
+		default:
+			// Acks, and noise between packets.
+			continue
+		}
+
+		select {
+		case messages <- m:
+		case <-done:
+			return
+		}
+
+		if m.err != nil {
+			return
+		}
+	}
+}
+
+// Reads the remainder of a packet, after the leading '$'. Returns true if the
+// packet's checksum is wrong.
+func readPacket(r *bufio.Reader) (string, bool, error) {
+	data, err := r.ReadString('#')
+	if err != nil {
+		return "", false, err
+	}
+	data = data[:len(data)-1]
+
+	var checksum [2]byte
+	if _, err = io.ReadFull(r, checksum[:]); err != nil {
+		return "", false, err
+	}
+
+	expected, err := strconv.ParseUint(string(checksum[:]), 16, 8)
+	if err != nil || byte(expected) != packetChecksum(data) {
+		return "", true, nil
+	}
+
+	return unescape(data), false, nil
+}
+
+// Sends a packet containing data.
+func (s *Server) send(w io.Writer, data string) error {
+	_, err := fmt.Fprintf(w, "$%s#%02x", data, packetChecksum(data))
+	return err
+}
+
+func packetChecksum(data string) byte {
+	var sum byte
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+
+	return sum
+}
+
+// Removes '}' escapes from binary packet data.
+func unescape(data string) string {
+	if !strings.Contains(data, "}") {
+		return data
+	}
+
+	var result bytes.Buffer
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			result.WriteByte(data[i] ^ 0x20)
+		} else {
+			result.WriteByte(data[i])
+		}
+	}
+
+	return result.String()
+}
+
+// Runs the console with run, stopping early if the client sends an interrupt
+// request. Returns the stop reply.
+func (s *Server) resume(run func() (*nes.Stop, error), messages <-chan message) string {
+	type result struct {
+		stop *nes.Stop
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		stop, err := run()
+		results <- result{stop, err}
+	}()
+
+	for {
+		select {
+		case r := <-results:
+			return s.stopReply(r.stop, r.err)
+		case m, ok := <-messages:
+			if !ok || m.interrupt || m.err != nil {
+				s.debugger.Pause()
+			}
+
+			if !ok {
+				messages = nil
+			}
+		}
+	}
+}
+
+// Returns the stop reply packet for a Stop.
+func (s *Server) stopReply(stop *nes.Stop, err error) string {
+	if err != nil {
+		return fmt.Sprintf("S%02x", sigILL)
+	}
+
+	if stop.Reason == nes.StopWatchpoint && stop.Access != nes.AccessExecute {
+		var kind string
+		switch stop.Watchpoint.Access {
+		case nes.AccessWrite:
+			kind = "watch"
+		case nes.AccessRead:
+			kind = "rwatch"
+		default:
+			kind = "awatch"
+		}
+
+		return fmt.Sprintf("T%02x%s:%x;", sigTRAP, kind, stop.Address)
+	}
+
+	return fmt.Sprintf("S%02x", sigTRAP)
+}
+
+// Handles packets other than those which resume execution. Returns the reply.
+func (s *Server) handle(data string) string {
+	var cpu *nes.CPU = s.console.CPU
+
+	switch {
+	case data == "?":
+		return fmt.Sprintf("S%02x", sigTRAP)
+	case data == "g":
+		return hex.EncodeToString(s.registers())
+	case strings.HasPrefix(data, "G"):
+		registers, err := hex.DecodeString(data[1:])
+		if err != nil || len(registers) != 7 {
+			return "E01"
+		}
+
+		s.setRegisters(registers)
+		return "OK"
+	case strings.HasPrefix(data, "p"):
+		n, err := strconv.ParseUint(data[1:], 16, 8)
+		if err != nil || n > 5 {
+			return "E01"
+		}
+
+		offset, size := registerOffset(int(n))
+		return hex.EncodeToString(s.registers()[offset : offset+size])
+	case strings.HasPrefix(data, "P"):
+		fields := strings.SplitN(data[1:], "=", 2)
+		n, err := strconv.ParseUint(fields[0], 16, 8)
+		if err != nil || n > 5 || len(fields) != 2 {
+			return "E01"
+		}
+
+		value, err := hex.DecodeString(fields[1])
+		offset, size := registerOffset(int(n))
+		if err != nil || len(value) != size {
+			return "E01"
+		}
+
+		registers := s.registers()
+		copy(registers[offset:], value)
+		s.setRegisters(registers)
+		return "OK"
+	case strings.HasPrefix(data, "m"):
+		address, length, err := parseAddressLength(data[1:])
+		if err != nil {
+			return "E01"
+		}
+
+		// Reads stop at $FFFF, and must fit in a reply packet as hex.
+		if int(address)+length > 0x10000 {
+			length = 0x10000 - int(address)
+		}
+		if length > packetSize/2 {
+			length = packetSize / 2
+		}
+
+		memory := make([]byte, length)
+		for i := range memory {
+			memory[i] = cpu.Bus.Peek(address + uint16(i))
+		}
+
+		return hex.EncodeToString(memory)
+	case strings.HasPrefix(data, "M"):
+		fields := strings.SplitN(data[1:], ":", 2)
+		address, length, err := parseAddressLength(fields[0])
+		if err != nil || len(fields) != 2 {
+			return "E01"
+		}
+
+		memory, err := hex.DecodeString(fields[1])
+		if err != nil || len(memory) != length {
+			return "E01"
+		}
+
+		if int(address)+len(memory) > 0x10000 {
+			memory = memory[:0x10000-int(address)]
+		}
+
+		for i, value := range memory {
+			cpu.Bus.Write(address+uint16(i), value)
+		}
+
+		return "OK"
+	case strings.HasPrefix(data, "Z"), strings.HasPrefix(data, "z"):
+		return s.setPoint(data)
+	case strings.HasPrefix(data, "qSupported"):
+		return fmt.Sprintf("PacketSize=%x;qXfer:features:read+;QStartNoAckMode+", packetSize)
+	case data == "QStartNoAckMode":
+		s.noAck = true
+		return "OK"
+	case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+		offset, length, err := parseAddressLength(data[len("qXfer:features:read:target.xml:"):])
+		if err != nil {
+			return "E01"
+		}
+
+		if int(offset) >= len(targetXML) {
+			return "l"
+		}
+
+		end := int(offset) + length
+		if end >= len(targetXML) {
+			return "l" + targetXML[offset:]
+		}
+
+		return "m" + targetXML[offset:end]
+	case data == "qAttached":
+		return "1"
+	case data == "qC":
+		return "QC1"
+	case data == "qfThreadInfo":
+		return "m1"
+	case data == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(data, "H"), strings.HasPrefix(data, "T"):
+		return "OK"
+	}
+
+	// Unsupported.
+	return ""
+}
+
+// Returns the register file, in GDB order.
+func (s *Server) registers() []byte {
+	var cpu *nes.CPU = s.console.CPU
+
+	return []byte{cpu.A, cpu.X, cpu.Y, cpu.SP, byte(cpu.PC), byte(cpu.PC >> 8), cpu.P()}
+}
+
+// Sets the register file, in GDB order.
+func (s *Server) setRegisters(registers []byte) {
+	var cpu *nes.CPU = s.console.CPU
+
+	cpu.A = registers[0]
+	cpu.X = registers[1]
+	cpu.Y = registers[2]
+	cpu.SP = registers[3]
+	cpu.PC = uint16(registers[4]) | uint16(registers[5])<<8
+	cpu.SetP(registers[6])
+}
+
+// Returns the offset and size in bytes of register n in the register file.
+func registerOffset(n int) (int, int) {
+	switch {
+	case n < 4:
+		return n, 1
+	case n == 4:
+		return 4, 2
+	default:
+		return 6, 1
+	}
+}
+
+// Handles Z (insert) and z (remove) breakpoint and watchpoint packets.
+func (s *Server) setPoint(data string) string {
+	fields := strings.Split(data[1:], ",")
+	if len(fields) < 3 {
+		return "E01"
+	}
+
+	address, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+
+	length, err := strconv.ParseUint(fields[2], 16, 16)
+	if err != nil || length == 0 {
+		length = 1
+	}
+
+	var key string = fields[0] + "," + fields[1]
+
+	if data[0] == 'z' {
+		if id, ok := s.points[key]; ok {
+			s.debugger.Delete(id)
+			delete(s.points, key)
+		}
+
+		return "OK"
+	}
+
+	if _, ok := s.points[key]; ok {
+		return "OK"
+	}
+
+	// Ranges stop at $FFFF.
+	if address+length > 0x10000 {
+		length = 0x10000 - address
+	}
+
+	var start uint16 = uint16(address)
+	var end uint16 = uint16(address + length - 1)
+
+	switch fields[0] {
+	case "0", "1":
+		s.points[key] = s.debugger.AddBreakpoint(start, nil).ID
+	case "2":
+		s.points[key] = s.debugger.AddWatchpoint(nes.CPUAddressSpace, start, end, nes.AccessWrite).ID
+	case "3":
+		s.points[key] = s.debugger.AddWatchpoint(nes.CPUAddressSpace, start, end, nes.AccessRead).ID
+	case "4":
+		s.points[key] = s.debugger.AddWatchpoint(nes.CPUAddressSpace, start, end, nes.AccessRead|nes.AccessWrite).ID
+	default:
+		return ""
+	}
+
+	return "OK"
+}
+
+// Parses "ADDR,LENGTH" (both hex).
+func parseAddressLength(text string) (uint16, int, error) {
+	fields := strings.Split(text, ",")
+	if len(fields) != 2 {
+		return 0, 0, errors.New("gdb: expected address,length")
+	}
+
+	address, err := strconv.ParseUint(fields[0], 16, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	length, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uint16(address), int(length), nil
+}
@@ -0,0 +1,192 @@
+package gdb
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/skip2/nes/nes"
+)
+
+// A GDB client connected to a Server over TCP.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Starts a Server on a local TCP socket, for a console running program at
+// $8000, and connects to it.
+func newTestClient(t *testing.T, program []byte) (*testClient, *nes.Console) {
+	cart := nes.NewCartridge(2, 1, 1)
+	copy(cart.PRG[0], program)
+	cart.PRG[1][0x3FFC] = 0x00
+	cart.PRG[1][0x3FFD] = 0x80
+	cart.Mapper = nes.NewMapper0(cart)
+
+	console := nes.NewConsole(cart)
+	server := NewServer(console)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %s\n", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go server.Serve(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %s\n", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}, console
+}
+
+// Sends a packet, and returns the reply.
+func (c *testClient) request(data string) string {
+	c.t.Helper()
+
+	fmt.Fprintf(c.conn, "$%s#%02x", data, packetChecksum(data))
+
+	if ack, err := c.reader.ReadByte(); err != nil || ack != '+' {
+		c.t.Fatalf("%q: expected ack, got %q (%v)\n", data, ack, err)
+	}
+
+	return c.reply()
+}
+
+// Reads a reply packet, and acknowledges it.
+func (c *testClient) reply() string {
+	c.t.Helper()
+
+	if b, err := c.reader.ReadByte(); err != nil || b != '$' {
+		c.t.Fatalf("expected packet, got %q (%v)\n", b, err)
+	}
+
+	packet, err := c.reader.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("reading packet: %s\n", err)
+	}
+	packet = packet[:len(packet)-1]
+
+	var checksum [2]byte
+	c.reader.Read(checksum[:1])
+	c.reader.Read(checksum[1:])
+	if string(checksum[:]) != fmt.Sprintf("%02x", packetChecksum(packet)) {
+		c.t.Fatalf("packet %q has bad checksum %q\n", packet, checksum)
+	}
+
+	c.conn.Write([]byte("+"))
+
+	return packet
+}
+
+func (c *testClient) expect(data string, expected string) {
+	c.t.Helper()
+
+	if reply := c.request(data); reply != expected {
+		c.t.Fatalf("%q: got %q, expected %q\n", data, reply, expected)
+	}
+}
+
+// LDX #$00; loop: INX; STX $10; JMP loop
+var testProgram = []byte{0xA2, 0x00, 0xE8, 0x86, 0x10, 0x4C, 0x02, 0x80}
+
+func TestServerRegistersAndMemory(t *testing.T) {
+	client, console := newTestClient(t, testProgram)
+
+	if reply := client.request("qSupported:swbreak+"); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Fatalf("qSupported: got %q\n", reply)
+	}
+
+	if reply := client.request("qXfer:features:read:target.xml:0,fff"); !strings.HasPrefix(reply, "l<?xml") {
+		t.Fatalf("qXfer: got %q\n", reply)
+	}
+
+	client.expect("?", "S05")
+
+	// A, X, Y, SP, PC (little endian), P.
+	client.expect("g", fmt.Sprintf("000000%02x0080%02x", console.CPU.SP, console.CPU.P()))
+
+	client.expect("G0102031f3480e1", "OK")
+	if console.CPU.A != 1 || console.CPU.X != 2 || console.CPU.Y != 3 || console.CPU.SP != 0x1F ||
+		console.CPU.PC != 0x8034 || console.CPU.P() != 0xE1 {
+		t.Fatalf("G: registers not set\n%s", console.CPU)
+	}
+
+	client.expect("p4", "3480")
+	client.expect("P0=7f", "OK")
+	client.expect("p0", "7f")
+	client.expect("P4=0080", "OK")
+
+	client.expect("m8000,4", "a200e886")
+	client.expect("M0300,3:aabbcc", "OK")
+	client.expect("m0300,3", "aabbcc")
+	client.expect("m02ff,1", "00")
+
+	// Reads stop at $FFFF, and at half the packet size.
+	client.expect("mfffc,8", "00800000")
+	if reply := client.request("m0,ffff"); len(reply) != packetSize {
+		t.Fatalf("m0,ffff: got %d hex digits, expected %d\n", len(reply), packetSize)
+	}
+}
+
+func TestServerBadChecksum(t *testing.T) {
+	client, _ := newTestClient(t, testProgram)
+
+	fmt.Fprintf(client.conn, "$?#00")
+	if nak, err := client.reader.ReadByte(); err != nil || nak != '-' {
+		t.Fatalf("expected nak, got %q (%v)\n", nak, err)
+	}
+
+	// The resent packet is handled.
+	client.expect("?", "S05")
+}
+
+func TestServerExecution(t *testing.T) {
+	client, console := newTestClient(t, testProgram)
+
+	client.expect("s", "S05")
+	client.expect("s", "S05")
+	if console.CPU.PC != 0x8003 || console.CPU.X != 1 {
+		t.Fatalf("s: PC=%04X X=%02X, expected PC=8003 X=01\n", console.CPU.PC, console.CPU.X)
+	}
+
+	// Breakpoint at the JMP.
+	client.expect("Z0,8005,1", "OK")
+	client.expect("c", "S05")
+	if console.CPU.PC != 0x8005 {
+		t.Fatalf("c: PC=%04X, expected 8005\n", console.CPU.PC)
+	}
+	client.expect("z0,8005,1", "OK")
+
+	// Write watchpoint on $10.
+	client.expect("Z2,10,1", "OK")
+	client.expect("c", "T05watch:10;")
+	if console.CPU.PC != 0x8005 || console.CPU.X != 2 {
+		t.Fatalf("watch: PC=%04X X=%02X, expected PC=8005 X=02\n", console.CPU.PC, console.CPU.X)
+	}
+	client.expect("z2,10,1", "OK")
+
+	// Watchpoints running past $FFFF stop there, so still fire.
+	client.expect("Z2,10,fffa", "OK")
+	client.expect("c", "T05watch:10;")
+	client.expect("z2,10,fffa", "OK")
+
+	// Run freely, until interrupted.
+	fmt.Fprintf(client.conn, "$c#%02x", 'c')
+	if ack, _ := client.reader.ReadByte(); ack != '+' {
+		t.Fatalf("c: expected ack\n")
+	}
+
+	client.conn.Write([]byte{0x03})
+	if reply := client.reply(); reply != "S05" {
+		t.Fatalf("interrupt: got %q, expected S05\n", reply)
+	}
+
+	client.expect("D", "OK")
+}
@@ -1,21 +1,81 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/skip2/nes/dap"
+	"github.com/skip2/nes/filter"
+	"github.com/skip2/nes/gdb"
 	"github.com/skip2/nes/nes"
+	"github.com/skip2/nes/symbols"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "disasm" {
+		err := runDisasm(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "dump-ppu" {
+		err := runDumpPPU(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "screenshot" {
+		err := runScreenshot(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	aspect := flag.Bool("aspect", false, "stretch frames to the 8:7 pixel aspect ratio of a TV")
+	cdlFilename := flag.String("cdl", "", "log PRG and CHR ROM usage to this FCEUX .cdl code/data log file, adding to it if it exists")
+	dapAddress := flag.String("dap", "", "serve the Debug Adapter Protocol on this TCP address (e.g. localhost:4711) instead of running the GUI")
+	debug := flag.Bool("debug", false, "run the interactive debugger instead of the GUI")
+	filterName := flag.String("filter", "", "upscaling filter: one of "+strings.Join(filter.Names(), ", "))
+	frameReportFilename := flag.String("frame-report", "", "write the CPU cycles used in each frame and its NMI handler to this file")
+	gdbAddress := flag.String("gdb", "", "serve the GDB remote protocol on this TCP address (e.g. localhost:1234) instead of running the GUI")
+	labelsFilename := flag.String("labels", "", "comma separated label files for the debuggers, trace log and error messages (ld65 -Ln or --dbgfile .dbg, FCEUX .nl or Mesen .mlb)")
+	noSpriteLimit := flag.Bool("no-sprite-limit", false, "draw all sprites on each scanline rather than the first 8, reducing flicker")
+	ntsc := flag.Bool("ntsc", false, "simulate NTSC composite video (ignores -palette)")
+	overscan := flag.String("overscan", "", overscanUsage)
+	paletteName := flag.String("palette", "", "colour palette: a .pal file, or one of "+strings.Join(nes.PaletteNames(), ", "))
+	powerOn := flag.String("power-on", "zeros", "power-on contents of RAM, VRAM, OAM and cartridge RAM: zeros, ones ($FF), hardware (4 x $00, 4 x $FF) or random")
+	ppuViewers := flag.Bool("ppu-viewers", false, "show the nametables, pattern tables, palette RAM, OAM and PPU event timing in extra windows")
+	profileFilename := flag.String("profile", "", "write a pprof CPU cycle profile to this file, for go tool pprof")
+	randomAlignment := flag.Bool("random-alignment", false, "power on with a random CPU/PPU clock alignment")
+	regionName := flag.String("region", "auto", regionUsage)
+	seed := flag.Int64("seed", 0, "seed for -power-on random and -random-alignment (default: from the time, and logged)")
+	traceFilename := flag.String("trace", "", "write an execution trace log to this file")
+	traceFormat := flag.String("trace-format", "nestest", "trace log format: nestest, fceux or mesen")
+	traceAfterFrame := flag.Uint64("trace-after-frame", 0, "only trace from this frame onwards")
+	traceBank := flag.Int("trace-bank", -1, "only trace instructions in this 16k PRG ROM bank (-1 for all)")
+	traceMinPC := flag.String("trace-min-pc", "$0000", "only trace instructions at or above this address")
+	traceMaxPC := flag.String("trace-max-pc", "$FFFF", "only trace instructions at or below this address")
 	flag.Parse()
 
 	var args []string = flag.Args()
 
 	if len(args) != 1 {
-		fmt.Println("Usage: nes FILENAME.ROM")
+		fmt.Println("Usage: nes [options] FILENAME.ROM")
+		fmt.Println("       nes disasm [options] FILENAME.ROM")
+		fmt.Println("       nes dump-ppu [options] FILENAME.ROM")
+		fmt.Println("       nes screenshot [options] FILENAME.ROM")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -28,10 +88,263 @@ func main() {
 	}
 
 	var console *nes.Console = nes.NewConsole(cart)
-	var gui *nes.GUI = nes.NewGUI(console)
 
-	err = gui.Run()
+	if err := setRegion(console, *regionName); err != nil {
+		log.Fatal(err)
+	}
+
+	console.RAMPattern, err = nes.ParseRAMPattern(*powerOn)
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	console.RandomAlignment = *randomAlignment
+	console.Seed = *seed
+
+	if console.RAMPattern == nes.RAMRandom || console.RandomAlignment {
+		if console.Seed == 0 {
+			console.Seed = time.Now().UnixNano()
+		}
+		log.Printf("power-on seed %d", console.Seed)
+	}
+
+	// Power on again with the options above.
+	console.PowerCycle()
+
+	if *paletteName != "" {
+		palette, err := loadPalette(*paletteName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		console.PPU.SetPalette(palette)
+	}
+
+	console.PPU.NoSpriteLimit = *noSpriteLimit
+
+	if *ntsc {
+		console.PPU.Converter = nes.NewNTSCConverter()
+	}
+
+	var labels nes.Labeler
+	var info *symbols.DebugInfo
+
+	if *labelsFilename != "" {
+		var filenames []string = strings.Split(*labelsFilename, ",")
+
+		table, err := symbols.Load(filenames...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		table.PRGOffset = cart.PRGOffset
+		labels = table
+
+		// Source line information for the DAP server.
+		for _, filename := range filenames {
+			if strings.HasSuffix(strings.ToLower(filename), ".dbg") {
+				info, err = symbols.LoadDebugInfo(filename)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+
+		console.CPU.Labels = labels
+	}
+
+	// Run on exit to flush and close output files.
+	var cleanups []func() error
+
+	if *traceFilename != "" {
+		formats := map[string]nes.TraceFormat{
+			"nestest": nes.TraceNestest,
+			"fceux":   nes.TraceFCEUX,
+			"mesen":   nes.TraceMesen,
+		}
+
+		format, ok := formats[*traceFormat]
+		if !ok {
+			log.Fatalf("unknown trace format %q", *traceFormat)
+		}
+
+		file, err := os.Create(*traceFilename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		writer := bufio.NewWriter(file)
+		cleanups = append(cleanups, func() error {
+			writer.Flush()
+			return file.Close()
+		})
+
+		tracer := nes.NewTracer(console, writer, format)
+		tracer.Labels = labels
+		tracer.AfterFrame = *traceAfterFrame
+		tracer.Bank = *traceBank
+
+		tracer.MinPC, err = nes.ParseNumber(*traceMinPC)
+		if err != nil {
+			log.Fatalf("-trace-min-pc: %s", err)
+		}
+
+		tracer.MaxPC, err = nes.ParseNumber(*traceMaxPC)
+		if err != nil {
+			log.Fatalf("-trace-max-pc: %s", err)
+		}
+	}
+
+	if *cdlFilename != "" {
+		logger := nes.NewCodeDataLogger(console)
+
+		err = logger.LoadFile(*cdlFilename)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+
+		cleanups = append(cleanups, func() error {
+			return logger.SaveFile(*cdlFilename)
+		})
+	}
+
+	if *profileFilename != "" || *frameReportFilename != "" {
+		profiler := nes.NewProfiler(console)
+		profiler.Labels = labels
+
+		cleanups = append(cleanups, func() error {
+			if *profileFilename != "" {
+				if err := writeFile(*profileFilename, profiler.WriteProfile); err != nil {
+					return err
+				}
+			}
+
+			if *frameReportFilename != "" {
+				return writeFile(*frameReportFilename, profiler.WriteFrameReport)
+			}
+
+			return nil
+		})
+	}
+
+	if *debug {
+		err = runDebugger(console, labels, os.Stdin, os.Stdout)
+	} else if *dapAddress != "" {
+		log.Printf("waiting for DAP connection on %s", *dapAddress)
+		err = dap.NewServer(console, info, labels).ListenAndServe(*dapAddress)
+	} else if *gdbAddress != "" {
+		log.Printf("waiting for GDB connection on %s", *gdbAddress)
+		err = gdb.NewServer(console).ListenAndServe(*gdbAddress)
+	} else {
+		var gui *nes.GUI = nes.NewGUI(console)
+		gui.PPUViewers = *ppuViewers
+
+		gui.Display, err = newDisplay(*overscan, *aspect)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *ppuViewers {
+			nes.NewEventViewer(console)
+		}
+
+		if *filterName != "" {
+			gui.Filter, err = filter.New(*filterName)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		err = gui.Run()
+	}
+
+	for _, cleanup := range cleanups {
+		if cleanupErr := cleanup(); err == nil {
+			err = cleanupErr
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Returns the built-in palette name, or else loads the .pal file name.
+func loadPalette(name string) (*nes.Palette, error) {
+	palette, err := nes.PresetPalette(name)
+	if err != nil {
+		palette, err = nes.LoadPalette(name)
+	}
+
+	return palette, err
+}
+
+// Usage of the -region flag.
+const regionUsage = "console region: ntsc, pal, dendy, or auto to use the region in the ROM's NES 2.0 header (NTSC if none)"
+
+// Sets the region of console from the -region flag, unless it's "auto".
+func setRegion(console *nes.Console, name string) error {
+	if name == "auto" {
+		return nil
+	}
+
+	region, err := nes.ParseRegion(name)
+	if err != nil {
+		return err
+	}
+
+	console.SetRegion(region)
+
+	return nil
+}
+
+// Usage of the -overscan flag.
+const overscanUsage = "crop this many pixels from each edge of frames: N for all edges, or TOP,BOTTOM,LEFT,RIGHT (e.g. 8,8,0,0)"
+
+// Returns a Display cropping the overscan given by the -overscan flag, and
+// correcting the aspect ratio if aspect is true, or nil if neither is needed.
+func newDisplay(overscan string, aspect bool) (*nes.Display, error) {
+	display := &nes.Display{AspectCorrection: aspect}
+
+	if overscan != "" {
+		var fields []string = strings.Split(overscan, ",")
+		if len(fields) != 1 && len(fields) != 4 {
+			return nil, fmt.Errorf("invalid overscan %q", overscan)
+		}
+
+		var edges [4]int
+		for i := range edges {
+			n, err := strconv.Atoi(fields[i%len(fields)])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid overscan %q", overscan)
+			}
+			edges[i] = n
+		}
+
+		display.Overscan = nes.Overscan{Top: edges[0], Bottom: edges[1], Left: edges[2], Right: edges[3]}
+
+		if width, height := display.Size(); width < 1 || height < 1 {
+			return nil, fmt.Errorf("overscan %q crops the whole frame", overscan)
+		}
+	}
+
+	if display.Overscan == (nes.Overscan{}) && !aspect {
+		return nil, nil
+	}
+
+	return display, nil
+}
+
+// Creates the file filename, and writes to it with write.
+func writeFile(filename string, write func(w io.Writer) error) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
 }
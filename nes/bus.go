@@ -0,0 +1,161 @@
+package nes
+
+// A Bus connects the CPU to memory and memory mapped devices.
+//
+// The CPU performs all memory accesses through its Bus, so the 6502 core can
+// be reused with address decoding other than the NES memory map (e.g. test
+// harnesses, or a plain 64k RAM 6502 system).
+type Bus interface {
+	// Read reads a byte from address. Reads may have side effects (e.g.
+	// reading the PPU status register clears the VBlank flag).
+	Read(address uint16) byte
+
+	// Write writes a byte to address. Returns the number of extra CPU
+	// cycles consumed by the write (e.g. by DMA), normally 0.
+	Write(address uint16, value byte) int
+
+	// Peek reads a byte from address without any side effects.
+	Peek(address uint16) byte
+
+	// IRQ returns true if the IRQ line is asserted.
+	IRQ() bool
+}
+
+// CPUBus implements the NES CPU memory map.
+//
+// The data bus retains the last value read or written. Reads from unmapped
+// addresses return this value (open bus).
+//
+// http://wiki.nesdev.com/w/index.php/CPU_memory_map
+// http://wiki.nesdev.com/w/index.php/Open_bus_behavior
+type CPUBus struct {
+	console *Console
+	openBus byte
+}
+
+// NewCPUBus returns a Bus implementing the NES CPU memory map for console.
+func NewCPUBus(console *Console) *CPUBus {
+	return &CPUBus{console: console}
+}
+
+// Read reads a byte from the CPU address space.
+func (b *CPUBus) Read(address uint16) byte {
+	var result byte
+	var c *Console = b.console
+
+	switch {
+	case address < 0x2000:
+		result = c.CPU.RAM[address&0x7FF]
+	case address >= 0x2000 && address < 0x4000:
+		c.syncPPU()
+		result = c.PPU.ReadRegister(address)
+	case address == 0x4016:
+		result = b.openBus&0xE0 | c.Joypads[0].Read()
+	case address == 0x4017:
+		result = b.openBus&0xE0 | c.Joypads[1].Read()
+	case address >= 0x6000 && address <= 0xFFFF:
+		result = c.Cart.Read(address, false)
+	default:
+		// log.Printf("Unimplemented CPU mem read @ %x", address)
+		result = b.openBus
+	}
+
+	b.openBus = result
+
+	if c.Debugger != nil {
+		c.Debugger.cpuAccess(address, result, AccessRead)
+	}
+
+	if c.CDL != nil {
+		c.CDL.cpuRead(address, result)
+	}
+
+	return result
+}
+
+// Write writes a byte to the CPU address space.
+//
+// Writes to $4014 perform OAM DMA, which takes 512 extra CPU cycles.
+func (b *CPUBus) Write(address uint16, value byte) int {
+	cycles := 0
+	var c *Console = b.console
+
+	b.openBus = value
+
+	if c.Debugger != nil {
+		c.Debugger.cpuAccess(address, value, AccessWrite)
+	}
+
+	if c.CDL != nil && address >= 0x4012 && address <= 0x4015 {
+		c.CDL.apuWrite(address, value)
+	}
+
+	if c.EventViewer != nil {
+		c.EventViewer.write(address, value)
+	}
+
+	switch {
+	case address < 0x2000:
+		c.CPU.RAM[address&0x7FF] = value
+	case address >= 0x2000 && address < 0x4000:
+		c.syncPPU()
+		c.PPU.WriteRegister(address, value)
+	case address == 0x4016:
+		c.Joypads[0].Write(value)
+	case address == 0x4017:
+		c.Joypads[1].Write(value)
+	case address == 0x4014:
+		c.PPU.SetSPRAddress(0)
+		var i uint16
+		for i = 0; i < 0x100; i++ {
+			sprValue := b.Read(uint16(value)*0x100 + i)
+			c.PPU.WriteSPR(sprValue)
+		}
+		cycles = 512
+	case address >= 0x6000 && address < 0x8000:
+		c.Cart.Write(address, value, false)
+	case address >= 0x8000 && address <= 0xFFFF:
+		c.Cart.Write(address, value, false)
+	default:
+		// log.Printf("Unimplemented CPU mem write @ %x", address)
+	}
+
+	return cycles
+}
+
+// Peek reads a byte from the CPU address space without side effects.
+//
+// PPU registers and joypads return the value a Read would, but their state is
+// left unchanged.
+func (b *CPUBus) Peek(address uint16) byte {
+	var result byte
+	var c *Console = b.console
+
+	switch {
+	case address < 0x2000:
+		result = c.CPU.RAM[address&0x7FF]
+	case address >= 0x2000 && address < 0x4000:
+		result = c.PPU.PeekRegister(address)
+	case address == 0x4016:
+		result = b.openBus&0xE0 | c.Joypads[0].Peek()
+	case address == 0x4017:
+		result = b.openBus&0xE0 | c.Joypads[1].Peek()
+	case address >= 0x6000 && address <= 0xFFFF:
+		result = c.Cart.Peek(address, false)
+	default:
+		result = b.openBus
+	}
+
+	return result
+}
+
+// IRQ returns true if the cartridge is asserting the IRQ line.
+func (b *CPUBus) IRQ() bool {
+	var irq bool = b.console.Cart.IRQ()
+
+	if irq && b.console.EventViewer != nil {
+		b.console.EventViewer.irq()
+	}
+
+	return irq
+}
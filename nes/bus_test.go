@@ -0,0 +1,113 @@
+package nes
+
+import (
+	"testing"
+)
+
+// newTestConsole returns a Console with an NROM cartridge containing program
+// at $8000. The reset vector points to $8000.
+func newTestConsole(program []byte) *Console {
+	cart := NewCartridge(2, 1, 1)
+	copy(cart.PRG[0], program)
+	cart.PRG[1][0x3FFC] = 0x00
+	cart.PRG[1][0x3FFD] = 0x80
+	cart.Mapper = NewMapper0(cart)
+
+	return NewConsole(cart)
+}
+
+func TestCPUBusPeekHasNoSideEffects(t *testing.T) {
+	console := newTestConsole(nil)
+	console.PPU.flagVBlankOutstanding = true
+	console.PPU.w = 1
+
+	if console.Bus.Peek(0x2002)&0x80 == 0 {
+		t.Fatalf("Peek($2002) missing VBlank flag\n")
+	}
+
+	if !console.PPU.flagVBlankOutstanding || console.PPU.w != 1 {
+		t.Fatalf("Peek($2002) modified PPU state\n")
+	}
+
+	if console.Bus.Read(0x2002)&0x80 == 0 || console.PPU.flagVBlankOutstanding {
+		t.Fatalf("Read($2002) did not clear VBlank flag\n")
+	}
+
+	console.PPU.v = 0x2000
+	console.PPU.readBuffer = 0x42
+	if console.Bus.Peek(0x2007) != 0x42 || console.PPU.v != 0x2000 {
+		t.Fatalf("Peek($2007) modified PPU state\n")
+	}
+
+	console.Joypads[0].A = true
+	console.Joypads[0].B = true
+	console.Joypads[0].Write(0)
+	for i := 0; i < 2; i++ {
+		if console.Bus.Peek(0x4016)&0x1 != 0x1 {
+			t.Fatalf("Peek($4016)=%x, expected bit 0 set\n", console.Bus.Peek(0x4016))
+		}
+	}
+}
+
+func TestCPUBusPeekUnmapped(t *testing.T) {
+	console := newTestConsole(nil)
+
+	if console.Cart.Peek(0x3000, true) != 0 {
+		t.Fatalf("Peek of unmapped PPU address returned non-zero\n")
+	}
+
+	if console.Cart.Peek(0x5000, false) != 0 {
+		t.Fatalf("Peek of unmapped CPU address returned non-zero\n")
+	}
+}
+
+func TestCPUBusOpenBus(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xAD, 0x16, 0x40, // LDA $4016
+		0xAD, 0x00, 0x50, // LDA $5000
+	})
+
+	console.Joypads[0].A = true
+	console.Joypads[0].Write(0)
+
+	// The high byte of the operand is left on the data bus.
+	console.CPU.Step()
+	if console.CPU.A != 0x41 {
+		t.Fatalf("LDA $4016: A=%02X, expected 41\n", console.CPU.A)
+	}
+
+	console.CPU.Step()
+	if console.CPU.A != 0x50 {
+		t.Fatalf("LDA $5000: A=%02X, expected 50\n", console.CPU.A)
+	}
+}
+
+func TestPPUIOLatch(t *testing.T) {
+	console := newTestConsole(nil)
+	ppu := console.PPU
+	ppu.flagVBlankOutstanding = true
+
+	console.Bus.Write(0x2000, 0x1F)
+	if value := console.Bus.Read(0x2002); value != 0x9F {
+		t.Fatalf("Read($2002)=%02X, expected 9F\n", value)
+	}
+
+	// Reading a write only register returns the latch.
+	if value := console.Bus.Read(0x2005); value != 0x9F {
+		t.Fatalf("Read($2005)=%02X, expected 9F\n", value)
+	}
+
+	// Bits 7-5 were refreshed by the $2002 read, so decay after bits 4-0.
+	ppu.Frame += ioLatchDecayFrames
+	ppu.flagVBlankOutstanding = true
+	console.Bus.Read(0x2002)
+	ppu.Frame += 1
+	if value := console.Bus.Peek(0x2000); value != 0x80 {
+		t.Fatalf("Peek($2000)=%02X, expected 80 after decay\n", value)
+	}
+
+	ppu.Frame += ioLatchDecayFrames
+	if value := console.Bus.Peek(0x2000); value != 0x00 {
+		t.Fatalf("Peek($2000)=%02X, expected 00 after decay\n", value)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"math/rand"
 	"os"
 )
 
@@ -25,6 +26,19 @@ type Cartridge struct {
 	PRG    [][]byte // [bank][byte], 16k banks.
 	CHR    [][]byte // [bank][byte], 8k banks.
 	SRAM   [][]byte // [bank][byte], 8k banks.
+
+	// True if CHR is RAM rather than ROM.
+	CHRRAM bool
+
+	// Region the cartridge was made for, from an NES 2.0 header. iNES 1.0
+	// files are assumed to be NTSC.
+	Region Region
+
+	// Mapper ID and nametable mirroring from the header, or -1 if the
+	// cartridge wasn't read from a file (the mapper is then set by the
+	// caller, and kept on power cycling).
+	mapperID     int
+	headerMirror MirrorType
 }
 
 // LoadCartridge opens and reads an iNES format ROM file.
@@ -47,12 +61,13 @@ func LoadCartridge(filename string) (*Cartridge, error) {
 // NewCartridge constructs an empty Cartridge with the given memory bank sizes.
 //
 // PRG banks are 16k bytes, CHR and SRAM banks are both 8k bytes. No mapper is
-// set.
+// set. If numCHRBanks is 0, a single bank of CHR RAM is created.
 func NewCartridge(numPRGBanks int, numCHRBanks int, numSRAMBanks int) *Cartridge {
-	var c *Cartridge = &Cartridge{}
+	var c *Cartridge = &Cartridge{mapperID: -1}
 
 	if numCHRBanks == 0 {
 		numCHRBanks = 1
+		c.CHRRAM = true
 	}
 
 	if numSRAMBanks == 0 {
@@ -78,7 +93,8 @@ func NewCartridge(numPRGBanks int, numCHRBanks int, numSRAMBanks int) *Cartridge
 	return c
 }
 
-// ReadCartridge reads an iNES v1.0 ROM file from file.
+// ReadCartridge reads an iNES v1.0 ROM file from file. The region is read
+// from NES 2.0 headers.
 //
 // http://wiki.nesdev.com/w/index.php/INES
 func ReadCartridge(file io.Reader) (*Cartridge, error) {
@@ -90,7 +106,9 @@ func ReadCartridge(file io.Reader) (*Cartridge, error) {
 		Control1     byte
 		Control2     byte
 		NumSRAMBanks byte
-		_            [7]byte
+		_            [3]byte
+		Timing       byte // NES 2.0 only.
+		_            [3]byte
 	}
 
 	var header iNESHeader
@@ -153,6 +171,18 @@ func ReadCartridge(file io.Reader) (*Cartridge, error) {
 		}
 	}
 
+	// NES 2.0 CPU/PPU timing: NTSC, PAL, multiple region or Dendy.
+	//
+	// http://wiki.nesdev.com/w/index.php/NES_2.0#CPU.2FPPU_Timing
+	if header.Control2&0x0C == 0x08 {
+		switch header.Timing & 0x3 {
+		case 1:
+			cart.Region = RegionPAL
+		case 3:
+			cart.Region = RegionDendy
+		}
+	}
+
 	mapper_id := int((header.Control1 >> 4) | (header.Control2 & 0xf0))
 
 	// For nestest.nes.
@@ -165,9 +195,36 @@ func ReadCartridge(file io.Reader) (*Cartridge, error) {
 		return nil, err
 	}
 
+	cart.mapperID = mapper_id
+	cart.headerMirror = cart.Mirror
+
 	return cart, nil
 }
 
+// Fills SRAM and CHR RAM with pattern, and returns the mapper to its power-on
+// state by recreating it.
+func (cart *Cartridge) powerOn(pattern RAMPattern, random *rand.Rand) {
+	for _, bank := range cart.SRAM {
+		pattern.fill(bank, random)
+	}
+
+	if cart.CHRRAM {
+		for _, bank := range cart.CHR {
+			pattern.fill(bank, random)
+		}
+	}
+
+	if cart.mapperID == -1 {
+		return
+	}
+
+	// NewMapper can't fail, as it succeeded when the cartridge was read.
+	if mapper, err := NewMapper(cart.mapperID, cart); err == nil {
+		cart.Mirror = cart.headerMirror
+		cart.Mapper = mapper
+	}
+}
+
 // Read reads a byte from the cartridge.
 //
 // address is the location to read from. Set isPPU to read from the PPU address
@@ -176,6 +233,30 @@ func (cart *Cartridge) Read(address uint16, isPPU bool) byte {
 	return cart.Mapper.Read(address, isPPU)
 }
 
+// Peek reads a byte from the cartridge without side effects.
+//
+// Unmapped addresses read as 0.
+func (cart *Cartridge) Peek(address uint16, isPPU bool) byte {
+	return cart.Mapper.Peek(address, isPPU)
+}
+
+// PRGOffset returns the offset into the PRG ROM mapped at CPU address, or -1
+// if the address isn't mapped to PRG ROM.
+//
+// The offset counts from the start of the first PRG bank, so the bank number
+// is PRGOffset(address) / 16384.
+func (cart *Cartridge) PRGOffset(address uint16) int {
+	return cart.Mapper.PRGOffset(address)
+}
+
+// CHROffset returns the offset into CHR memory mapped at PPU address, or -1 if
+// the address isn't mapped to CHR memory.
+//
+// The offset counts from the start of the first CHR bank.
+func (cart *Cartridge) CHROffset(address uint16) int {
+	return cart.Mapper.CHROffset(address)
+}
+
 // Write writes a byte to the cartridge.
 //
 // address is the location to write to. Set isPPU to write to the PPU address
@@ -0,0 +1,221 @@
+package nes
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Code/data log flags for PRG ROM bytes, as used in FCEUX .cdl files.
+//
+// http://fceux.com/web/help/CodeDataLogger.html
+const (
+	CDLCode         = 0x01 // Executed as code.
+	CDLData         = 0x02 // Read as data.
+	CDLBankMask     = 0x0C // CPU address bits 13-14 when last accessed.
+	CDLIndirectCode = 0x10 // Executed as the target of a JMP (addr).
+	CDLIndirectData = 0x20 // Read as data through a (zp,X) or (zp),Y pointer.
+	CDLPCM          = 0x40 // Played as a DMC sample.
+)
+
+// Code/data log flags for CHR bytes, as used in FCEUX .cdl files.
+const (
+	CDLDrawn = 0x01 // Fetched by the PPU for rendering.
+	CDLRead  = 0x02 // Read by the CPU through $2007.
+)
+
+// A CodeDataLogger records how each byte of a cartridge's PRG ROM and CHR ROM
+// is used, e.g. executed as code or read as data. Code/data logs are used to
+// separate code from data when disassembling a cartridge.
+//
+// Logs are saved in FCEUX .cdl format: a byte of flags for each byte of PRG
+// ROM, followed by a byte for each byte of CHR ROM (none if the cartridge has
+// CHR RAM).
+//
+// The console has no APU, so DMC samples are logged when the DMC channel is
+// enabled through $4015, using the sample address and length last written to
+// $4012 and $4013.
+type CodeDataLogger struct {
+	// Flags for each byte of PRG and CHR ROM.
+	PRG []byte
+	CHR []byte
+
+	console *Console
+
+	// Location of the instruction being executed, and its flags.
+	fetched    bool
+	fetchStart uint16
+	fetchEnd   uint16
+	codeFlags  byte
+	mode       AddressingMode
+
+	// True if the previous instruction was JMP (addr).
+	indirectJump bool
+
+	dmcAddress uint16
+	dmcLength  int
+}
+
+// NewCodeDataLogger returns an empty CodeDataLogger, and attaches it to
+// console. Logging stops when console.CDL is set to nil.
+func NewCodeDataLogger(console *Console) *CodeDataLogger {
+	var cart *Cartridge = console.Cart
+
+	l := &CodeDataLogger{
+		PRG:        make([]byte, len(cart.PRG)*0x4000),
+		console:    console,
+		dmcAddress: 0xC000,
+		dmcLength:  1,
+	}
+
+	if !cart.CHRRAM {
+		l.CHR = make([]byte, len(cart.CHR)*0x2000)
+	}
+
+	console.CDL = l
+
+	return l
+}
+
+// Load reads a log in .cdl format, replacing the current log.
+func (l *CodeDataLogger) Load(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) != len(l.PRG)+len(l.CHR) {
+		return fmt.Errorf("code/data log is %d bytes, expected %d for this cartridge",
+			len(data), len(l.PRG)+len(l.CHR))
+	}
+
+	copy(l.PRG, data)
+	copy(l.CHR, data[len(l.PRG):])
+
+	return nil
+}
+
+// Save writes the log in .cdl format.
+func (l *CodeDataLogger) Save(w io.Writer) error {
+	if _, err := w.Write(l.PRG); err != nil {
+		return err
+	}
+
+	_, err := w.Write(l.CHR)
+	return err
+}
+
+// LoadFile reads the .cdl file filename, replacing the current log.
+func (l *CodeDataLogger) LoadFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = l.Load(file); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return nil
+}
+
+// SaveFile writes the log to the .cdl file filename.
+func (l *CodeDataLogger) SaveFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = l.Save(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Called before each CPU instruction.
+func (l *CodeDataLogger) step() {
+	l.fetched = false
+}
+
+// Called for each CPU bus read.
+//
+// The first read of the current PC in a step is the opcode fetch (after any
+// IRQ has been taken), and reads of the following bytes of the instruction
+// before the PC moves are operand fetches. Other reads are data.
+func (l *CodeDataLogger) cpuRead(address uint16, value byte) {
+	var cpu *CPU = l.console.CPU
+	var flags byte
+
+	switch {
+	case !l.fetched && address == cpu.PC:
+		l.fetched = true
+		l.fetchStart = address
+		l.fetchEnd = address + uint16(opcodes[value].Size)
+		l.mode = opcodes[value].Mode
+
+		l.codeFlags = CDLCode
+		if l.indirectJump {
+			l.codeFlags |= CDLIndirectCode
+		}
+		l.indirectJump = value == 0x6C
+
+		flags = l.codeFlags
+	case cpu.PC == l.fetchStart && address > l.fetchStart && address < l.fetchEnd:
+		flags = l.codeFlags
+	case l.mode == IndirectX || l.mode == IndirectY:
+		flags = CDLData | CDLIndirectData
+	default:
+		flags = CDLData
+	}
+
+	l.markPRG(address, flags)
+}
+
+// Marks the PRG ROM byte mapped at CPU address, replacing its bank bits.
+func (l *CodeDataLogger) markPRG(address uint16, flags byte) {
+	offset := l.console.Cart.PRGOffset(address)
+	if offset < 0 || offset >= len(l.PRG) {
+		return
+	}
+
+	l.PRG[offset] = l.PRG[offset]&^CDLBankMask | flags | byte(address>>11)&CDLBankMask
+}
+
+// Called for CPU writes to the APU registers.
+func (l *CodeDataLogger) apuWrite(address uint16, value byte) {
+	switch address {
+	case 0x4012:
+		l.dmcAddress = 0xC000 + uint16(value)*64
+	case 0x4013:
+		l.dmcLength = int(value)*16 + 1
+	case 0x4015:
+		if value&0x10 == 0 {
+			break
+		}
+
+		// Sample addresses wrap from $FFFF to $8000.
+		var address uint16 = l.dmcAddress
+		for i := 0; i < l.dmcLength; i++ {
+			l.markPRG(address, CDLPCM)
+
+			address++
+			if address == 0 {
+				address = 0x8000
+			}
+		}
+	}
+}
+
+// Called for PPU reads of CHR memory, by the renderer (CDLDrawn) or through
+// $2007 (CDLRead).
+func (l *CodeDataLogger) chrAccess(address uint16, flags byte) {
+	offset := l.console.Cart.CHROffset(address)
+	if offset < 0 || offset >= len(l.CHR) {
+		return
+	}
+
+	l.CHR[offset] |= flags
+}
@@ -0,0 +1,104 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodeDataLogger(t *testing.T) {
+	program := []byte{
+		0xAD, 0x20, 0x80, // $8000 LDA $8020
+		0xA9, 0x30, //       $8003 LDA #$30
+		0x85, 0x00, //       $8005 STA $00
+		0xA9, 0x80, //       $8007 LDA #$80
+		0x85, 0x01, //       $8009 STA $01
+		0xA0, 0x00, //       $800B LDY #$00
+		0xB1, 0x00, //       $800D LDA ($00),Y
+		0x4C, 0x0F, 0x80, // $800F JMP $800F
+	}
+
+	console := newTestConsole(program)
+	logger := NewCodeDataLogger(console)
+
+	if len(logger.PRG) != 0x8000 || len(logger.CHR) != 0x2000 {
+		t.Fatalf("got %d PRG and %d CHR bytes, expected 32768 and 8192\n", len(logger.PRG), len(logger.CHR))
+	}
+
+	for i := 0; i < 9; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Literal values, as in FCEUX's xPdcAADC layout.
+	expected := map[int]byte{
+		0x00: 0x01,
+		0x02: 0x01,
+		0x0D: 0x01,
+		0x11: 0x01,
+		0x12: 0x00,
+		0x20: 0x02,
+		0x30: 0x22,
+	}
+
+	for offset, flags := range expected {
+		if logger.PRG[offset] != flags {
+			t.Errorf("PRG offset $%04X has flags $%02X, expected $%02X\n", offset, logger.PRG[offset], flags)
+		}
+	}
+
+	// Bank bits of the CPU address, and CHR reads through $2007.
+	console.CPU.PC = 0xC000
+	logger.step()
+	logger.cpuRead(0xC000, 0xEA)
+	if logger.PRG[0x4000] != 0x09 {
+		t.Errorf("PRG offset $4000 has flags $%02X, expected $09\n", logger.PRG[0x4000])
+	}
+
+	// The bank bits are those of the last access, here to a 16k PRG ROM
+	// mirrored at $8000 and $C000.
+	mirrored := NewCartridge(1, 1, 1)
+	mirrored.Mapper = NewMapper0(mirrored)
+	mirroredLogger := NewCodeDataLogger(NewConsole(mirrored))
+	mirroredLogger.markPRG(0xC000, CDLData)
+	mirroredLogger.markPRG(0x8000, CDLData)
+	if mirroredLogger.PRG[0] != 0x02 {
+		t.Errorf("PRG offset $0000 has flags $%02X after access at $8000, expected $02\n", mirroredLogger.PRG[0])
+	}
+
+	console.PPU.v = 0x0123
+	console.PPU.ReadData()
+	if logger.CHR[0x123] != 0x02 {
+		t.Errorf("CHR offset $0123 has flags $%02X, expected $02\n", logger.CHR[0x123])
+	}
+
+	// DMC samples, from $C000 + 1*64 for 1*16 + 1 bytes.
+	console.Bus.Write(0x4012, 1)
+	console.Bus.Write(0x4013, 1)
+	console.Bus.Write(0x4015, 0x10)
+	if logger.PRG[0x4040]&CDLPCM == 0 || logger.PRG[0x4050]&CDLPCM == 0 || logger.PRG[0x4051] != 0 {
+		t.Errorf("DMC sample not logged at PRG offsets $4040-$4050\n")
+	}
+
+	var buffer bytes.Buffer
+	if err := logger.Save(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if buffer.Len() != 0xA000 {
+		t.Fatalf("saved %d bytes, expected 40960\n", buffer.Len())
+	}
+
+	loaded := NewCodeDataLogger(newTestConsole(nil))
+	if err := loaded.Load(bytes.NewReader(buffer.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(loaded.PRG, logger.PRG) || !bytes.Equal(loaded.CHR, logger.CHR) {
+		t.Errorf("loaded log differs from saved log\n")
+	}
+
+	if err := loaded.Load(bytes.NewReader(buffer.Bytes()[:0x8000])); err == nil {
+		t.Errorf("loaded log of the wrong size\n")
+	}
+}
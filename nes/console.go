@@ -2,30 +2,66 @@ package nes
 
 import (
 	"image"
+	"math/rand"
 	"time"
 )
 
-// Number of video frames per second.
-const framesPerSecond = 60
-
 // Console represents a NES console and its main hardware components (the
 // cartridge, CPU, PPU, and joypads).
+//
+// The CPU is connected to the other components through Bus, which implements
+// the NES CPU memory map.
 type Console struct {
 	Cart    *Cartridge
+	Bus     *CPUBus
 	CPU     *CPU
 	PPU     *PPU
 	Joypads [2]*Joypad
 
+	// Debugger, if attached with NewDebugger.
+	Debugger *Debugger
+
+	// Tracer, if attached with NewTracer.
+	Tracer *Tracer
+
+	// Code/data logger, if attached with NewCodeDataLogger.
+	CDL *CodeDataLogger
+
+	// Profiler, if attached with NewProfiler.
+	Profiler *Profiler
+
+	// Event viewer, if attached with NewEventViewer.
+	EventViewer *EventViewer
+
+	// Power-on state set by PowerCycle: the contents of RAM (CPU RAM, VRAM,
+	// palette RAM, OAM and cartridge PRG and CHR RAM), and whether the
+	// CPU/PPU clock alignment is random. RAM is zeroed by NewConsole.
+	RAMPattern      RAMPattern
+	RandomAlignment bool
+
+	// Seed for RAMRandom and RandomAlignment, used from the first
+	// PowerCycle.
+	Seed int64
+
+	random *rand.Rand
+
+	// Frame completed by the PPU during the current step, if any.
+	frame *image.RGBA
+
+	region Region
+
 	lastFrameStart time.Time
 	frameDuration  time.Duration
 	frameCount     uint64
 }
 
-// NewConsole returns a Console initialised with cart.
+// NewConsole returns a Console initialised with cart, for the cartridge's
+// region.
 func NewConsole(cart *Cartridge) *Console {
 	c := &Console{}
 	c.Cart = cart
-	c.CPU = NewCPU(c)
+	c.Bus = NewCPUBus(c)
+	c.CPU = NewCPU(c.Bus)
 	c.PPU = NewPPU(c)
 
 	for i := range c.Joypads {
@@ -33,48 +69,129 @@ func NewConsole(cart *Cartridge) *Console {
 	}
 
 	c.lastFrameStart = time.Now()
-	c.frameDuration = time.Second / framesPerSecond
+	c.SetRegion(cart.Region)
 
 	return c
 }
 
+// Region returns the console's region.
+func (c *Console) Region() Region {
+	return c.region
+}
+
+// SetRegion sets the console's region, which sets its timing and frame rate.
+// PAL and Dendy PPUs swap the red and green emphasis bits, so
+// PPU.SwapEmphasis is set for them.
+//
+// Set the region before running the console.
+func (c *Console) SetRegion(region Region) {
+	c.region = region
+	c.frameDuration = time.Duration(float64(time.Second) / region.FrameRate())
+	c.PPU.SwapEmphasis = region != RegionNTSC
+}
+
+// Reset presses the console's reset button. The CPU performs its reset
+// sequence, jumping through the reset vector, and the PPU's registers are
+// cleared. RAM and the mapper's state are kept.
+func (c *Console) Reset() {
+	c.CPU.reset()
+	c.PPU.reset()
+}
+
+// PowerCycle switches the console off and on again. RAM is filled with
+// RAMPattern, and the CPU, PPU and mapper return to their power-on state. If
+// RandomAlignment is set, the PPU starts 0-2 dots into a CPU cycle at random,
+// as the clocks of real consoles power on unaligned. The attached debugging
+// tools are kept.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_frame_timing#CPU-PPU_Clock_Alignment
+func (c *Console) PowerCycle() {
+	if c.random == nil {
+		c.random = rand.New(rand.NewSource(c.Seed))
+	}
+
+	var alignment int
+	if c.RandomAlignment {
+		alignment = c.random.Intn(3)
+	}
+
+	c.Cart.powerOn(c.RAMPattern, c.random)
+	c.PPU.powerOn(c.RAMPattern, c.random, alignment)
+	c.CPU.powerOn(c.RAMPattern, c.random)
+}
+
 // Step runs the Console for 1 CPU instruction. The PPU runs at the same time.
 //
 // Call Step() repeatedly to simulate the Console. For the majority of calls,
-// Step() returns a nil *image.RGBA. Approximately 60 times a second the PPU
-// emits a new image frame, and a non-nil *image.RGBA is returned. The image is
-// 256x240px.
+// Step() returns a nil *image.RGBA. Once a frame (around 60 times a second for
+// NTSC, or 50 for PAL and Dendy) the PPU emits a new image frame, and a non-nil
+// *image.RGBA is returned. The image is 256x240px.
 //
 // To regulate emulation speed, Step() may sleep when emitting an image. It
-// sleeps to regulate the output to around 60 frames per second (as per NTSC).
+// sleeps to regulate the output to the region's frame rate.
 func (c *Console) Step() (*image.RGBA, error) {
 	var cpuCycles uint64
-	var ppuCycles uint64
+
+	if c.Tracer != nil {
+		if err := c.Tracer.trace(); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.CDL != nil {
+		c.CDL.step()
+	}
+
+	if c.Profiler != nil {
+		c.Profiler.beforeStep()
+	}
 
 	cpuCycles, err := c.CPU.Step()
 	if err != nil {
 		return nil, err
 	}
 
-	for ppuCycles < cpuCycles*3 {
-		var image *image.RGBA
-		ppuCycles, image = c.PPU.Step()
+	if c.Profiler != nil {
+		c.Profiler.afterStep()
+	}
 
-		if image != nil {
-			c.frameCount++
+	c.runPPU(c.region.ppuCycles(cpuCycles))
+
+	var image *image.RGBA = c.frame
+	c.frame = nil
 
-			// Regulate frames per second.
-			expectedTime := c.lastFrameStart.Add(c.frameDuration)
-			actualTime := time.Now()
-			sleepDuration := expectedTime.Sub(actualTime)
+	if image != nil {
+		c.frameCount++
 
-			time.Sleep(sleepDuration)
+		// Regulate frames per second.
+		expectedTime := c.lastFrameStart.Add(c.frameDuration)
+		actualTime := time.Now()
+		sleepDuration := expectedTime.Sub(actualTime)
 
-			c.lastFrameStart = time.Now()
+		time.Sleep(sleepDuration)
 
-			return image, nil
+		c.lastFrameStart = time.Now()
+	}
+
+	return image, nil
+}
+
+// Runs the PPU until it has run the given number of cycles in its lifetime.
+func (c *Console) runPPU(cycles uint64) {
+	for c.PPU.numCycles < cycles {
+		_, image := c.PPU.Step()
+
+		if image != nil {
+			c.frame = image
 		}
 	}
+}
 
-	return nil, nil
+// Runs the PPU up to the CPU's current bus access, so the PPU registers are
+// accessed on the right tick. The CPU runs whole instructions at a time, and
+// the PPU otherwise catches up afterwards.
+//
+// The access is assumed to happen a tick into its CPU cycle.
+func (c *Console) syncPPU() {
+	c.runPPU(c.region.ppuCycles(c.CPU.accessCycle) + 1)
 }
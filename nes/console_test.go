@@ -0,0 +1,178 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConsoleReset(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xA9, 0x42, // LDA #$42
+		0x85, 0x10, // STA $10
+		0xA9, 0x80, // LDA #$80
+		0x8D, 0x00, 0x20, // STA $2000
+		0x58,             // CLI
+		0x4C, 0x0A, 0x80, // JMP $800A
+	})
+
+	for i := 0; i < 6; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cpu *CPU = console.CPU
+	var sp byte = cpu.SP
+	var cycles uint64 = cpu.NumCycles
+
+	console.Reset()
+
+	if cpu.PC != 0x8000 || cpu.SP != sp-3 || !cpu.flagInterruptDisable || cpu.NumCycles != cycles+7 {
+		t.Errorf("after reset PC=%04X SP=%02X I=%v cycles=%d, expected PC=8000 SP=%02X I=true cycles=%d\n",
+			cpu.PC, cpu.SP, cpu.flagInterruptDisable, cpu.NumCycles, sp-3, cycles+7)
+	}
+
+	if cpu.A != 0x80 || cpu.RAM[0x10] != 0x42 {
+		t.Errorf("reset changed A or RAM\n")
+	}
+
+	if console.PPU.flagNMIOnVBlank {
+		t.Errorf("reset didn't clear the control register\n")
+	}
+}
+
+func TestConsolePowerCycle(t *testing.T) {
+	console := newTestConsole(nil)
+	console.CPU.A = 0x42
+	console.CPU.RAM[0x10] = 0x42
+	console.PPU.ram[0x2000] = 0x42
+	console.PPU.SetControlRegister(0x80)
+	console.PPU.NoSpriteLimit = true
+
+	console.RAMPattern = RAMHardware
+	console.PowerCycle()
+
+	var cpu *CPU = console.CPU
+	if cpu.PC != 0x8000 || cpu.SP != 0xFD || cpu.A != 0 || cpu.P() != 0x24 {
+		t.Errorf("after power cycle PC=%04X SP=%02X A=%02X P=%02X, expected PC=8000 SP=FD A=00 P=24\n",
+			cpu.PC, cpu.SP, cpu.A, cpu.P())
+	}
+
+	var expected []byte = []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}
+	if !bytes.Equal(cpu.RAM[0x10:0x18], expected) {
+		t.Errorf("RAM is % X, expected % X\n", cpu.RAM[0x10:0x18], expected)
+	}
+
+	var ppu *PPU = console.PPU
+	if ppu.ram[0x2000] != 0 || ppu.flagNMIOnVBlank || !ppu.NoSpriteLimit {
+		t.Errorf("PPU not powered on with its settings kept\n")
+	}
+
+	if !bytes.Equal(console.Cart.SRAM[0][0x10:0x18], expected) || !bytes.Equal(ppu.sprRAM[0x10:0x18], expected) {
+		t.Errorf("cartridge RAM or OAM not filled with the pattern\n")
+	}
+}
+
+// Returns a console with an MMC3 (mapper 4) cartridge read from an iNES file,
+// whose 8k PRG banks start with $A0-$A3.
+func newMapper4TestConsole(t *testing.T) *Console {
+	var rom []byte = make([]byte, 16+2*16384+8192)
+	copy(rom, "NES\x1a\x02\x01\x40")
+
+	var prg []byte = rom[16 : 16+2*16384]
+	for bank := 0; bank < 4; bank++ {
+		prg[bank*0x2000] = 0xA0 + byte(bank)
+	}
+	prg[0x7FFC] = 0x00
+	prg[0x7FFD] = 0x80
+
+	cart, err := ReadCartridge(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewConsole(cart)
+}
+
+func TestConsolePowerCycleMapper4(t *testing.T) {
+	console := newMapper4TestConsole(t)
+	var bus *CPUBus = console.Bus
+
+	// Switch PRG bank 1 in at $8000, and write to PRG RAM.
+	bus.Write(0x8000, 0x06)
+	bus.Write(0x8001, 0x01)
+	bus.Write(0x6004, 0x42)
+
+	if bus.Read(0x8000) != 0xA1 || console.Cart.SRAM[0][4] != 0x42 {
+		t.Fatalf("bank switch or PRG RAM write failed\n")
+	}
+
+	console.RAMPattern = RAMHardware
+	console.PowerCycle()
+
+	if value := bus.Read(0x8000); value != 0xA0 {
+		t.Errorf("$8000 is $%02X after power cycle, expected $A0 from the power-on bank\n", value)
+	}
+
+	// PRG RAM is the cartridge's SRAM, which survives recreating the mapper.
+	if value := bus.Read(0x6004); value != console.Cart.SRAM[0][4] {
+		t.Errorf("$6004 is $%02X after power cycle, expected SRAM's $%02X\n", value, console.Cart.SRAM[0][4])
+	}
+}
+
+func TestConsolePowerOnCartridgeRAM(t *testing.T) {
+	tests := []struct {
+		pattern  RAMPattern
+		expected []byte // $6000-$6007.
+	}{
+		{RAMZeros, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{RAMOnes, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{RAMHardware, []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+
+	for _, test := range tests {
+		console := newMapper4TestConsole(t)
+		console.Bus.Write(0x6000, 0x42)
+
+		console.RAMPattern = test.pattern
+		console.PowerCycle()
+
+		var ram []byte = make([]byte, 8)
+		for i := range ram {
+			ram[i] = console.Bus.Read(0x6000 + uint16(i))
+		}
+
+		if !bytes.Equal(ram, test.expected) {
+			t.Errorf("pattern %d: PRG RAM is % X, expected % X\n", test.pattern, ram, test.expected)
+		}
+	}
+}
+
+func TestConsolePowerOnRandom(t *testing.T) {
+	// The same seed gives the same RAM and alignment.
+	var ram [2][2048]byte
+	var ticks [2]int
+
+	for i := range ram {
+		console := newTestConsole(nil)
+		console.RAMPattern = RAMRandom
+		console.RandomAlignment = true
+		console.Seed = 42
+		console.PowerCycle()
+
+		ram[i] = console.CPU.RAM
+		ticks[i] = console.PPU.Tick
+	}
+
+	if ram[0] != ram[1] || ticks[0] != ticks[1] {
+		t.Errorf("power-on state differs with the same seed\n")
+	}
+
+	if ram[0] == [2048]byte{} {
+		t.Errorf("RAM not randomised\n")
+	}
+
+	if ticks[0] < 0 || ticks[0] > 2 {
+		t.Errorf("PPU started on tick %d, expected 0-2\n", ticks[0])
+	}
+}
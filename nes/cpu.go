@@ -2,7 +2,7 @@ package nes
 
 import (
 	"fmt"
-	"log"
+	"math/rand"
 )
 
 // Interrupt vectors && stack base address.
@@ -15,8 +15,8 @@ const NMIVector uint16 = 0xfffa
 //
 // https://web.archive.org/web/20110320213225/http://www.obelisk.demon.co.uk/6502/
 type CPU struct {
-	Console *Console
-	RAM     [2048]byte
+	Bus Bus
+	RAM [2048]byte
 
 	NumCycles uint64
 	PC        uint16
@@ -33,6 +33,17 @@ type CPU struct {
 	flagOverflow         bool
 	flagSign             bool
 
+	// Labels used to name addresses in error messages, may be nil.
+	Labels Labeler
+
+	// True if an NMI has been requested, and the cycle it was requested on.
+	nmiPending bool
+	nmiCycle   uint64
+
+	// Cycle of the current instruction's last bus access, assumed to be its
+	// last cycle.
+	accessCycle uint64
+
 	instructions [256]instruction
 }
 
@@ -47,11 +58,15 @@ type instruction struct {
 	NumBaseCycles      int
 	NumPageCrossCycles int
 	GetAddressImpl     func() (uint16, bool)
+	Mode               AddressingMode
 }
 
-// NewCPU constructs and returns a CPU for the given console.
-func NewCPU(console *Console) *CPU {
-	c := &CPU{Console: console,
+// NewCPU constructs and returns a CPU connected to bus.
+//
+// The program counter is loaded from the reset vector, so bus must be ready to
+// serve reads.
+func NewCPU(bus Bus) *CPU {
+	c := &CPU{Bus: bus,
 		SP:                   0xFD,
 		flagInterruptDisable: true}
 
@@ -61,6 +76,33 @@ func NewCPU(console *Console) *CPU {
 	return c
 }
 
+// Performs the 6502 reset sequence, which takes 7 cycles: the stack pointer is
+// decremented by 3 (without writing), interrupts are disabled, and the program
+// counter is loaded from the reset vector. The other registers and RAM are
+// kept.
+//
+// http://wiki.nesdev.com/w/index.php/CPU_power_up_state
+func (c *CPU) reset() {
+	c.SP -= 3
+	c.flagInterruptDisable = true
+	c.PC = c.read16(ResetVector)
+	c.nmiPending = false
+
+	c.NumCycles += 7
+}
+
+// Returns the registers to their power-on state, fills RAM with pattern, and
+// performs the reset sequence. The cycle counter is kept.
+func (c *CPU) powerOn(pattern RAMPattern, random *rand.Rand) {
+	c.A, c.X, c.Y = 0, 0, 0
+	c.SetP(0x24)
+	c.SP = 0x00
+
+	pattern.fill(c.RAM[:], random)
+
+	c.reset()
+}
+
 // String returns the CPU state as a string.
 func (c *CPU) String() string {
 	instructionBytes, _ := c.NextInstructionBytes()
@@ -97,8 +139,13 @@ func (c *CPU) String() string {
 func (c *CPU) Step() (uint64, error) {
 	var numCycles int = 0
 
+	if c.isNMIDue() {
+		c.nmiPending = false
+		numCycles += c.NMI()
+	}
+
 	if !c.flagInterruptDisable {
-		if c.Console.Cart.IRQ() {
+		if c.Bus.IRQ() {
 			numCycles += c.interrupt()
 		}
 	}
@@ -107,8 +154,8 @@ func (c *CPU) Step() (uint64, error) {
 	var instruction *instruction = &c.instructions[opcode]
 
 	if instruction.Size == 0 {
-		return 0, fmt.Errorf("invalid instruction %x @ PC=%x",
-			opcode, c.PC)
+		return 0, fmt.Errorf("invalid instruction %x @ PC=%s",
+			opcode, c.addressName(c.PC))
 	}
 
 	numCycles += instruction.NumBaseCycles
@@ -123,6 +170,8 @@ func (c *CPU) Step() (uint64, error) {
 		numCycles += instruction.NumPageCrossCycles
 	}
 
+	c.accessCycle = c.NumCycles + uint64(numCycles) - 1
+
 	var extraCycles int = instruction.Impl(value)
 
 	if extraCycles == -1 {
@@ -183,6 +232,21 @@ func (c *CPU) NMI() int {
 	return 7
 }
 
+// Requests a non-maskable interrupt on the given cycle, as the PPU does at the
+// start of VBlank.
+func (c *CPU) triggerNMI(cycle uint64) {
+	c.nmiPending = true
+	c.nmiCycle = cycle
+}
+
+// Returns true if a requested NMI is taken before the next instruction.
+//
+// Interrupts are polled before an instruction's last cycle, so an NMI
+// requested during the last cycle is taken after the following instruction.
+func (c *CPU) isNMIDue() bool {
+	return c.nmiPending && c.nmiCycle+1 < c.NumCycles
+}
+
 func signBitSet(value byte) bool {
 	return value&0x80 != 0
 }
@@ -495,15 +559,7 @@ func (c *CPU) pla(address uint16) int {
 }
 
 func (c *CPU) plp(address uint16) int {
-	p := c.pop8() & 0xEF
-
-	c.flagCarry = p&0x01 != 0
-	c.flagZero = p&0x02 != 0
-	c.flagInterruptDisable = p&0x04 != 0
-	c.flagDecimalMode = p&0x08 != 0
-	c.flagBreak = p&0x10 != 0
-	c.flagOverflow = p&0x40 != 0
-	c.flagSign = p&0x80 != 0
+	c.SetP(c.pop8() & 0xEF)
 
 	return 0
 }
@@ -947,361 +1003,313 @@ func (c *CPU) P() byte {
 	return p
 }
 
+// SetP sets the processor status flags from p.
+func (c *CPU) SetP(p byte) {
+	c.flagCarry = p&0x01 != 0
+	c.flagZero = p&0x02 != 0
+	c.flagInterruptDisable = p&0x04 != 0
+	c.flagDecimalMode = p&0x08 != 0
+	c.flagBreak = p&0x10 != 0
+	c.flagOverflow = p&0x40 != 0
+	c.flagSign = p&0x80 != 0
+}
+
+// Returns address in hex, or relative to a nearby label, e.g. "NMI_Handler+3".
+func (c *CPU) addressName(address uint16) string {
+	if name, ok := NearestLabel(c.Labels, address); ok {
+		return name
+	}
+
+	return fmt.Sprintf("%x", address)
+}
+
+// NextInstructionBytes returns the bytes of the instruction at PC.
+//
+// The bytes are read with Bus.Peek, so no side effects occur.
 func (c *CPU) NextInstructionBytes() ([]byte, error) {
-	var opcode byte = c.read(c.PC)
+	var opcode byte = c.Bus.Peek(c.PC)
 	var instruction *instruction = &c.instructions[opcode]
 
 	bytes := make([]byte, 0, 3)
 
 	if instruction.Size == 0 {
-		return bytes, fmt.Errorf("invalid instruction %x @ PC=%x",
-			opcode, c.PC)
+		return bytes, fmt.Errorf("invalid instruction %x @ PC=%s",
+			opcode, c.addressName(c.PC))
 	}
 
 	var i uint16
 	for i = 0; i < instruction.Size; i++ {
-		bytes = append(bytes, c.read(c.PC+i))
+		bytes = append(bytes, c.Bus.Peek(c.PC+i))
 	}
 
 	return bytes, nil
 }
 
 func (c *CPU) read(address uint16) byte {
-	var result byte
-
-	switch {
-	case address < 0x2000:
-		result = c.RAM[address&0x7FF]
-	case address >= 0x2000 && address < 0x4000:
-		switch address & 0x7 {
-		case 2:
-			result = c.Console.PPU.StatusRegister()
-		case 4:
-			result = c.Console.PPU.ReadSPR()
-		case 7:
-			result = c.Console.PPU.ReadData()
-		default:
-			log.Printf("Unknown read @ %x", address)
-		}
-	case address == 0x4016:
-		result = c.Console.Joypads[0].Read()
-	case address == 0x4017:
-		result = c.Console.Joypads[1].Read()
-	case address >= 0x6000 && address <= 0xFFFF:
-		result = c.Console.Cart.Read(address, false)
-	default:
-		// log.Printf("Unimplemented CPU mem read @ %x", address)
-		result = 0xFF
-	}
-
-	return result
+	return c.Bus.Read(address)
 }
 
 func (c *CPU) write(address uint16, value byte) int {
-	cycles := 0
-
-	switch {
-	case address < 0x2000:
-		c.RAM[address&0x7FF] = value
-	case address >= 0x2000 && address < 0x4000:
-		switch address & 0x7 {
-		case 0x0:
-			c.Console.PPU.SetControlRegister(value)
-		case 0x1:
-			c.Console.PPU.SetMaskRegister(value)
-		case 0x3:
-			c.Console.PPU.SetSPRAddress(value)
-		case 0x4:
-			c.Console.PPU.WriteSPR(value)
-		case 0x5:
-			c.Console.PPU.WriteScroll(value)
-		case 0x6:
-			c.Console.PPU.WriteDataAddress(value)
-		case 0x7:
-			c.Console.PPU.WriteData(value)
-		default:
-			log.Printf("Unknown write @ %x", address)
-		}
-	case address == 0x4016:
-		c.Console.Joypads[0].Write(value)
-	case address == 0x4017:
-		c.Console.Joypads[1].Write(value)
-	case address == 0x4014:
-		c.Console.PPU.SetSPRAddress(0)
-		var i uint16
-		for i = 0; i < 0x100; i++ {
-			sprValue := c.read(uint16(value)*0x100 + i)
-			c.Console.PPU.WriteSPR(sprValue)
-		}
-		cycles = 512
-	case address >= 0x6000 && address < 0x8000:
-		c.Console.Cart.Write(address, value, false)
-	case address >= 0x8000 && address <= 0xFFFF:
-		c.Console.Cart.Write(address, value, false)
-	default:
-		// log.Printf("Unimplemented CPU mem write @ %x", address)
-	}
-
-	return cycles
+	return c.Bus.Write(address, value)
 }
 
 func (c *CPU) loadInstructions() {
 	c.instructions = [256]instruction{
-		/* 0x00 */ {"BRK", c.brk, 1, 7, 0, c.getAddrImplied},
-		/* 0x01 */ {"ORA", c.ora, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x02 */ {"x02", c.xxx, 0, 0, 0, nil},
-		/* 0x03 */ {"SLO", c.slo, 2, 8, 0, c.getAddrIndirectX},
-		/* 0x04 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x05 */ {"ORA", c.ora, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x06 */ {"ASL", c.asl, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x07 */ {"SLO", c.slo, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x08 */ {"PHP", c.php, 1, 3, 0, c.getAddrImplied},
-		/* 0x09 */ {"ORA", c.ora, 2, 2, 0, c.getAddrImmediate},
-		/* 0x0A */ {"ASL", c.asla, 1, 2, 0, c.getAddrAccumulator},
-		/* 0x0B */ {"ANC", c.anc, 2, 2, 0, c.getAddrImmediate},
-		/* 0x0C */ {"TOP", c.top, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x0D */ {"ORA", c.ora, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x0E */ {"ASL", c.asl, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x0F */ {"SLO", c.slo, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x10 */ {"BPL", c.bpl, 2, 2, 0, c.getAddrRelative},
-		/* 0x11 */ {"ORA", c.ora, 2, 5, 1, c.getAddrIndirectY},
-		/* 0x12 */ {"x12", c.xxx, 0, 0, 0, nil},
-		/* 0x13 */ {"SLO", c.slo, 2, 8, 0, c.getAddrIndirectY},
-		/* 0x14 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x15 */ {"ORA", c.ora, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x16 */ {"ASL", c.asl, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x17 */ {"SLO", c.slo, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x18 */ {"CLC", c.clc, 1, 2, 0, c.getAddrImplied},
-		/* 0x19 */ {"ORA", c.ora, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0x1A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0x1B */ {"SLO", c.slo, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0x1C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x1D */ {"ORA", c.ora, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x1E */ {"ASL", c.asl, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x1F */ {"SLO", c.slo, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x20 */ {"JSR", c.jsr, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x21 */ {"AND", c.and, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x22 */ {"x22", c.xxx, 0, 0, 0, nil},
-		/* 0x23 */ {"RLA", c.rla, 2, 8, 0, c.getAddrIndirectX},
-		/* 0x24 */ {"BIT", c.bit, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x25 */ {"AND", c.and, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x26 */ {"ROL", c.rol, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x27 */ {"RLA", c.rla, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x28 */ {"PLP", c.plp, 1, 4, 0, c.getAddrImplied},
-		/* 0x29 */ {"AND", c.and, 2, 2, 0, c.getAddrImmediate},
-		/* 0x2A */ {"ROL", c.rola, 1, 2, 0, c.getAddrAccumulator},
-		/* 0x2B */ {"ANC", c.anc, 2, 2, 0, c.getAddrImmediate},
-		/* 0x2C */ {"BIT", c.bit, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x2D */ {"AND", c.and, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x2E */ {"ROL", c.rol, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x2F */ {"RLA", c.rla, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x30 */ {"BMI", c.bmi, 2, 2, 0, c.getAddrRelative},
-		/* 0x31 */ {"AND", c.and, 2, 5, 1, c.getAddrIndirectY},
-		/* 0x32 */ {"x32", c.xxx, 0, 0, 0, nil},
-		/* 0x33 */ {"RLA", c.rla, 2, 8, 0, c.getAddrIndirectY},
-		/* 0x34 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x35 */ {"AND", c.and, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x36 */ {"ROL", c.rol, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x37 */ {"RLA", c.rla, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x38 */ {"SEC", c.sec, 1, 2, 0, c.getAddrImplied},
-		/* 0x39 */ {"AND", c.and, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0x3A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0x3B */ {"RLA", c.rla, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0x3C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x3D */ {"AND", c.and, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x3E */ {"ROL", c.rol, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x3F */ {"RLA", c.rla, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x40 */ {"RTI", c.rti, 1, 6, 0, c.getAddrImplied},
-		/* 0x41 */ {"EOR", c.eor, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x42 */ {"x42", c.xxx, 0, 0, 0, nil},
-		/* 0x43 */ {"SRE", c.sre, 2, 8, 0, c.getAddrIndirectX},
-		/* 0x44 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x45 */ {"EOR", c.eor, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x46 */ {"LSR", c.lsr, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x47 */ {"SRE", c.sre, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x48 */ {"PHA", c.pha, 1, 3, 0, c.getAddrImplied},
-		/* 0x49 */ {"EOR", c.eor, 2, 2, 0, c.getAddrImmediate},
-		/* 0x4A */ {"LSR", c.lsra, 1, 2, 0, c.getAddrAccumulator},
-		/* 0x4B */ {"ALR", c.alr, 2, 2, 0, c.getAddrImmediate},
-		/* 0x4C */ {"JMP", c.jmp, 3, 3, 0, c.getAddrAbsolute},
-		/* 0x4D */ {"EOR", c.eor, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x4E */ {"LSR", c.lsr, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x4F */ {"SRE", c.sre, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x50 */ {"BVC", c.bvc, 2, 2, 0, c.getAddrRelative},
-		/* 0x51 */ {"EOR", c.eor, 2, 5, 1, c.getAddrIndirectY},
-		/* 0x52 */ {"x52", c.xxx, 0, 0, 0, nil},
-		/* 0x53 */ {"SRE", c.sre, 2, 8, 0, c.getAddrIndirectY},
-		/* 0x54 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x55 */ {"EOR", c.eor, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x56 */ {"LSR", c.lsr, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x57 */ {"SRE", c.sre, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x58 */ {"CLI", c.cli, 1, 2, 0, c.getAddrImplied},
-		/* 0x59 */ {"EOR", c.eor, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0x5A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0x5B */ {"SRE", c.sre, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0x5C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x5D */ {"EOR", c.eor, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x5E */ {"LSR", c.lsr, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x5F */ {"SRE", c.sre, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x60 */ {"RTS", c.rts, 1, 6, 0, c.getAddrImplied},
-		/* 0x61 */ {"ADC", c.adc, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x62 */ {"x62", c.xxx, 0, 0, 0, nil},
-		/* 0x63 */ {"RRA", c.rra, 2, 8, 0, c.getAddrIndirectX},
-		/* 0x64 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x65 */ {"ADC", c.adc, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x66 */ {"ROR", c.ror, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x67 */ {"RRA", c.rra, 2, 5, 0, c.getAddrZeroPage},
-		/* 0x68 */ {"PLA", c.pla, 1, 4, 0, c.getAddrImplied},
-		/* 0x69 */ {"ADC", c.adc, 2, 2, 0, c.getAddrImmediate},
-		/* 0x6A */ {"ROR", c.rora, 1, 2, 0, c.getAddrAccumulator},
-		/* 0x6B */ {"ARR", c.arr, 2, 2, 0, c.getAddrImmediate},
-		/* 0x6C */ {"JMP", c.jmp, 3, 5, 0, c.getAddrIndirect},
-		/* 0x6D */ {"ADC", c.adc, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x6E */ {"ROR", c.ror, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x6F */ {"RRA", c.rra, 3, 6, 0, c.getAddrAbsolute},
-		/* 0x70 */ {"BVS", c.bvs, 2, 2, 0, c.getAddrRelative},
-		/* 0x71 */ {"ADC", c.adc, 2, 5, 1, c.getAddrIndirectY},
-		/* 0x72 */ {"x72", c.xxx, 0, 0, 0, nil},
-		/* 0x73 */ {"RRA", c.rra, 2, 8, 0, c.getAddrIndirectY},
-		/* 0x74 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x75 */ {"ADC", c.adc, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x76 */ {"ROR", c.ror, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x77 */ {"RRA", c.rra, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0x78 */ {"SEI", c.sei, 1, 2, 0, c.getAddrImplied},
-		/* 0x79 */ {"ADC", c.adc, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0x7A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0x7B */ {"RRA", c.rra, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0x7C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x7D */ {"ADC", c.adc, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0x7E */ {"ROR", c.ror, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x7F */ {"RRA", c.rra, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0x80 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate},
-		/* 0x81 */ {"STA", c.sta, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x82 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate},
-		/* 0x83 */ {"AAX", c.aax, 2, 6, 0, c.getAddrIndirectX},
-		/* 0x84 */ {"STY", c.sty, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x85 */ {"STA", c.sta, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x86 */ {"STX", c.stx, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x87 */ {"AAX", c.aax, 2, 3, 0, c.getAddrZeroPage},
-		/* 0x88 */ {"DEY", c.dey, 1, 2, 0, c.getAddrImplied},
-		/* 0x89 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate},
-		/* 0x8A */ {"TXA", c.txa, 1, 2, 0, c.getAddrImplied},
-		/* 0x8B */ {"x8B", c.xxx, 0, 0, 0, nil},
-		/* 0x8C */ {"STY", c.sty, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x8D */ {"STA", c.sta, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x8E */ {"STX", c.stx, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x8F */ {"AAX", c.aax, 3, 4, 0, c.getAddrAbsolute},
-		/* 0x90 */ {"BCC", c.bcc, 2, 2, 0, c.getAddrRelative},
-		/* 0x91 */ {"STA", c.sta, 2, 6, 0, c.getAddrIndirectY},
-		/* 0x92 */ {"x92", c.xxx, 0, 0, 0, nil},
-		/* 0x93 */ {"x93", c.xxx, 0, 0, 0, nil},
-		/* 0x94 */ {"STY", c.sty, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x95 */ {"STA", c.sta, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0x96 */ {"STX", c.stx, 2, 4, 0, c.getAddrZeroPageY},
-		/* 0x97 */ {"AAX", c.aax, 2, 4, 0, c.getAddrZeroPageY},
-		/* 0x98 */ {"TYA", c.tya, 1, 2, 0, c.getAddrImplied},
-		/* 0x99 */ {"STA", c.sta, 3, 5, 0, c.getAddrAbsoluteY},
-		/* 0x9A */ {"TXS", c.txs, 1, 2, 0, c.getAddrImplied},
-		/* 0x9B */ {"x9B", c.xxx, 0, 0, 0, nil},
-		/* 0x9C */ {"x9C", c.xxx, 0, 0, 0, nil},
-		/* 0x9D */ {"STA", c.sta, 3, 5, 0, c.getAddrAbsoluteX},
-		/* 0x9E */ {"x9E", c.xxx, 0, 0, 0, nil},
-		/* 0x9F */ {"x9F", c.xxx, 0, 0, 0, nil},
-		/* 0xA0 */ {"LDY", c.ldy, 2, 2, 0, c.getAddrImmediate},
-		/* 0xA1 */ {"LDA", c.lda, 2, 6, 0, c.getAddrIndirectX},
-		/* 0xA2 */ {"LDX", c.ldx, 2, 2, 0, c.getAddrImmediate},
-		/* 0xA3 */ {"LAX", c.lax, 2, 6, 0, c.getAddrIndirectX},
-		/* 0xA4 */ {"LDY", c.ldy, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xA5 */ {"LDA", c.lda, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xA6 */ {"LDX", c.ldx, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xA7 */ {"LAX", c.lax, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xA8 */ {"TAY", c.tay, 1, 2, 0, c.getAddrImplied},
-		/* 0xA9 */ {"LDA", c.lda, 2, 2, 0, c.getAddrImmediate},
-		/* 0xAA */ {"TAX", c.tax, 1, 2, 0, c.getAddrImplied},
-		/* 0xAB */ {"LXA", c.lxa, 2, 2, 0, c.getAddrImmediate},
-		/* 0xAC */ {"LDY", c.ldy, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xAD */ {"LDA", c.lda, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xAE */ {"LDX", c.ldx, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xAF */ {"LAX", c.lax, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xB0 */ {"BCS", c.bcs, 2, 2, 0, c.getAddrRelative},
-		/* 0xB1 */ {"LDA", c.lda, 2, 5, 1, c.getAddrIndirectY},
-		/* 0xB2 */ {"xB2", c.xxx, 0, 0, 0, nil},
-		/* 0xB3 */ {"LAX", c.lax, 2, 5, 1, c.getAddrIndirectY},
-		/* 0xB4 */ {"LDY", c.ldy, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xB5 */ {"LDA", c.lda, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xB6 */ {"LDX", c.ldx, 2, 4, 0, c.getAddrZeroPageY},
-		/* 0xB7 */ {"LAX", c.lax, 2, 4, 0, c.getAddrZeroPageY},
-		/* 0xB8 */ {"CLV", c.clv, 1, 2, 0, c.getAddrImplied},
-		/* 0xB9 */ {"LDA", c.lda, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0xBA */ {"TSX", c.tsx, 1, 2, 0, c.getAddrImplied},
-		/* 0xBB */ {"xBB", c.xxx, 0, 0, 0, nil},
-		/* 0xBC */ {"LDY", c.ldy, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xBD */ {"LDA", c.lda, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xBE */ {"LDX", c.ldx, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0xBF */ {"LAX", c.lax, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0xC0 */ {"CPY", c.cpy, 2, 2, 0, c.getAddrImmediate},
-		/* 0xC1 */ {"CMP", c.cmp, 2, 6, 0, c.getAddrIndirectX},
-		/* 0xC2 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate},
-		/* 0xC3 */ {"DCP", c.dcp, 2, 8, 0, c.getAddrIndirectX},
-		/* 0xC4 */ {"CPY", c.cpy, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xC5 */ {"CMP", c.cmp, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xC6 */ {"DEC", c.dec, 2, 5, 0, c.getAddrZeroPage},
-		/* 0xC7 */ {"DCP", c.dcp, 2, 5, 0, c.getAddrZeroPage},
-		/* 0xC8 */ {"INY", c.iny, 1, 2, 0, c.getAddrImplied},
-		/* 0xC9 */ {"CMP", c.cmp, 2, 2, 0, c.getAddrImmediate},
-		/* 0xCA */ {"DEX", c.dex, 1, 2, 0, c.getAddrImplied},
-		/* 0xCB */ {"SAX", c.sax, 2, 2, 0, c.getAddrImmediate},
-		/* 0xCC */ {"CPY", c.cpy, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xCD */ {"CMP", c.cmp, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xCE */ {"DEC", c.dec, 3, 6, 0, c.getAddrAbsolute},
-		/* 0xCF */ {"DCP", c.dcp, 3, 6, 0, c.getAddrAbsolute},
-		/* 0xD0 */ {"BNE", c.bne, 2, 2, 0, c.getAddrRelative},
-		/* 0xD1 */ {"CMP", c.cmp, 2, 5, 1, c.getAddrIndirectY},
-		/* 0xD2 */ {"xD2", c.xxx, 0, 0, 0, nil},
-		/* 0xD3 */ {"DCP", c.dcp, 2, 8, 0, c.getAddrIndirectY},
-		/* 0xD4 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xD5 */ {"CMP", c.cmp, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xD6 */ {"DEC", c.dec, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0xD7 */ {"DCP", c.dcp, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0xD8 */ {"CLD", c.cld, 1, 2, 0, c.getAddrImplied},
-		/* 0xD9 */ {"CMP", c.cmp, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0xDA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0xDB */ {"DCP", c.dcp, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0xDC */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xDD */ {"CMP", c.cmp, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xDE */ {"DEC", c.dec, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0xDF */ {"DCP", c.dcp, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0xE0 */ {"CPX", c.cpx, 2, 2, 0, c.getAddrImmediate},
-		/* 0xE1 */ {"SBC", c.sbc, 2, 6, 0, c.getAddrIndirectX},
-		/* 0xE2 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate},
-		/* 0xE3 */ {"ISC", c.isc, 2, 8, 0, c.getAddrIndirectX},
-		/* 0xE4 */ {"CPX", c.cpx, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xE5 */ {"SBC", c.sbc, 2, 3, 0, c.getAddrZeroPage},
-		/* 0xE6 */ {"INC", c.inc, 2, 5, 0, c.getAddrZeroPage},
-		/* 0xE7 */ {"ISC", c.isc, 2, 5, 0, c.getAddrZeroPage},
-		/* 0xE8 */ {"INX", c.inx, 1, 2, 0, c.getAddrImplied},
-		/* 0xE9 */ {"SBC", c.sbc, 2, 2, 0, c.getAddrImmediate},
-		/* 0xEA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0xEB */ {"SBC", c.sbc, 2, 2, 0, c.getAddrImmediate},
-		/* 0xEC */ {"CPX", c.cpx, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xED */ {"SBC", c.sbc, 3, 4, 0, c.getAddrAbsolute},
-		/* 0xEE */ {"INC", c.inc, 3, 6, 0, c.getAddrAbsolute},
-		/* 0xEF */ {"ISC", c.isc, 3, 6, 0, c.getAddrAbsolute},
-		/* 0xF0 */ {"BEQ", c.beq, 2, 2, 0, c.getAddrRelative},
-		/* 0xF1 */ {"SBC", c.sbc, 2, 5, 1, c.getAddrIndirectY},
-		/* 0xF2 */ {"xF2", c.xxx, 0, 0, 0, nil},
-		/* 0xF3 */ {"ISC", c.isc, 2, 8, 0, c.getAddrIndirectY},
-		/* 0xF4 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xF5 */ {"SBC", c.sbc, 2, 4, 0, c.getAddrZeroPageX},
-		/* 0xF6 */ {"INC", c.inc, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0xF7 */ {"ISC", c.isc, 2, 6, 0, c.getAddrZeroPageX},
-		/* 0xF8 */ {"SED", c.sed, 1, 2, 0, c.getAddrImplied},
-		/* 0xF9 */ {"SBC", c.sbc, 3, 4, 1, c.getAddrAbsoluteY},
-		/* 0xFA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied},
-		/* 0xFB */ {"ISC", c.isc, 3, 7, 0, c.getAddrAbsoluteY},
-		/* 0xFC */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xFD */ {"SBC", c.sbc, 3, 4, 1, c.getAddrAbsoluteX},
-		/* 0xFE */ {"INC", c.inc, 3, 7, 0, c.getAddrAbsoluteX},
-		/* 0xFF */ {"ISC", c.isc, 3, 7, 0, c.getAddrAbsoluteX},
+		/* 0x00 */ {"BRK", c.brk, 1, 7, 0, c.getAddrImplied, Implied},
+		/* 0x01 */ {"ORA", c.ora, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x02 */ {"x02", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x03 */ {"SLO", c.slo, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x04 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x05 */ {"ORA", c.ora, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x06 */ {"ASL", c.asl, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x07 */ {"SLO", c.slo, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x08 */ {"PHP", c.php, 1, 3, 0, c.getAddrImplied, Implied},
+		/* 0x09 */ {"ORA", c.ora, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x0A */ {"ASL", c.asla, 1, 2, 0, c.getAddrAccumulator, Accumulator},
+		/* 0x0B */ {"ANC", c.anc, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x0C */ {"TOP", c.top, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x0D */ {"ORA", c.ora, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x0E */ {"ASL", c.asl, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x0F */ {"SLO", c.slo, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x10 */ {"BPL", c.bpl, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0x11 */ {"ORA", c.ora, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0x12 */ {"x12", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x13 */ {"SLO", c.slo, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0x14 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x15 */ {"ORA", c.ora, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x16 */ {"ASL", c.asl, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x17 */ {"SLO", c.slo, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x18 */ {"CLC", c.clc, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x19 */ {"ORA", c.ora, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x1A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x1B */ {"SLO", c.slo, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x1C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x1D */ {"ORA", c.ora, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x1E */ {"ASL", c.asl, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x1F */ {"SLO", c.slo, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x20 */ {"JSR", c.jsr, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x21 */ {"AND", c.and, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x22 */ {"x22", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x23 */ {"RLA", c.rla, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x24 */ {"BIT", c.bit, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x25 */ {"AND", c.and, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x26 */ {"ROL", c.rol, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x27 */ {"RLA", c.rla, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x28 */ {"PLP", c.plp, 1, 4, 0, c.getAddrImplied, Implied},
+		/* 0x29 */ {"AND", c.and, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x2A */ {"ROL", c.rola, 1, 2, 0, c.getAddrAccumulator, Accumulator},
+		/* 0x2B */ {"ANC", c.anc, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x2C */ {"BIT", c.bit, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x2D */ {"AND", c.and, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x2E */ {"ROL", c.rol, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x2F */ {"RLA", c.rla, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x30 */ {"BMI", c.bmi, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0x31 */ {"AND", c.and, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0x32 */ {"x32", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x33 */ {"RLA", c.rla, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0x34 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x35 */ {"AND", c.and, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x36 */ {"ROL", c.rol, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x37 */ {"RLA", c.rla, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x38 */ {"SEC", c.sec, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x39 */ {"AND", c.and, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x3A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x3B */ {"RLA", c.rla, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x3C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x3D */ {"AND", c.and, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x3E */ {"ROL", c.rol, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x3F */ {"RLA", c.rla, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x40 */ {"RTI", c.rti, 1, 6, 0, c.getAddrImplied, Implied},
+		/* 0x41 */ {"EOR", c.eor, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x42 */ {"x42", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x43 */ {"SRE", c.sre, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x44 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x45 */ {"EOR", c.eor, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x46 */ {"LSR", c.lsr, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x47 */ {"SRE", c.sre, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x48 */ {"PHA", c.pha, 1, 3, 0, c.getAddrImplied, Implied},
+		/* 0x49 */ {"EOR", c.eor, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x4A */ {"LSR", c.lsra, 1, 2, 0, c.getAddrAccumulator, Accumulator},
+		/* 0x4B */ {"ALR", c.alr, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x4C */ {"JMP", c.jmp, 3, 3, 0, c.getAddrAbsolute, Absolute},
+		/* 0x4D */ {"EOR", c.eor, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x4E */ {"LSR", c.lsr, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x4F */ {"SRE", c.sre, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x50 */ {"BVC", c.bvc, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0x51 */ {"EOR", c.eor, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0x52 */ {"x52", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x53 */ {"SRE", c.sre, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0x54 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x55 */ {"EOR", c.eor, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x56 */ {"LSR", c.lsr, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x57 */ {"SRE", c.sre, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x58 */ {"CLI", c.cli, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x59 */ {"EOR", c.eor, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x5A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x5B */ {"SRE", c.sre, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x5C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x5D */ {"EOR", c.eor, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x5E */ {"LSR", c.lsr, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x5F */ {"SRE", c.sre, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x60 */ {"RTS", c.rts, 1, 6, 0, c.getAddrImplied, Implied},
+		/* 0x61 */ {"ADC", c.adc, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x62 */ {"x62", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x63 */ {"RRA", c.rra, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x64 */ {"DOP", c.dop, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x65 */ {"ADC", c.adc, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x66 */ {"ROR", c.ror, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x67 */ {"RRA", c.rra, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x68 */ {"PLA", c.pla, 1, 4, 0, c.getAddrImplied, Implied},
+		/* 0x69 */ {"ADC", c.adc, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x6A */ {"ROR", c.rora, 1, 2, 0, c.getAddrAccumulator, Accumulator},
+		/* 0x6B */ {"ARR", c.arr, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x6C */ {"JMP", c.jmp, 3, 5, 0, c.getAddrIndirect, Indirect},
+		/* 0x6D */ {"ADC", c.adc, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x6E */ {"ROR", c.ror, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x6F */ {"RRA", c.rra, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0x70 */ {"BVS", c.bvs, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0x71 */ {"ADC", c.adc, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0x72 */ {"x72", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x73 */ {"RRA", c.rra, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0x74 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x75 */ {"ADC", c.adc, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x76 */ {"ROR", c.ror, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x77 */ {"RRA", c.rra, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x78 */ {"SEI", c.sei, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x79 */ {"ADC", c.adc, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x7A */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x7B */ {"RRA", c.rra, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x7C */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x7D */ {"ADC", c.adc, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x7E */ {"ROR", c.ror, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x7F */ {"RRA", c.rra, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x80 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x81 */ {"STA", c.sta, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x82 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x83 */ {"AAX", c.aax, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0x84 */ {"STY", c.sty, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x85 */ {"STA", c.sta, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x86 */ {"STX", c.stx, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x87 */ {"AAX", c.aax, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0x88 */ {"DEY", c.dey, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x89 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0x8A */ {"TXA", c.txa, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x8B */ {"x8B", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x8C */ {"STY", c.sty, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x8D */ {"STA", c.sta, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x8E */ {"STX", c.stx, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x8F */ {"AAX", c.aax, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0x90 */ {"BCC", c.bcc, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0x91 */ {"STA", c.sta, 2, 6, 0, c.getAddrIndirectY, IndirectY},
+		/* 0x92 */ {"x92", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x93 */ {"x93", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x94 */ {"STY", c.sty, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x95 */ {"STA", c.sta, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0x96 */ {"STX", c.stx, 2, 4, 0, c.getAddrZeroPageY, ZeroPageY},
+		/* 0x97 */ {"AAX", c.aax, 2, 4, 0, c.getAddrZeroPageY, ZeroPageY},
+		/* 0x98 */ {"TYA", c.tya, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x99 */ {"STA", c.sta, 3, 5, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0x9A */ {"TXS", c.txs, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0x9B */ {"x9B", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x9C */ {"x9C", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x9D */ {"STA", c.sta, 3, 5, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0x9E */ {"x9E", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0x9F */ {"x9F", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0xA0 */ {"LDY", c.ldy, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xA1 */ {"LDA", c.lda, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xA2 */ {"LDX", c.ldx, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xA3 */ {"LAX", c.lax, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xA4 */ {"LDY", c.ldy, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xA5 */ {"LDA", c.lda, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xA6 */ {"LDX", c.ldx, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xA7 */ {"LAX", c.lax, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xA8 */ {"TAY", c.tay, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xA9 */ {"LDA", c.lda, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xAA */ {"TAX", c.tax, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xAB */ {"LXA", c.lxa, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xAC */ {"LDY", c.ldy, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xAD */ {"LDA", c.lda, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xAE */ {"LDX", c.ldx, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xAF */ {"LAX", c.lax, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xB0 */ {"BCS", c.bcs, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0xB1 */ {"LDA", c.lda, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0xB2 */ {"xB2", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0xB3 */ {"LAX", c.lax, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0xB4 */ {"LDY", c.ldy, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xB5 */ {"LDA", c.lda, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xB6 */ {"LDX", c.ldx, 2, 4, 0, c.getAddrZeroPageY, ZeroPageY},
+		/* 0xB7 */ {"LAX", c.lax, 2, 4, 0, c.getAddrZeroPageY, ZeroPageY},
+		/* 0xB8 */ {"CLV", c.clv, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xB9 */ {"LDA", c.lda, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xBA */ {"TSX", c.tsx, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xBB */ {"xBB", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0xBC */ {"LDY", c.ldy, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xBD */ {"LDA", c.lda, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xBE */ {"LDX", c.ldx, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xBF */ {"LAX", c.lax, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xC0 */ {"CPY", c.cpy, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xC1 */ {"CMP", c.cmp, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xC2 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xC3 */ {"DCP", c.dcp, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xC4 */ {"CPY", c.cpy, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xC5 */ {"CMP", c.cmp, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xC6 */ {"DEC", c.dec, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xC7 */ {"DCP", c.dcp, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xC8 */ {"INY", c.iny, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xC9 */ {"CMP", c.cmp, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xCA */ {"DEX", c.dex, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xCB */ {"SAX", c.sax, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xCC */ {"CPY", c.cpy, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xCD */ {"CMP", c.cmp, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xCE */ {"DEC", c.dec, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0xCF */ {"DCP", c.dcp, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0xD0 */ {"BNE", c.bne, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0xD1 */ {"CMP", c.cmp, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0xD2 */ {"xD2", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0xD3 */ {"DCP", c.dcp, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0xD4 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xD5 */ {"CMP", c.cmp, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xD6 */ {"DEC", c.dec, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xD7 */ {"DCP", c.dcp, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xD8 */ {"CLD", c.cld, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xD9 */ {"CMP", c.cmp, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xDA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xDB */ {"DCP", c.dcp, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xDC */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xDD */ {"CMP", c.cmp, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xDE */ {"DEC", c.dec, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xDF */ {"DCP", c.dcp, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xE0 */ {"CPX", c.cpx, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xE1 */ {"SBC", c.sbc, 2, 6, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xE2 */ {"DOP", c.dop, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xE3 */ {"ISC", c.isc, 2, 8, 0, c.getAddrIndirectX, IndirectX},
+		/* 0xE4 */ {"CPX", c.cpx, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xE5 */ {"SBC", c.sbc, 2, 3, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xE6 */ {"INC", c.inc, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xE7 */ {"ISC", c.isc, 2, 5, 0, c.getAddrZeroPage, ZeroPage},
+		/* 0xE8 */ {"INX", c.inx, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xE9 */ {"SBC", c.sbc, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xEA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xEB */ {"SBC", c.sbc, 2, 2, 0, c.getAddrImmediate, Immediate},
+		/* 0xEC */ {"CPX", c.cpx, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xED */ {"SBC", c.sbc, 3, 4, 0, c.getAddrAbsolute, Absolute},
+		/* 0xEE */ {"INC", c.inc, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0xEF */ {"ISC", c.isc, 3, 6, 0, c.getAddrAbsolute, Absolute},
+		/* 0xF0 */ {"BEQ", c.beq, 2, 2, 0, c.getAddrRelative, Relative},
+		/* 0xF1 */ {"SBC", c.sbc, 2, 5, 1, c.getAddrIndirectY, IndirectY},
+		/* 0xF2 */ {"xF2", c.xxx, 0, 0, 0, nil, Implied},
+		/* 0xF3 */ {"ISC", c.isc, 2, 8, 0, c.getAddrIndirectY, IndirectY},
+		/* 0xF4 */ {"DOP", c.dop, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xF5 */ {"SBC", c.sbc, 2, 4, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xF6 */ {"INC", c.inc, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xF7 */ {"ISC", c.isc, 2, 6, 0, c.getAddrZeroPageX, ZeroPageX},
+		/* 0xF8 */ {"SED", c.sed, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xF9 */ {"SBC", c.sbc, 3, 4, 1, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xFA */ {"NOP", c.nop, 1, 2, 0, c.getAddrImplied, Implied},
+		/* 0xFB */ {"ISC", c.isc, 3, 7, 0, c.getAddrAbsoluteY, AbsoluteY},
+		/* 0xFC */ {"TOP", c.top, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xFD */ {"SBC", c.sbc, 3, 4, 1, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xFE */ {"INC", c.inc, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
+		/* 0xFF */ {"ISC", c.isc, 3, 7, 0, c.getAddrAbsoluteX, AbsoluteX},
 	}
 }
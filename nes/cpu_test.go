@@ -200,3 +200,60 @@ func TestCPUUsingNESTest(t *testing.T) {
 		}
 	}
 }
+
+// ramBus is a Bus backed by a flat 64k RAM, with no memory mapped devices.
+type ramBus struct {
+	ram [65536]byte
+}
+
+func (b *ramBus) Read(address uint16) byte {
+	return b.ram[address]
+}
+
+func (b *ramBus) Write(address uint16, value byte) int {
+	b.ram[address] = value
+	return 0
+}
+
+func (b *ramBus) Peek(address uint16) byte {
+	return b.ram[address]
+}
+
+func (b *ramBus) IRQ() bool {
+	return false
+}
+
+func TestCPUWithRAMBus(t *testing.T) {
+	bus := &ramBus{}
+	bus.ram[ResetVector] = 0x00
+	bus.ram[ResetVector+1] = 0x02
+
+	program := []byte{
+		0xA9, 0x05, // LDA #$05
+		0x69, 0x03, // ADC #$03
+		0x8D, 0x00, 0x03, // STA $0300
+		0x20, 0x00, 0x04, // JSR $0400
+	}
+	copy(bus.ram[0x200:], program)
+	bus.ram[0x400] = 0xE8 // INX
+	bus.ram[0x401] = 0x60 // RTS
+
+	cpu := NewCPU(bus)
+	if cpu.PC != 0x200 {
+		t.Fatalf("PC=%04X, expected 0200\n", cpu.PC)
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bus.ram[0x300] != 0x08 {
+		t.Errorf("$0300=%02X, expected 08\n", bus.ram[0x300])
+	}
+
+	if cpu.X != 1 || cpu.PC != 0x20A {
+		t.Errorf("X=%02X PC=%04X, expected X=01 PC=020A\n", cpu.X, cpu.PC)
+	}
+}
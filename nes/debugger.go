@@ -0,0 +1,458 @@
+package nes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// AccessType is a kind of memory access, used by Watchpoints.
+type AccessType int
+
+const (
+	AccessRead AccessType = 1 << iota
+	AccessWrite
+	AccessExecute
+)
+
+// String returns the access type as a combination of "r", "w" and "x".
+func (a AccessType) String() string {
+	var result string
+
+	if a&AccessRead != 0 {
+		result += "r"
+	}
+	if a&AccessWrite != 0 {
+		result += "w"
+	}
+	if a&AccessExecute != 0 {
+		result += "x"
+	}
+
+	return result
+}
+
+// AddressSpace selects the CPU or PPU address space.
+type AddressSpace int
+
+const (
+	CPUAddressSpace AddressSpace = iota
+	PPUAddressSpace
+)
+
+// String returns "cpu" or "ppu".
+func (s AddressSpace) String() string {
+	if s == PPUAddressSpace {
+		return "ppu"
+	}
+
+	return "cpu"
+}
+
+// A Breakpoint stops execution before the instruction at Address is executed.
+//
+// If Condition is non-nil, execution only stops when Condition returns true.
+type Breakpoint struct {
+	ID            int
+	Address       uint16
+	Condition     func(c *CPU) bool
+	ConditionText string
+}
+
+// A Watchpoint stops execution when an address in the range [Start, End] is
+// accessed.
+//
+// CPU watchpoints see all CPU bus accesses, except instruction fetches, which
+// are matched by AccessExecute instead. PPU watchpoints see accesses made
+// through PPUDATA ($2007).
+type Watchpoint struct {
+	ID     int
+	Space  AddressSpace
+	Start  uint16
+	End    uint16
+	Access AccessType
+}
+
+// StopReason describes why the Debugger stopped execution.
+type StopReason int
+
+const (
+	StopStep StopReason = iota
+	StopBreakpoint
+	StopWatchpoint
+	StopPause
+)
+
+// A Stop describes where and why the Debugger stopped execution.
+type Stop struct {
+	Reason StopReason
+	PC     uint16
+
+	// Set for StopBreakpoint.
+	Breakpoint *Breakpoint
+
+	// Set for StopWatchpoint.
+	Watchpoint *Watchpoint
+	Address    uint16
+	Value      byte
+	Access     AccessType
+}
+
+// String returns a description of the Stop.
+func (s *Stop) String() string {
+	switch s.Reason {
+	case StopBreakpoint:
+		return fmt.Sprintf("breakpoint %d @ PC=%04X", s.Breakpoint.ID, s.PC)
+	case StopWatchpoint:
+		return fmt.Sprintf("watchpoint %d (%s %s $%04X = %02X) @ PC=%04X",
+			s.Watchpoint.ID, s.Watchpoint.Space, s.Access, s.Address, s.Value, s.PC)
+	case StopPause:
+		return fmt.Sprintf("paused @ PC=%04X", s.PC)
+	default:
+		return fmt.Sprintf("stepped to PC=%04X", s.PC)
+	}
+}
+
+// Debugger controls the execution of a Console.
+//
+// The Debugger supports PC breakpoints (optionally conditional on the CPU
+// registers), read/write/execute watchpoints, and stepping by instruction,
+// subroutine, scanline or frame.
+type Debugger struct {
+	console *Console
+
+	breakpoints []*Breakpoint
+	watchpoints []*Watchpoint
+	nextID      int
+
+	// Set by the memory access hooks when a watchpoint is hit.
+	stop *Stop
+
+	// Bytes of the instruction being executed, excluded from read
+	// watchpoints.
+	fetchStart uint16
+	fetchEnd   uint16
+
+	pauseRequested int32
+}
+
+// NewDebugger returns a Debugger attached to console.
+//
+// Only one Debugger may be attached at a time; it replaces console.Debugger.
+func NewDebugger(console *Console) *Debugger {
+	d := &Debugger{console: console, nextID: 1}
+	console.Debugger = d
+
+	return d
+}
+
+// AddBreakpoint adds a breakpoint at address. condition may be nil.
+func (d *Debugger) AddBreakpoint(address uint16, condition func(c *CPU) bool) *Breakpoint {
+	b := &Breakpoint{ID: d.nextID, Address: address, Condition: condition}
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, b)
+
+	return b
+}
+
+// AddWatchpoint adds a watchpoint for accesses to [start, end] in space.
+func (d *Debugger) AddWatchpoint(space AddressSpace, start uint16, end uint16, access AccessType) *Watchpoint {
+	w := &Watchpoint{ID: d.nextID, Space: space, Start: start, End: end, Access: access}
+	d.nextID++
+	d.watchpoints = append(d.watchpoints, w)
+
+	return w
+}
+
+// Delete removes the breakpoint or watchpoint with the given id. Returns false
+// if there isn't one.
+func (d *Debugger) Delete(id int) bool {
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Breakpoints returns the current breakpoints.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	return d.breakpoints
+}
+
+// Watchpoints returns the current watchpoints.
+func (d *Debugger) Watchpoints() []*Watchpoint {
+	return d.watchpoints
+}
+
+// Pause requests that a running Continue (or other run function) stops as soon
+// as possible. If none is running, the next one stops after one instruction.
+// A pending pause is cleared whenever a run function returns. Pause may be
+// called from any goroutine.
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pauseRequested, 1)
+}
+
+// CancelPause cancels a pending pause, e.g. one requested while a run was
+// stopping by itself, so that it doesn't stop the next run. Call it once the
+// run has returned.
+func (d *Debugger) CancelPause() {
+	atomic.StoreInt32(&d.pauseRequested, 0)
+}
+
+// Continue runs until a breakpoint or watchpoint is hit, or Pause is called.
+func (d *Debugger) Continue() (*Stop, error) {
+	return d.run(nil)
+}
+
+// StepInto executes a single instruction.
+func (d *Debugger) StepInto() (*Stop, error) {
+	return d.run(func() bool {
+		return true
+	})
+}
+
+// StepOver executes a single instruction. Subroutine calls (JSR) are run until
+// they return.
+func (d *Debugger) StepOver() (*Stop, error) {
+	var cpu *CPU = d.console.CPU
+
+	if cpu.Bus.Peek(cpu.PC) != 0x20 {
+		return d.StepInto()
+	}
+
+	var returnAddress uint16 = cpu.PC + 3
+	var sp byte = cpu.SP
+
+	return d.run(func() bool {
+		return cpu.PC == returnAddress && cpu.SP >= sp
+	})
+}
+
+// StepOut runs until the current subroutine or interrupt handler returns.
+func (d *Debugger) StepOut() (*Stop, error) {
+	var cpu *CPU = d.console.CPU
+	var sp byte = cpu.SP
+	var opcode byte = cpu.Bus.Peek(cpu.PC)
+
+	return d.run(func() bool {
+		returned := (opcode == 0x60 || opcode == 0x40) && cpu.SP > sp
+		opcode = cpu.Bus.Peek(cpu.PC)
+
+		return returned
+	})
+}
+
+// RunToScanline runs until the PPU reaches the start of scanline (0-261, or
+// 0-311 for PAL and Dendy).
+func (d *Debugger) RunToScanline(scanline int) (*Stop, error) {
+	var ppu *PPU = d.console.PPU
+	var previous int = ppu.Scanline
+
+	return d.run(func() bool {
+		reached := ppu.Scanline == scanline && previous != scanline
+		previous = ppu.Scanline
+
+		return reached
+	})
+}
+
+// RunToFrame runs until the PPU frame counter reaches frame.
+func (d *Debugger) RunToFrame(frame uint64) (*Stop, error) {
+	var ppu *PPU = d.console.PPU
+
+	return d.run(func() bool {
+		return ppu.Frame >= frame
+	})
+}
+
+// Runs the console one instruction at a time, until done returns true, a
+// breakpoint or watchpoint is hit, or a pause is requested. Breakpoints at the
+// starting PC are ignored, so execution can continue from a breakpoint.
+func (d *Debugger) run(done func() bool) (*Stop, error) {
+	var cpu *CPU = d.console.CPU
+
+	defer atomic.StoreInt32(&d.pauseRequested, 0)
+
+	for first := true; ; first = false {
+		if !first {
+			if stop := d.checkPC(); stop != nil {
+				return stop, nil
+			}
+		}
+
+		d.stop = nil
+		d.fetchStart = cpu.PC
+		d.fetchEnd = cpu.PC + uint16(cpu.instructions[cpu.Bus.Peek(cpu.PC)].Size)
+
+		_, err := d.console.Step()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.stop != nil {
+			d.stop.PC = cpu.PC
+			return d.stop, nil
+		}
+
+		if atomic.LoadInt32(&d.pauseRequested) != 0 {
+			return &Stop{Reason: StopPause, PC: cpu.PC}, nil
+		}
+
+		if done != nil && done() {
+			return &Stop{Reason: StopStep, PC: cpu.PC}, nil
+		}
+	}
+}
+
+// Returns a Stop if a breakpoint or execute watchpoint matches the current PC.
+func (d *Debugger) checkPC() *Stop {
+	var cpu *CPU = d.console.CPU
+
+	for _, b := range d.breakpoints {
+		if b.Address == cpu.PC && (b.Condition == nil || b.Condition(cpu)) {
+			return &Stop{Reason: StopBreakpoint, PC: cpu.PC, Breakpoint: b}
+		}
+	}
+
+	for _, w := range d.watchpoints {
+		if w.Space == CPUAddressSpace && w.Access&AccessExecute != 0 &&
+			cpu.PC >= w.Start && cpu.PC <= w.End {
+			return &Stop{Reason: StopWatchpoint, PC: cpu.PC, Watchpoint: w,
+				Address: cpu.PC, Value: cpu.Bus.Peek(cpu.PC), Access: AccessExecute}
+		}
+	}
+
+	return nil
+}
+
+// Called for each CPU bus read and write.
+func (d *Debugger) cpuAccess(address uint16, value byte, access AccessType) {
+	if access == AccessRead && address >= d.fetchStart && address < d.fetchEnd {
+		return
+	}
+
+	d.access(CPUAddressSpace, address, value, access)
+}
+
+// Called for each PPUDATA read and write.
+func (d *Debugger) ppuAccess(address uint16, value byte, access AccessType) {
+	d.access(PPUAddressSpace, address&0x3FFF, value, access)
+}
+
+func (d *Debugger) access(space AddressSpace, address uint16, value byte, access AccessType) {
+	if d.stop != nil {
+		return
+	}
+
+	for _, w := range d.watchpoints {
+		if w.Space == space && w.Access&access != 0 &&
+			address >= w.Start && address <= w.End {
+			d.stop = &Stop{Reason: StopWatchpoint, Watchpoint: w,
+				Address: address, Value: value, Access: access}
+			return
+		}
+	}
+}
+
+// ParseCondition parses a register condition for a conditional breakpoint.
+//
+// Conditions have the form "REG OP VALUE", where REG is one of A, X, Y, SP, PC
+// or P, OP is one of ==, !=, <, <=, >, >= or & (bitwise and is non-zero), and
+// VALUE is a number in decimal, or hex prefixed with $ or 0x. Examples:
+//
+//	A == $10
+//	P & $01
+//	SP < 0x80
+func ParseCondition(text string) (func(c *CPU) bool, error) {
+	var operators = []string{"==", "!=", "<=", ">=", "<", ">", "&"}
+
+	var operator string
+	var index int = -1
+	for _, op := range operators {
+		if index = strings.Index(text, op); index != -1 {
+			operator = op
+			break
+		}
+	}
+
+	if index == -1 {
+		return nil, fmt.Errorf("no operator in condition %q", text)
+	}
+
+	register := strings.ToUpper(strings.TrimSpace(text[:index]))
+	value, err := ParseNumber(strings.TrimSpace(text[index+len(operator):]))
+	if err != nil {
+		return nil, err
+	}
+
+	var get func(c *CPU) int
+	switch register {
+	case "A":
+		get = func(c *CPU) int { return int(c.A) }
+	case "X":
+		get = func(c *CPU) int { return int(c.X) }
+	case "Y":
+		get = func(c *CPU) int { return int(c.Y) }
+	case "SP":
+		get = func(c *CPU) int { return int(c.SP) }
+	case "PC":
+		get = func(c *CPU) int { return int(c.PC) }
+	case "P":
+		get = func(c *CPU) int { return int(c.P()) }
+	default:
+		return nil, fmt.Errorf("unknown register %q", register)
+	}
+
+	var v int = int(value)
+	var condition func(c *CPU) bool
+	switch operator {
+	case "==":
+		condition = func(c *CPU) bool { return get(c) == v }
+	case "!=":
+		condition = func(c *CPU) bool { return get(c) != v }
+	case "<=":
+		condition = func(c *CPU) bool { return get(c) <= v }
+	case ">=":
+		condition = func(c *CPU) bool { return get(c) >= v }
+	case "<":
+		condition = func(c *CPU) bool { return get(c) < v }
+	case ">":
+		condition = func(c *CPU) bool { return get(c) > v }
+	case "&":
+		condition = func(c *CPU) bool { return get(c)&v != 0 }
+	}
+
+	return condition, nil
+}
+
+// ParseNumber parses a 16-bit number in decimal, or hex prefixed with $ or 0x.
+func ParseNumber(text string) (uint16, error) {
+	var value uint64
+	var err error
+
+	switch {
+	case strings.HasPrefix(text, "$"):
+		value, err = strconv.ParseUint(text[1:], 16, 16)
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		value, err = strconv.ParseUint(text[2:], 16, 16)
+	default:
+		value, err = strconv.ParseUint(text, 10, 16)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+
+	return uint16(value), nil
+}
@@ -0,0 +1,120 @@
+package nes
+
+import (
+	"testing"
+)
+
+var debuggerTestProgram = []byte{
+	/* 8000 */ 0xA2, 0x00, // LDX #$00
+	/* 8002 */ 0xE8, // INX
+	/* 8003 */ 0x20, 0x0C, 0x80, // JSR $800C
+	/* 8006 */ 0x8E, 0x00, 0x03, // STX $0300
+	/* 8009 */ 0x4C, 0x02, 0x80, // JMP $8002
+	/* 800C */ 0xEA, // NOP
+	/* 800D */ 0xEA, // NOP
+	/* 800E */ 0x60, // RTS
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	console := newTestConsole(debuggerTestProgram)
+	debugger := NewDebugger(console)
+
+	condition, err := ParseCondition("X == 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugger.AddBreakpoint(0x8006, condition)
+
+	stop, err := debugger.Continue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stop.Reason != StopBreakpoint || console.CPU.PC != 0x8006 || console.CPU.X != 3 {
+		t.Fatalf("stop=%s X=%d, expected breakpoint @ 8006 with X=3\n", stop, console.CPU.X)
+	}
+}
+
+func TestDebuggerCancelPause(t *testing.T) {
+	console := newTestConsole(debuggerTestProgram)
+	debugger := NewDebugger(console)
+	debugger.AddBreakpoint(0x8006, nil)
+
+	// A pause requested between runs stops the next one, unless cancelled.
+	debugger.Pause()
+	debugger.CancelPause()
+
+	stop, err := debugger.Continue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stop.Reason != StopBreakpoint || console.CPU.PC != 0x8006 {
+		t.Fatalf("stop=%s, expected breakpoint @ 8006\n", stop)
+	}
+}
+
+func TestDebuggerWatchpoints(t *testing.T) {
+	console := newTestConsole(debuggerTestProgram)
+	debugger := NewDebugger(console)
+	w := debugger.AddWatchpoint(CPUAddressSpace, 0x0300, 0x0300, AccessWrite)
+
+	stop, err := debugger.Continue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stop.Reason != StopWatchpoint || stop.Address != 0x0300 || stop.Value != 1 || console.CPU.PC != 0x8009 {
+		t.Fatalf("stop=%s, expected write watchpoint @ $0300\n", stop)
+	}
+
+	debugger.Delete(w.ID)
+	debugger.AddWatchpoint(CPUAddressSpace, 0x800D, 0x800D, AccessExecute)
+
+	stop, err = debugger.Continue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stop.Reason != StopWatchpoint || console.CPU.PC != 0x800D {
+		t.Fatalf("stop=%s, expected execute watchpoint @ $800D\n", stop)
+	}
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	console := newTestConsole(debuggerTestProgram)
+	debugger := NewDebugger(console)
+	cpu := console.CPU
+
+	expected := []struct {
+		step func() (*Stop, error)
+		pc   uint16
+	}{
+		{debugger.StepInto, 0x8002},
+		{debugger.StepInto, 0x8003},
+		{debugger.StepOver, 0x8006},
+		{debugger.StepOver, 0x8009},
+		{debugger.StepInto, 0x8002},
+		{debugger.StepInto, 0x8003},
+		{debugger.StepInto, 0x800C},
+		{debugger.StepOut, 0x8006},
+	}
+
+	for i, e := range expected {
+		if _, err := e.step(); err != nil {
+			t.Fatal(err)
+		}
+
+		if cpu.PC != e.pc {
+			t.Fatalf("step %d: PC=%04X, expected %04X\n", i, cpu.PC, e.pc)
+		}
+	}
+
+	if _, err := debugger.RunToScanline(10); err != nil {
+		t.Fatal(err)
+	}
+
+	if console.PPU.Scanline != 10 {
+		t.Fatalf("Scanline=%d, expected 10\n", console.PPU.Scanline)
+	}
+}
@@ -0,0 +1,334 @@
+package nes
+
+import (
+	"fmt"
+)
+
+// AddressingMode is a 6502 memory addressing mode.
+type AddressingMode int
+
+const (
+	Implied AddressingMode = iota
+	Accumulator
+	Immediate
+	ZeroPage
+	ZeroPageX
+	ZeroPageY
+	Absolute
+	AbsoluteX
+	AbsoluteY
+	Indirect
+	IndirectX
+	IndirectY
+	Relative
+)
+
+// Opcode describes a CPU instruction type, as listed in the CPU's instruction
+// table.
+type Opcode struct {
+	Name string
+	Size int // Instruction size in bytes, 0 if the opcode is invalid.
+	Mode AddressingMode
+
+	// True for undocumented opcodes.
+	Unofficial bool
+}
+
+// The instruction table, without the implementations.
+var opcodes [256]Opcode
+
+// Mnemonics used for undocumented opcodes in disassembly (as per nestest.log),
+// where they differ from the instruction table names.
+var unofficialMnemonics = map[string]string{
+	"DOP": "NOP",
+	"TOP": "NOP",
+	"AAX": "SAX",
+	"SAX": "AXS",
+	"ISC": "ISB",
+}
+
+func init() {
+	var c *CPU = &CPU{}
+	c.loadInstructions()
+
+	for i, instruction := range c.instructions {
+		var opcode *Opcode = &opcodes[i]
+
+		opcode.Name = instruction.Name
+		opcode.Size = int(instruction.Size)
+		opcode.Mode = instruction.Mode
+
+		switch instruction.Name {
+		case "DOP", "TOP", "LAX", "AAX", "DCP", "ISC", "SLO", "RLA", "SRE",
+			"RRA", "ANC", "ALR", "ARR", "LXA", "SAX":
+			opcode.Unofficial = true
+		case "NOP":
+			opcode.Unofficial = i != 0xEA
+		case "SBC":
+			opcode.Unofficial = i == 0xEB
+		}
+	}
+}
+
+// LookupOpcode returns a description of opcode.
+func LookupOpcode(opcode byte) Opcode {
+	return opcodes[opcode]
+}
+
+// A Labeler maps addresses to symbolic names.
+type Labeler interface {
+	// Label returns the name of the label at address, if any.
+	Label(address uint16) (string, bool)
+}
+
+// Furthest distance NearestLabel looks back for a label.
+const maxLabelOffset = 0xFF
+
+// NearestLabel returns the name of address relative to the closest label at
+// or before it, e.g. "NMI_Handler+3". Labels more than 255 bytes before address
+// aren't used. labels may be nil.
+func NearestLabel(labels Labeler, address uint16) (string, bool) {
+	if labels == nil {
+		return "", false
+	}
+
+	for offset := 0; offset <= maxLabelOffset && offset <= int(address); offset++ {
+		if name, ok := labels.Label(address - uint16(offset)); ok {
+			if offset == 0 {
+				return name, true
+			}
+
+			return fmt.Sprintf("%s+%d", name, offset), true
+		}
+	}
+
+	return "", false
+}
+
+// DisassembledInstruction is a single disassembled instruction.
+type DisassembledInstruction struct {
+	Address uint16
+	Bytes   []byte
+	Opcode  Opcode
+
+	// Name of the label at Address, or "".
+	Label string
+
+	// Operand text, e.g. "#$10", "($20),Y", or "$C5F5". Addresses are
+	// replaced by labels where available. Branch, JMP and JSR targets may be
+	// named relative to a preceding label, e.g. "loop+2".
+	Operand string
+
+	// Effective address of the operand for non-indexed modes (including the
+	// branch target for Relative). Not meaningful for Implied, Accumulator
+	// and Immediate.
+	Target uint16
+}
+
+// Mnemonic returns the instruction's mnemonic, e.g. "JMP".
+//
+// Invalid opcodes are shown as ".DB".
+func (i *DisassembledInstruction) Mnemonic() string {
+	if i.Opcode.Size == 0 {
+		return ".DB"
+	}
+
+	if name, ok := unofficialMnemonics[i.Opcode.Name]; ok && i.Opcode.Unofficial {
+		return name
+	}
+
+	return i.Opcode.Name
+}
+
+// Assembly returns the assembly text, e.g. "JMP $C5F5".
+func (i *DisassembledInstruction) Assembly() string {
+	if i.Operand == "" {
+		return i.Mnemonic()
+	}
+
+	return i.Mnemonic() + " " + i.Operand
+}
+
+// String returns the instruction in nestest.log format, e.g.
+// "C000  4C F5 C5  JMP $C5F5". Undocumented opcodes are marked with a "*".
+func (i *DisassembledInstruction) String() string {
+	var marker string = " "
+	if i.Opcode.Unofficial {
+		marker = "*"
+	}
+
+	return fmt.Sprintf("%04X  %-8s %s%s", i.Address, fmt.Sprintf("% X", i.Bytes), marker, i.Assembly())
+}
+
+// A Disassembler disassembles 6502 machine code.
+type Disassembler struct {
+	// Peek reads a byte of memory to disassemble, without side effects.
+	Peek func(address uint16) byte
+
+	// Labels used to name addresses, may be nil.
+	Labels Labeler
+}
+
+// NewDisassembler returns a Disassembler reading memory with peek, and naming
+// addresses with labels (which may be nil).
+//
+// To disassemble a running console use its Bus:
+//
+//	d := NewDisassembler(console.Bus.Peek, nil)
+//	fmt.Println(d.Disassemble(console.CPU.PC))
+func NewDisassembler(peek func(address uint16) byte, labels Labeler) *Disassembler {
+	return &Disassembler{Peek: peek, Labels: labels}
+}
+
+// Disassemble disassembles the instruction at address.
+func (d *Disassembler) Disassemble(address uint16) *DisassembledInstruction {
+	var opcode Opcode = opcodes[d.Peek(address)]
+
+	var size int = opcode.Size
+	if size == 0 {
+		size = 1
+	}
+
+	i := &DisassembledInstruction{
+		Address: address,
+		Bytes:   make([]byte, size),
+		Opcode:  opcode,
+	}
+
+	for j := range i.Bytes {
+		i.Bytes[j] = d.Peek(address + uint16(j))
+	}
+
+	i.Label, _ = d.label(address)
+
+	if opcode.Size == 0 {
+		i.Operand = fmt.Sprintf("$%02X", i.Bytes[0])
+		return i
+	}
+
+	var value uint16
+	switch opcode.Size {
+	case 2:
+		value = uint16(i.Bytes[1])
+	case 3:
+		value = uint16(i.Bytes[1]) | uint16(i.Bytes[2])<<8
+	}
+
+	i.Target = value
+
+	switch opcode.Mode {
+	case Accumulator:
+		i.Operand = "A"
+	case Immediate:
+		i.Operand = fmt.Sprintf("#$%02X", value)
+	case ZeroPage:
+		i.Operand = d.name(value, 2)
+	case ZeroPageX:
+		i.Operand = d.name(value, 2) + ",X"
+	case ZeroPageY:
+		i.Operand = d.name(value, 2) + ",Y"
+	case Absolute:
+		if opcode.Name == "JMP" || opcode.Name == "JSR" {
+			i.Operand = d.codeName(value)
+		} else {
+			i.Operand = d.name(value, 4)
+		}
+	case AbsoluteX:
+		i.Operand = d.name(value, 4) + ",X"
+	case AbsoluteY:
+		i.Operand = d.name(value, 4) + ",Y"
+	case Indirect:
+		i.Operand = "(" + d.name(value, 4) + ")"
+	case IndirectX:
+		i.Operand = "(" + d.name(value, 2) + ",X)"
+	case IndirectY:
+		i.Operand = "(" + d.name(value, 2) + "),Y"
+	case Relative:
+		i.Target = address + 2 + uint16(int8(value))
+		i.Operand = d.codeName(i.Target)
+	}
+
+	return i
+}
+
+// DisassembleRange disassembles the instructions starting at address, stopping
+// before end, or after the instruction that crosses end.
+func (d *Disassembler) DisassembleRange(address uint16, end uint16) []*DisassembledInstruction {
+	var result []*DisassembledInstruction
+
+	for address < end {
+		i := d.Disassemble(address)
+		result = append(result, i)
+
+		next := address + uint16(len(i.Bytes))
+		if next < address {
+			break
+		}
+		address = next
+	}
+
+	return result
+}
+
+// DisassembleBytes disassembles code, which is located at origin in the CPU
+// address space. A final instruction extending past the end of code is shown
+// as data.
+func DisassembleBytes(code []byte, origin uint16, labels Labeler) []*DisassembledInstruction {
+	var end int = int(origin) + len(code)
+
+	d := NewDisassembler(func(address uint16) byte {
+		if int(address) >= int(origin) && int(address) < end {
+			return code[address-origin]
+		}
+
+		return 0
+	}, labels)
+
+	var result []*DisassembledInstruction
+	for offset := 0; offset < len(code); {
+		i := d.Disassemble(origin + uint16(offset))
+
+		if offset+len(i.Bytes) > len(code) {
+			i = &DisassembledInstruction{
+				Address: i.Address,
+				Bytes:   code[offset : offset+1],
+				Label:   i.Label,
+				Operand: fmt.Sprintf("$%02X", code[offset]),
+			}
+		}
+
+		result = append(result, i)
+		offset += len(i.Bytes)
+	}
+
+	return result
+}
+
+// Returns the label at address, if any.
+func (d *Disassembler) label(address uint16) (string, bool) {
+	if d.Labels == nil {
+		return "", false
+	}
+
+	return d.Labels.Label(address)
+}
+
+// Returns the label for address, or the address in hex with the given number
+// of digits.
+func (d *Disassembler) name(address uint16, digits int) string {
+	if name, ok := d.label(address); ok {
+		return name
+	}
+
+	return fmt.Sprintf("$%0*X", digits, address)
+}
+
+// Returns the name of the code address, relative to the closest preceding
+// label if any, or the address in hex.
+func (d *Disassembler) codeName(address uint16) string {
+	if name, ok := NearestLabel(d.Labels, address); ok {
+		return name
+	}
+
+	return fmt.Sprintf("$%04X", address)
+}
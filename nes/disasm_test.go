@@ -0,0 +1,101 @@
+package nes
+
+import (
+	"testing"
+)
+
+type testLabels map[uint16]string
+
+func (l testLabels) Label(address uint16) (string, bool) {
+	name, ok := l[address]
+	return name, ok
+}
+
+func TestDisassembleBytes(t *testing.T) {
+	code := []byte{
+		0x4C, 0xF5, 0xC5, // JMP $C5F5
+		0xA9, 0x10, // LDA #$10
+		0xB1, 0x20, // LDA ($20),Y
+		0x6C, 0x00, 0x02, // JMP ($0200)
+		0xD0, 0xFE, // BNE *
+		0x0A,       // ASL A
+		0x04, 0xA9, // NOP $A9 (unofficial)
+		0x02,       // Invalid.
+		0xAD, 0x00, // Truncated.
+	}
+
+	expected := []string{
+		"C000  4C F5 C5  JMP $C5F5",
+		"C003  A9 10     LDA #$10",
+		"C005  B1 20     LDA ($20),Y",
+		"C007  6C 00 02  JMP ($0200)",
+		"C00A  D0 FE     BNE $C00A",
+		"C00C  0A        ASL A",
+		"C00D  04 A9    *NOP $A9",
+		"C00F  02        .DB $02",
+		"C010  AD        .DB $AD",
+		"C011  00        BRK",
+	}
+
+	actual := DisassembleBytes(code, 0xC000, nil)
+
+	if len(actual) != len(expected) {
+		t.Fatalf("got %d instructions, expected %d\n", len(actual), len(expected))
+	}
+
+	for i := range expected {
+		if actual[i].String() != expected[i] {
+			t.Errorf("got %q, expected %q\n", actual[i], expected[i])
+		}
+	}
+}
+
+func TestDisassembleLabels(t *testing.T) {
+	labels := testLabels{0xC5F5: "main", 0xC000: "reset", 0x0020: "ptr"}
+	code := []byte{
+		0x4C, 0xF5, 0xC5, // JMP main
+		0xB1, 0x20, // LDA (ptr),Y
+	}
+
+	actual := DisassembleBytes(code, 0xC000, labels)
+
+	if actual[0].Label != "reset" || actual[0].Assembly() != "JMP main" {
+		t.Errorf("got %q (label %q)\n", actual[0].Assembly(), actual[0].Label)
+	}
+
+	if actual[1].Assembly() != "LDA (ptr),Y" {
+		t.Errorf("got %q\n", actual[1].Assembly())
+	}
+}
+
+func TestNearestLabel(t *testing.T) {
+	labels := testLabels{0xC000: "NMI_Handler", 0x0010: "ptr"}
+
+	code := []byte{
+		0xD0, 0xFE, // BNE NMI_Handler
+		0x4C, 0x01, 0xC0, // JMP NMI_Handler+1
+		0xAD, 0x11, 0x00, // LDA $0011
+	}
+
+	actual := DisassembleBytes(code, 0xC000, labels)
+	for i, expected := range []string{"BNE NMI_Handler", "JMP NMI_Handler+1", "LDA $0011"} {
+		if actual[i].Assembly() != expected {
+			t.Errorf("got %q, expected %q\n", actual[i].Assembly(), expected)
+		}
+	}
+
+	if name, _ := NearestLabel(labels, 0xC003); name != "NMI_Handler+3" {
+		t.Errorf("NearestLabel(C003)=%q, expected NMI_Handler+3\n", name)
+	}
+
+	if name, ok := NearestLabel(labels, 0xC100); ok {
+		t.Errorf("NearestLabel(C100)=%q, expected none\n", name)
+	}
+
+	var cpu *CPU = newTestConsole([]byte{0x02}).CPU
+	cpu.Labels = testLabels{0x7FFE: "main"}
+
+	if _, err := cpu.Step(); err == nil || err.Error() != "invalid instruction 2 @ PC=main+2" {
+		t.Errorf("Step() error %v, expected invalid instruction 2 @ PC=main+2\n", err)
+	}
+}
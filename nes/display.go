@@ -0,0 +1,147 @@
+package nes
+
+import (
+	"image"
+)
+
+// Pixel aspect ratio of NES video: pixels are 8/7 as wide as they are tall.
+//
+// http://wiki.nesdev.com/w/index.php/Overscan
+const (
+	PixelAspectWidth  = 8
+	PixelAspectHeight = 7
+)
+
+// Overscan is the number of rows and columns of NES pixels to crop from each
+// edge of frames. TVs hid roughly the outer 8 pixels, which often contain
+// garbage such as scroll seams and mid-frame palette changes.
+type Overscan struct {
+	Top, Bottom, Left, Right int
+}
+
+// A Display crops overscan from frames and corrects their aspect ratio, to
+// show them as they were seen on a TV.
+type Display struct {
+	Overscan Overscan
+
+	// If true, frames are stretched horizontally to the 8:7 pixel aspect
+	// ratio.
+	AspectCorrection bool
+
+	cropped   *image.RGBA
+	stretched *image.RGBA
+}
+
+// Size returns the size of the images output by Apply for a FrameWidth x
+// FrameHeight frame, i.e. the size of frames as shown on the display in NES
+// pixels.
+func (d *Display) Size() (int, int) {
+	return d.ScaledSize(1)
+}
+
+// ScaledSize returns the size of the images output by Apply for a frame which
+// has been scaled by scale, e.g. by an upscaling filter.
+func (d *Display) ScaledSize(scale int) (int, int) {
+	var width int = (FrameWidth - d.Overscan.Left - d.Overscan.Right) * scale
+	var height int = (FrameHeight - d.Overscan.Top - d.Overscan.Bottom) * scale
+
+	if d.AspectCorrection {
+		width = aspectWidth(width)
+	}
+
+	return width, height
+}
+
+// Apply returns img cropped and stretched. img is a frame, or a frame which
+// has been scaled (e.g. by an upscaling filter or NTSCConverter), in which
+// case the overscan is scaled to match, even if the scale isn't a whole
+// number. The image may be reused by later calls.
+func (d *Display) Apply(img *image.RGBA) *image.RGBA {
+	var width int = img.Rect.Dx()
+	var height int = img.Rect.Dy()
+
+	var o Overscan = d.Overscan
+	if o != (Overscan{}) {
+		r := image.Rect(
+			o.Left*width/FrameWidth,
+			o.Top*height/FrameHeight,
+			width-o.Right*width/FrameWidth,
+			height-o.Bottom*height/FrameHeight)
+		r = r.Add(img.Rect.Min).Intersect(img.Rect)
+
+		var dst *image.RGBA = reuseImage(&d.cropped, r.Dx(), r.Dy())
+		for y := 0; y < r.Dy(); y++ {
+			var i int = img.PixOffset(r.Min.X, r.Min.Y+y)
+			copy(dst.Pix[y*dst.Stride:y*dst.Stride+r.Dx()*4], img.Pix[i:i+r.Dx()*4])
+		}
+
+		img = dst
+	}
+
+	if d.AspectCorrection {
+		var dst *image.RGBA = reuseImage(&d.stretched, aspectWidth(img.Rect.Dx()), img.Rect.Dy())
+		stretch(dst, img)
+
+		img = dst
+	}
+
+	return img
+}
+
+// Returns width stretched to the pixel aspect ratio, rounded.
+func aspectWidth(width int) int {
+	return (width*PixelAspectWidth + PixelAspectHeight/2) / PixelAspectHeight
+}
+
+// Returns *img if it's an image of width x height, or else a new image which
+// is stored in *img.
+func reuseImage(img **image.RGBA, width int, height int) *image.RGBA {
+	if *img == nil || (*img).Rect.Dx() != width || (*img).Rect.Dy() != height {
+		*img = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+
+	return *img
+}
+
+// Scales src horizontally to the width of dst, which must have the same
+// height. Each output pixel is the average of the input pixels it covers,
+// weighted by coverage, so pixels stay sharp except at the columns where
+// they're split.
+func stretch(dst *image.RGBA, src *image.RGBA) {
+	var srcWidth int = src.Rect.Dx()
+	var dstWidth int = dst.Rect.Dx()
+
+	for y := 0; y < src.Rect.Dy(); y++ {
+		var srcRow []uint8 = src.Pix[src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y):]
+		var dstRow []uint8 = dst.Pix[y*dst.Stride:]
+
+		for x := 0; x < dstWidth; x++ {
+			// The output pixel covers [start, end) of the input, in units
+			// of 1/dstWidth of an input pixel.
+			var start int = x * srcWidth
+			var end int = start + srcWidth
+
+			var sums [4]int
+
+			for sx := start / dstWidth; sx*dstWidth < end; sx++ {
+				var from int = sx * dstWidth
+				if from < start {
+					from = start
+				}
+
+				var to int = (sx + 1) * dstWidth
+				if to > end {
+					to = end
+				}
+
+				for channel := range sums {
+					sums[channel] += int(srcRow[sx*4+channel]) * (to - from)
+				}
+			}
+
+			for channel, sum := range sums {
+				dstRow[x*4+channel] = uint8(sum / srcWidth)
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package nes
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Returns an image of width x height whose pixels are coloured by position.
+func testDisplayImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), 0, 0xFF})
+		}
+	}
+
+	return img
+}
+
+func TestDisplayOverscan(t *testing.T) {
+	d := &Display{Overscan: Overscan{Top: 8, Bottom: 8, Left: 2, Right: 6}}
+
+	if width, height := d.Size(); width != 248 || height != 224 {
+		t.Errorf("size is %dx%d, expected 248x224\n", width, height)
+	}
+
+	img := d.Apply(testDisplayImage(FrameWidth, FrameHeight))
+
+	if img.Rect.Dx() != 248 || img.Rect.Dy() != 224 {
+		t.Fatalf("image is %v, expected 248x224\n", img.Rect)
+	}
+
+	if c := img.RGBAAt(0, 0); c.R != 2 || c.G != 8 {
+		t.Errorf("top left pixel is from (%d, %d), expected (2, 8)\n", c.R, c.G)
+	}
+
+	if c := img.RGBAAt(247, 223); c.R != 249 || c.G != 231 {
+		t.Errorf("bottom right pixel is from (%d, %d), expected (249, 231)\n", c.R, c.G)
+	}
+
+	// Scaled frames have the overscan scaled to match.
+	img = d.Apply(testDisplayImage(FrameWidth*2, FrameHeight*2))
+
+	if img.Rect.Dx() != 496 || img.Rect.Dy() != 448 {
+		t.Fatalf("scaled image is %v, expected 496x448\n", img.Rect)
+	}
+
+	if c := img.RGBAAt(0, 0); c.R != 4 || c.G != 16 {
+		t.Errorf("scaled top left pixel is from (%d, %d), expected (4, 16)\n", c.R, c.G)
+	}
+
+	// Frames scaled by a fraction, e.g. 602px wide frames from an NTSC filter,
+	// have the overscan scaled in proportion: 2*602/256 and 6*602/256 columns,
+	// rounded down.
+	img = d.Apply(testDisplayImage(602, FrameHeight))
+
+	if img.Rect.Dx() != 584 || img.Rect.Dy() != 224 {
+		t.Fatalf("fractionally scaled image is %v, expected 584x224\n", img.Rect)
+	}
+
+	if c := img.RGBAAt(0, 0); c.R != 4 || c.G != 8 {
+		t.Errorf("fractionally scaled top left pixel is from (%d, %d), expected (4, 8)\n", c.R, c.G)
+	}
+}
+
+func TestDisplayAspectCorrection(t *testing.T) {
+	d := &Display{AspectCorrection: true}
+
+	if width, height := d.Size(); width != 293 || height != 240 {
+		t.Errorf("size is %dx%d, expected 293x240\n", width, height)
+	}
+
+	// The size of scaled frames is stretched after scaling, as by Apply.
+	if width, height := d.ScaledSize(2); width != 585 || height != 480 {
+		t.Errorf("scaled size is %dx%d, expected 585x480\n", width, height)
+	}
+
+	if img := d.Apply(testDisplayImage(FrameWidth*2, FrameHeight*2)); img.Rect.Dx() != 585 || img.Rect.Dy() != 480 {
+		t.Errorf("scaled image is %v, expected 585x480\n", img.Rect)
+	}
+
+	// A black and a white column, each 7 pixels wide.
+	src := image.NewRGBA(image.Rect(0, 0, 14, 1))
+	for x := 0; x < 14; x++ {
+		var level uint8
+		if x >= 7 {
+			level = 0xFF
+		}
+		src.SetRGBA(x, 0, color.RGBA{level, level, level, 0xFF})
+	}
+
+	img := d.Apply(src)
+
+	if img.Rect.Dx() != 16 || img.Rect.Dy() != 1 {
+		t.Fatalf("image is %v, expected 16x1\n", img.Rect)
+	}
+
+	// Each becomes 8 pixels wide.
+	for x := 0; x < 16; x++ {
+		var expected uint8
+		if x >= 8 {
+			expected = 0xFF
+		}
+
+		if c := img.RGBAAt(x, 0); c.R != expected || c.A != 0xFF {
+			t.Errorf("pixel %d is %v, expected %02X\n", x, c, expected)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package nes
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Width of the images output by EventViewer.Image: a pixel for each tick of a
+// scanline. The height is the number of scanlines in a frame, see
+// Region.Scanlines.
+const EventsWidth = 341
+
+// Kinds of Event.
+type EventKind int
+
+const (
+	EventRegisterWrite EventKind = iota // CPU write to a PPU register ($2000-$2007).
+	EventOAMDMA                         // CPU write to $4014.
+	EventMapperWrite                    // CPU write to a mapper register ($4020-$5FFF, $8000-$FFFF).
+	EventNMI                            // NMI requested by the PPU.
+	EventIRQ                            // IRQ taken by the CPU.
+)
+
+// An Event is something which happened on a particular tick of a scanline.
+type Event struct {
+	Kind     EventKind
+	Scanline int
+	Tick     int
+
+	// Address and value written, for writes.
+	Address uint16
+	Value   byte
+}
+
+// String returns a description of the event, e.g. "$2005 = $00 at 120:256".
+func (e Event) String() string {
+	var description string
+
+	switch e.Kind {
+	case EventNMI:
+		description = "NMI"
+	case EventIRQ:
+		description = "IRQ"
+	default:
+		description = fmt.Sprintf("$%04X = $%02X", e.Address, e.Value)
+	}
+
+	return fmt.Sprintf("%s at %d:%d", description, e.Scanline, e.Tick)
+}
+
+// An EventViewer records the PPU register writes, OAM DMA, mapper register
+// writes and interrupts in each frame, with the scanline and tick they
+// happened on, for finding raster timing problems.
+//
+// Attach one with NewEventViewer.
+type EventViewer struct {
+	// Events in the last complete frame, in order.
+	Events []Event
+
+	console *Console
+
+	// Events in the current frame.
+	current []Event
+}
+
+// Marker colours for events, by kind, and for PPU register writes by
+// register.
+var eventColours = map[EventKind]color.RGBA{
+	EventOAMDMA:      {0xFF, 0x80, 0xC0, 0xFF},
+	EventMapperWrite: {0x40, 0xE0, 0x40, 0xFF},
+	EventNMI:         {0xFF, 0xFF, 0xFF, 0xFF},
+	EventIRQ:         {0xFF, 0xFF, 0x00, 0xFF},
+}
+
+var registerColours = [8]color.RGBA{
+	{0xFF, 0x40, 0x40, 0xFF}, // $2000
+	{0xFF, 0xA0, 0x20, 0xFF}, // $2001
+	{0xA0, 0xA0, 0xA0, 0xFF}, // $2002
+	{0xC0, 0xC0, 0x60, 0xFF}, // $2003
+	{0x80, 0xFF, 0x80, 0xFF}, // $2004
+	{0x40, 0xE0, 0xE0, 0xFF}, // $2005
+	{0x40, 0x80, 0xFF, 0xFF}, // $2006
+	{0xC0, 0x60, 0xFF, 0xFF}, // $2007
+}
+
+// NewEventViewer returns an EventViewer recording events in console.
+func NewEventViewer(console *Console) *EventViewer {
+	v := &EventViewer{console: console}
+	console.EventViewer = v
+
+	return v
+}
+
+// Image returns the events of the last complete frame as coloured markers on
+// an image of the frame's timing, EventsWidth wide with a row for each
+// scanline. Visible pixels are shown light grey, horizontal blanking mid grey,
+// and vertical blanking (and Dendy's idle scanlines) dark grey.
+func (v *EventViewer) Image() *image.RGBA {
+	var region Region = v.console.region
+	img := image.NewRGBA(image.Rect(0, 0, EventsWidth, region.Scanlines()))
+
+	for y := 0; y < region.Scanlines(); y++ {
+		for x := 0; x < EventsWidth; x++ {
+			var level uint8 = 0x30
+			if y < FrameHeight || y == region.PrerenderScanline() {
+				level = 0x50
+			}
+			if y < FrameHeight && x >= 1 && x <= 256 {
+				level = 0x70
+			}
+
+			img.SetRGBA(x, y, color.RGBA{level, level, level, 0xFF})
+		}
+	}
+
+	for _, event := range v.Events {
+		var colour color.RGBA = eventColours[event.Kind]
+		if event.Kind == EventRegisterWrite {
+			colour = registerColours[event.Address&0x7]
+		}
+
+		// A 3x3 marker centred on the tick.
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				img.SetRGBA(event.Tick+dx, event.Scanline+dy, colour)
+			}
+		}
+	}
+
+	return img
+}
+
+// Records a CPU write, if it's one of the events recorded. Called before the
+// write.
+func (v *EventViewer) write(address uint16, value byte) {
+	var kind EventKind
+
+	switch {
+	case address >= 0x2000 && address < 0x4000:
+		kind = EventRegisterWrite
+		address = 0x2000 | address&0x7
+	case address == 0x4014:
+		kind = EventOAMDMA
+	case address >= 0x4020 && address < 0x6000, address >= 0x8000:
+		kind = EventMapperWrite
+	default:
+		return
+	}
+
+	v.record(kind, address, value, v.console.CPU.accessCycle)
+}
+
+// Records an NMI requested by the PPU.
+func (v *EventViewer) nmi() {
+	var ppu *PPU = v.console.PPU
+	v.add(Event{Kind: EventNMI, Scanline: ppu.Scanline, Tick: ppu.Tick})
+}
+
+// Records an IRQ, when the CPU takes it at the start of an instruction.
+func (v *EventViewer) irq() {
+	v.record(EventIRQ, 0, 0, v.console.CPU.NumCycles)
+}
+
+// Records an event which happened on the given CPU cycle.
+//
+// The PPU runs behind the CPU (except when PPU registers are accessed), so
+// the tick is found by counting on from the PPU's current tick, the one run
+// on PPU cycle numCycles-1.
+func (v *EventViewer) record(kind EventKind, address uint16, value byte, cpuCycle uint64) {
+	var ppu *PPU = v.console.PPU
+	var region Region = v.console.region
+
+	var ticks int
+	if cycle := region.ppuCycles(cpuCycle) + 1; cycle > ppu.numCycles {
+		ticks = int(cycle - ppu.numCycles)
+	}
+
+	var tick int = ppu.Tick + ticks
+	var scanline int = (ppu.Scanline + tick/341) % region.Scanlines()
+	tick %= 341
+
+	v.add(Event{Kind: kind, Scanline: scanline, Tick: tick, Address: address, Value: value})
+}
+
+func (v *EventViewer) add(event Event) {
+	v.current = append(v.current, event)
+}
+
+// Called at the start of each frame.
+func (v *EventViewer) startFrame() {
+	v.Events = v.current
+	v.current = nil
+}
@@ -0,0 +1,67 @@
+package nes
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestEventViewer(t *testing.T) {
+	program := make([]byte, 0x30)
+	copy(program, []byte{
+		0xA9, 0x80, // $8000 LDA #$80
+		0x8D, 0x00, 0x20, // $8002 STA $2000
+		0x4C, 0x05, 0x80, // $8005 JMP $8005
+	})
+	copy(program[0x20:], []byte{
+		0xA9, 0x00, // $8020 LDA #$00
+		0x8D, 0x05, 0x20, // $8022 STA $2005
+		0x8D, 0x05, 0x20, // $8025 STA $2005
+		0x8D, 0x00, 0x50, // $8028 STA $5000
+		0x40, //             $802B RTI
+	})
+
+	console := newTestConsole(program)
+	console.Cart.PRG[1][0x3FFA] = 0x20
+	console.Cart.PRG[1][0x3FFB] = 0x80
+
+	viewer := NewEventViewer(console)
+
+	// The events of the second frame.
+	for i := 0; i < 3; i++ {
+		runFrame(t, console)
+	}
+
+	if len(viewer.Events) != 4 {
+		t.Fatalf("got events %v, expected 4\n", viewer.Events)
+	}
+
+	// The NMI is requested 2 ticks after VBlank starts.
+	var nmi Event = viewer.Events[0]
+	if nmi.Kind != EventNMI || nmi.Scanline != 241 || nmi.Tick != 3 {
+		t.Errorf("got %v, expected NMI at 241:3\n", nmi)
+	}
+
+	kinds := []EventKind{EventRegisterWrite, EventRegisterWrite, EventMapperWrite}
+	addresses := []uint16{0x2005, 0x2005, 0x5000}
+
+	for i, event := range viewer.Events[1:] {
+		if event.Kind != kinds[i] || event.Address != addresses[i] || event.Scanline != 241 {
+			t.Errorf("got %v, expected write to $%04X on scanline 241\n", event, addresses[i])
+		}
+	}
+
+	// The writes are 4 CPU cycles apart.
+	if viewer.Events[2].Tick-viewer.Events[1].Tick != 12 || viewer.Events[3].Tick-viewer.Events[2].Tick != 12 {
+		t.Errorf("got writes %v, expected 12 ticks apart\n", viewer.Events[1:])
+	}
+
+	img := viewer.Image()
+	var write Event = viewer.Events[1]
+	if img.RGBAAt(write.Tick, write.Scanline) != registerColours[5] {
+		t.Errorf("no marker for %v\n", write)
+	}
+
+	if img.RGBAAt(100, 100) != (color.RGBA{0x70, 0x70, 0x70, 0xFF}) {
+		t.Errorf("unexpected colour %v for visible area\n", img.RGBAAt(100, 100))
+	}
+}
@@ -8,30 +8,73 @@ import (
 
 	"github.com/go-gl/gl/v2.1/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/skip2/nes/filter"
 )
 
-// Window dimensions.
+// Logical window dimensions, without a Display. The window is resizable, and
+// frames are drawn at the largest integer multiple of the logical size which
+// fits.
 const windowWidth = 256
 const windowHeight = 240
 
+// Initial window scale.
+const windowScale = 2
+
 type GUI struct {
+	// Filter to upscale frames with before drawing them, or nil.
+	Filter filter.Filter
+
+	// Display to crop and stretch frames with after filtering, or nil.
+	Display *Display
+
+	// If true, the PPU viewers (nametables, pattern tables, palette RAM and
+	// OAM) are shown in extra windows, updated each frame.
+	PPUViewers bool
+
 	console *Console
 	window  *glfw.Window
+	viewers []*viewerWindow
+
+	// The only sprite shown, or -1 for all.
+	soloSprite int
+
+	// Palette RAM palette the pattern table viewers are coloured with, 0-7.
+	patternPalette int
+}
+
+// A window showing an image, e.g. a PPU viewer.
+type viewerWindow struct {
+	window *glfw.Window
+
+	// Logical size of the image.
+	width  int
+	height int
+
+	// Returns the image to show.
+	render func() *image.RGBA
 }
 
 // NewGUI returns using the given console.
 func NewGUI(console *Console) *GUI {
-	return &GUI{console: console}
+	return &GUI{console: console, soloSprite: -1}
 }
 
 func init() {
 	runtime.LockOSThread()
 }
 
-// Run opens a small 256x240px GUI window and runs the console.
+// Run opens a resizable GUI window, initially 512x480px (less any overscan) or
+// the size of one filtered frame if larger, and runs the console.
 //
 // Input is via the arrow keys, enter, space, Z, X. Pressing S saves a
-// screenshot to "screenshot.png".
+// screenshot (after filtering, cropping and stretching) to "screenshot.png".
+//
+// Ctrl+R presses the reset button, and Ctrl+T power cycles the console.
+//
+// For debugging, F1 and F2 toggle the background and sprite layers, and F3
+// steps through showing only sprite 0, 1, ... 63, then all sprites again. F4
+// cycles the palette the pattern table viewers are coloured with through
+// palettes 0-7 (0-3 background, 4-7 sprites).
 //
 // The function terminates when the Q key is pressed, or an error occurs.
 func (g *GUI) Run() error {
@@ -41,13 +84,24 @@ func (g *GUI) Run() error {
 	}
 	defer glfw.Terminate()
 
-	glfw.WindowHint(glfw.Resizable, glfw.False)
+	glfw.WindowHint(glfw.Resizable, glfw.True)
 
-	g.window, err = glfw.CreateWindow(windowWidth, windowHeight, "NES emulator", nil, nil)
+	width, height := g.logicalSize()
+
+	// Frames are only drawn at whole multiples of their filtered size, so that
+	// filtered pixels stay sharp.
+	var scale int = windowScale / g.filterScale()
+	if scale < 1 {
+		scale = 1
+	}
+
+	g.window, err = glfw.CreateWindow(width*scale, height*scale, "NES emulator", nil, nil)
 	if err != nil {
 		return err
 	}
 
+	g.window.SetSizeLimits(width, height, glfw.DontCare, glfw.DontCare)
+
 	g.window.MakeContextCurrent()
 
 	if err := gl.Init(); err != nil {
@@ -66,6 +120,8 @@ func (g *GUI) Run() error {
 		console.Joypads[0].Right = g.isKeyPressed(glfw.KeyRight)
 	})
 
+	g.window.SetKeyCallback(g.onKey)
+
 	gl.ClearColor(0.0, 0.0, 0.0, 0.0)
 
 	gl.MatrixMode(gl.PROJECTION)
@@ -74,6 +130,13 @@ func (g *GUI) Run() error {
 	gl.MatrixMode(gl.MODELVIEW)
 	gl.LoadIdentity()
 
+	if g.PPUViewers {
+		err = g.openViewers()
+		if err != nil {
+			return err
+		}
+	}
+
 	for !g.window.ShouldClose() {
 		image, err := console.Step()
 		if err != nil {
@@ -81,7 +144,16 @@ func (g *GUI) Run() error {
 		}
 
 		if image != nil {
+			if g.Filter != nil {
+				image = g.Filter.Apply(image)
+			}
+
+			if g.Display != nil {
+				image = g.Display.Apply(image)
+			}
+
 			g.doRedraw(image)
+			g.redrawViewers()
 			glfw.PollEvents()
 
 			if g.isKeyPressed(glfw.KeyS) {
@@ -98,6 +170,26 @@ func (g *GUI) Run() error {
 	return nil
 }
 
+// Returns the logical window size.
+func (g *GUI) logicalSize() (int, int) {
+	var scale int = g.filterScale()
+
+	if g.Display != nil {
+		return g.Display.ScaledSize(scale)
+	}
+
+	return windowWidth * scale, windowHeight * scale
+}
+
+// Returns the factor by which the filter scales frames, 1 if there's none.
+func (g *GUI) filterScale() int {
+	if g.Filter == nil {
+		return 1
+	}
+
+	return g.Filter.Scale()
+}
+
 // Saves the image as "screenshot.png".
 func (g *GUI) saveScreenshot(image *image.RGBA) error {
 	filename := "screenshot.png"
@@ -116,17 +208,127 @@ func (g *GUI) saveScreenshot(image *image.RGBA) error {
 	return nil
 }
 
+// Opens the PPU viewer windows.
+func (g *GUI) openViewers() error {
+	var ppu *PPU = g.console.PPU
+
+	type viewer struct {
+		title         string
+		width, height int
+		render        func() *image.RGBA
+	}
+
+	viewers := []viewer{
+		{"Nametables", NametablesWidth, NametablesHeight, func() *image.RGBA {
+			return ppu.NametablesImage(ppu.Palette())
+		}},
+		{"Pattern table 0", PatternTableSize, PatternTableSize, func() *image.RGBA {
+			return ppu.PatternTableImage(0, g.patternPalette, ppu.Palette())
+		}},
+		{"Pattern table 1", PatternTableSize, PatternTableSize, func() *image.RGBA {
+			return ppu.PatternTableImage(1, g.patternPalette, ppu.Palette())
+		}},
+		{"Palette RAM", PaletteRAMWidth, PaletteRAMHeight, func() *image.RGBA {
+			return ppu.PaletteRAMImage(ppu.Palette())
+		}},
+		{"OAM", OAMWidth, OAMHeight, func() *image.RGBA {
+			return ppu.OAMImage(ppu.Palette())
+		}},
+	}
+
+	if events := g.console.EventViewer; events != nil {
+		viewers = append(viewers, viewer{"Events", EventsWidth, g.console.region.Scanlines(), events.Image})
+	}
+
+	for _, v := range viewers {
+		// Small images are shown at 2x.
+		var scale int = 1
+		if v.width < 256 {
+			scale = 2
+		}
+
+		window, err := glfw.CreateWindow(v.width*scale, v.height*scale, v.title, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		window.SetSizeLimits(v.width, v.height, glfw.DontCare, glfw.DontCare)
+		window.SetKeyCallback(g.onKey)
+
+		g.viewers = append(g.viewers, &viewerWindow{
+			window: window,
+			width:  v.width,
+			height: v.height,
+			render: v.render,
+		})
+	}
+
+	g.window.MakeContextCurrent()
+
+	return nil
+}
+
+// Redraws the PPU viewer windows, and closes those the user has closed.
+func (g *GUI) redrawViewers() {
+	if len(g.viewers) == 0 {
+		return
+	}
+
+	var open []*viewerWindow
+
+	for _, viewer := range g.viewers {
+		if viewer.window.ShouldClose() {
+			viewer.window.Destroy()
+			continue
+		}
+
+		viewer.window.MakeContextCurrent()
+		drawImage(viewer.window, viewer.render(), viewer.width, viewer.height)
+		open = append(open, viewer)
+	}
+
+	g.viewers = open
+	g.window.MakeContextCurrent()
+}
+
 // Redraws the screen with the image rgba.
+func (g *GUI) doRedraw(rgba *image.RGBA) {
+	width, height := g.logicalSize()
+	drawImage(g.window, rgba, width, height)
+}
+
+// Draws the image rgba in window, whose context must be current. The image is
+// scaled to the largest integer multiple of the logical size width x height
+// which fits the window, and centred.
 //
 // https://github.com/go-gl/examples/blob/master/glfw31-gl21-cube/cube.go
-func (g *GUI) doRedraw(rgba *image.RGBA) {
+func drawImage(window *glfw.Window, rgba *image.RGBA, logicalWidth int, logicalHeight int) {
 	var texture uint32
 
+	width, height := window.GetFramebufferSize()
+
+	gl.Viewport(0, 0, int32(width), int32(height))
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	var scale int = width / logicalWidth
+	if height/logicalHeight < scale {
+		scale = height / logicalHeight
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	gl.Viewport(
+		int32((width-logicalWidth*scale)/2),
+		int32((height-logicalHeight*scale)/2),
+		int32(logicalWidth*scale),
+		int32(logicalHeight*scale))
+
 	gl.Enable(gl.TEXTURE_2D)
 	gl.GenTextures(1, &texture)
 	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
 	gl.TexImage2D(
@@ -140,8 +342,6 @@ func (g *GUI) doRedraw(rgba *image.RGBA) {
 		gl.UNSIGNED_BYTE,
 		gl.Ptr(rgba.Pix))
 
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-
 	gl.MatrixMode(gl.MODELVIEW)
 	gl.LoadIdentity()
 
@@ -165,7 +365,43 @@ func (g *GUI) doRedraw(rgba *image.RGBA) {
 
 	gl.DeleteTextures(1, &texture)
 
-	g.window.SwapBuffers()
+	window.SwapBuffers()
+}
+
+// Handles the reset, power cycle and layer toggle keys.
+func (g *GUI) onKey(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	if action != glfw.Press {
+		return
+	}
+
+	var ppu *PPU = g.console.PPU
+
+	var isControl bool = mods&glfw.ModControl != 0
+
+	switch key {
+	case glfw.KeyR:
+		if isControl {
+			g.console.Reset()
+		}
+	case glfw.KeyT:
+		if isControl {
+			g.console.PowerCycle()
+		}
+	case glfw.KeyF1:
+		ppu.HideBackground = !ppu.HideBackground
+	case glfw.KeyF2:
+		ppu.HideSprites = !ppu.HideSprites
+	case glfw.KeyF3:
+		g.soloSprite++
+		if g.soloSprite == 64 {
+			g.soloSprite = -1
+			ppu.HiddenSprites = 0
+		} else {
+			ppu.HiddenSprites = ^(uint64(1) << uint(g.soloSprite))
+		}
+	case glfw.KeyF4:
+		g.patternPalette = (g.patternPalette + 1) % 8
+	}
 }
 
 // Returns true if the key is currently pressed.
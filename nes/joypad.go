@@ -44,6 +44,21 @@ func (j *Joypad) SetReadKeysCallback(callback func()) {
 
 // Read reads a byte from the joypad's output register.
 func (j *Joypad) Read() byte {
+	result := j.Peek()
+
+	if !j.strobe {
+		j.i++
+		if j.i == 8 {
+			j.i = 0
+		}
+	}
+
+	return result
+}
+
+// Peek returns the byte the next Read would return, without advancing the
+// joypad's shift register.
+func (j *Joypad) Peek() byte {
 	var pressed bool
 
 	if j.strobe {
@@ -69,11 +84,6 @@ func (j *Joypad) Read() byte {
 		default:
 			// Ignored.
 		}
-
-		j.i++
-		if j.i == 8 {
-			j.i = 0
-		}
 	}
 
 	if pressed {
@@ -11,8 +11,20 @@ import (
 // counting.
 //
 // http://wiki.nesdev.com/w/index.php/Mapper
+//
+// Peek reads a byte like Read, but must not have any side effects (e.g. IRQ
+// acknowledgement or latch updates), and returns 0 for unmapped addresses.
+//
+// PRGOffset returns the offset into the PRG ROM (counting from the start of
+// the first bank) currently mapped at CPU address, or -1 if address isn't
+// mapped to PRG ROM. CHROffset does the same for CHR memory and PPU address.
+//
+// IRQ returns true if the IRQ line is asserted, without side effects.
 type Mapper interface {
 	Read(address uint16, isPPU bool) byte
+	Peek(address uint16, isPPU bool) byte
+	PRGOffset(address uint16) int
+	CHROffset(address uint16) int
 	Write(address uint16, value byte, isPPU bool)
 	IRQ() bool
 	NextScanline()
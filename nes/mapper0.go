@@ -31,13 +31,24 @@ func NewMapper0(cart *Cartridge) *Mapper0 {
 }
 
 func (m *Mapper0) Read(address uint16, isPPU bool) byte {
+	if isPPU && address >= 0x2000 {
+		log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n",
+			address)
+	} else if !isPPU && address < 0x6000 {
+		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n",
+			address)
+	}
+
+	return m.Peek(address, isPPU)
+}
+
+func (m *Mapper0) Peek(address uint16, isPPU bool) byte {
 	if isPPU {
 		if address < 0x2000 {
 			return m.CHR[0][address]
-		} else {
-			log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n",
-				address)
 		}
+
+		return 0
 	}
 
 	var result byte
@@ -49,14 +60,30 @@ func (m *Mapper0) Read(address uint16, isPPU bool) byte {
 		result = m.PRG[m.prgBank1][address-0x8000]
 	case address >= 0x6000:
 		result = m.SRAM[0][address-0x6000]
-	default:
-		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n",
-			address)
 	}
 
 	return result
 }
 
+func (m *Mapper0) PRGOffset(address uint16) int {
+	switch {
+	case address >= 0xC000:
+		return m.prgBank2*0x4000 + int(address-0xC000)
+	case address >= 0x8000:
+		return m.prgBank1*0x4000 + int(address-0x8000)
+	}
+
+	return -1
+}
+
+func (m *Mapper0) CHROffset(address uint16) int {
+	if address < 0x2000 {
+		return int(address)
+	}
+
+	return -1
+}
+
 func (m *Mapper0) Write(address uint16, value byte, isPPU bool) {
 	if !isPPU && address >= 0x6000 && address < 0x8000 {
 		m.SRAM[0][address-0x6000] = value
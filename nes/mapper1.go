@@ -35,6 +35,16 @@ func NewMapper1(cart *Cartridge) *Mapper1 {
 }
 
 func (m *Mapper1) Read(address uint16, isPPU bool) byte {
+	if isPPU && address >= 0x2000 {
+		log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n", address)
+	} else if !isPPU && address < 0x6000 {
+		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n", address)
+	}
+
+	return m.Peek(address, isPPU)
+}
+
+func (m *Mapper1) Peek(address uint16, isPPU bool) byte {
 	if isPPU {
 		if m.chr8kMode {
 			if address < 0x2000 {
@@ -48,7 +58,7 @@ func (m *Mapper1) Read(address uint16, isPPU bool) byte {
 			}
 		}
 
-		log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n", address)
+		return 0
 	}
 
 	if address >= 0x6000 && address <= 0x7FFF {
@@ -56,7 +66,7 @@ func (m *Mapper1) Read(address uint16, isPPU bool) byte {
 	}
 
 	if address < 0x6000 {
-		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n", address)
+		return 0
 	}
 
 	var result byte
@@ -85,6 +95,50 @@ func (m *Mapper1) Read(address uint16, isPPU bool) byte {
 	return result
 }
 
+func (m *Mapper1) PRGOffset(address uint16) int {
+	if address < 0x8000 {
+		return -1
+	}
+
+	var bank int
+
+	switch m.prgBankMode {
+	case 0, 1:
+		if address < 0xC000 {
+			bank = m.prgBank &^ 1
+		} else {
+			bank = m.prgBank | 1
+		}
+	case 2:
+		if address < 0xC000 {
+			bank = 0
+		} else {
+			bank = m.prgBank
+		}
+	case 3:
+		if address < 0xC000 {
+			bank = m.prgBank
+		} else {
+			bank = len(m.PRG) - 1
+		}
+	}
+
+	return bank*0x4000 + int(address&0x3FFF)
+}
+
+func (m *Mapper1) CHROffset(address uint16) int {
+	switch {
+	case address >= 0x2000:
+		return -1
+	case m.chr8kMode:
+		return m.chrBank[0]*0x2000 + int(address)
+	case address < 0x1000:
+		return m.chrBank[0]*0x2000 + int(m.chrBankOffset[0]+address)
+	}
+
+	return m.chrBank[1]*0x2000 + int(m.chrBankOffset[1]+address-0x1000)
+}
+
 func (m *Mapper1) Write(address uint16, value byte, isPPU bool) {
 	if isPPU {
 		if address < 0x2000 {
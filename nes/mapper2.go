@@ -23,13 +23,24 @@ func NewMapper2(cart *Cartridge) *Mapper2 {
 }
 
 func (m *Mapper2) Read(address uint16, isPPU bool) byte {
+	if isPPU && address >= 0x2000 {
+		log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n",
+			address)
+	} else if !isPPU && address < 0x8000 {
+		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n",
+			address)
+	}
+
+	return m.Peek(address, isPPU)
+}
+
+func (m *Mapper2) Peek(address uint16, isPPU bool) byte {
 	if isPPU {
 		if address < 0x2000 {
 			return m.CHR[0][address]
-		} else {
-			log.Fatalf("Unmapped ReadMem address=%x (isPPU)\n",
-				address)
 		}
+
+		return 0
 	}
 
 	var result byte
@@ -39,14 +50,30 @@ func (m *Mapper2) Read(address uint16, isPPU bool) byte {
 		result = m.PRG[m.prgLastBank][address-0xC000]
 	case address >= 0x8000:
 		result = m.PRG[m.prgSwitchableBank][address-0x8000]
-	default:
-		log.Fatalf("Unmapped ReadMem address=%x (!isPPU)\n",
-			address)
 	}
 
 	return result
 }
 
+func (m *Mapper2) PRGOffset(address uint16) int {
+	switch {
+	case address >= 0xC000:
+		return m.prgLastBank*0x4000 + int(address-0xC000)
+	case address >= 0x8000:
+		return m.prgSwitchableBank*0x4000 + int(address-0x8000)
+	}
+
+	return -1
+}
+
+func (m *Mapper2) CHROffset(address uint16) int {
+	if address < 0x2000 {
+		return int(address)
+	}
+
+	return -1
+}
+
 func (m *Mapper2) Write(address uint16, value byte, isPPU bool) {
 	if isPPU && address < 0x2000 {
 		m.CHR[0][address] = value
@@ -7,7 +7,6 @@ package nes
 // http://wiki.nesdev.com/w/index.php/MMC3
 type Mapper4 struct {
 	*Cartridge
-	ram [8192]byte
 
 	prgBank       [4]int
 	prgBankOffset [4]uint16
@@ -83,11 +82,10 @@ func (m *Mapper4) NextScanline() {
 	}
 }
 
+// IRQ returns true while the IRQ line is asserted. The IRQ stays asserted
+// until acknowledged by a write to $E000.
 func (m *Mapper4) IRQ() bool {
-	isIRQ := m.irqAssert
-	m.irqAssert = false
-
-	return isIRQ
+	return m.irqAssert
 }
 
 func (m *Mapper4) Read(address uint16, isPPU bool) byte {
@@ -105,7 +103,7 @@ func (m *Mapper4) Read(address uint16, isPPU bool) byte {
 	} else {
 		switch {
 		case address >= 0x6000 && address <= 0x7FFF:
-			result = m.ram[address-0x6000]
+			result = m.SRAM[0][address-0x6000]
 		case address >= 0x8000 && address <= 0xFFFF:
 			bank := (address & 0x6000) >> 13
 			offset := address & 0x1FFF
@@ -116,13 +114,39 @@ func (m *Mapper4) Read(address uint16, isPPU bool) byte {
 	return result
 }
 
+func (m *Mapper4) Peek(address uint16, isPPU bool) byte {
+	return m.Read(address, isPPU)
+}
+
+func (m *Mapper4) PRGOffset(address uint16) int {
+	if address < 0x8000 {
+		return -1
+	}
+
+	bank := (address & 0x6000) >> 13
+	offset := address & 0x1FFF
+
+	return m.prgBank[bank]*0x4000 + int(m.prgBankOffset[bank]+offset)
+}
+
+func (m *Mapper4) CHROffset(address uint16) int {
+	if address >= 0x2000 {
+		return -1
+	}
+
+	bank := (address & 0x1C00) >> 10
+	offset := address & 0x3FF
+
+	return m.chgBank[bank]*0x2000 + int(m.chgBankOffset[bank]+offset)
+}
+
 func (m *Mapper4) Write(address uint16, value byte, isPPU bool) {
 	if !isPPU {
 		isEven := address&0x1 == 0
 
 		switch {
 		case address >= 0x6000 && address <= 0x7FFF:
-			m.ram[address-0x6000] = value
+			m.SRAM[0][address-0x6000] = value
 		case address >= 0x8000 && address <= 0x9FFF:
 			if isEven {
 				m.selectedBankRegister = int(value & 0x7)
@@ -143,7 +167,7 @@ func (m *Mapper4) Write(address uint16, value byte, isPPU bool) {
 			} else {
 				// PRG RAM protect not implemented.
 			}
-		case address >= 0xC000 && address <= 0xEFFF:
+		case address >= 0xC000 && address <= 0xDFFF:
 			if isEven {
 				m.irqLatch = value
 			} else {
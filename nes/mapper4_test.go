@@ -0,0 +1,32 @@
+package nes
+
+import (
+	"testing"
+)
+
+func TestMapper4IRQAcknowledge(t *testing.T) {
+	cart := NewCartridge(2, 1, 1)
+	m := NewMapper4(cart)
+
+	// IRQ on the scanline after the counter is reloaded with 1.
+	m.Write(0xC000, 1, false)
+	m.Write(0xC001, 0, false)
+	m.Write(0xE001, 0, false)
+
+	m.NextScanline()
+	m.NextScanline()
+
+	if !m.IRQ() {
+		t.Fatalf("IRQ not asserted\n")
+	}
+
+	if !m.IRQ() {
+		t.Fatalf("IRQ acknowledged by reading it\n")
+	}
+
+	m.Write(0xE000, 0, false)
+
+	if m.IRQ() {
+		t.Fatalf("IRQ still asserted after writing $E000\n")
+	}
+}
@@ -0,0 +1,167 @@
+package nes
+
+import (
+	"image"
+	"math"
+)
+
+// Width of the images output by an NTSCConverter: 3 pixels for each NES pixel.
+const NTSCWidth = FrameWidth * 3
+
+// Number of composite signal samples per NES pixel. The colour subcarrier has
+// 12 phases, so a pixel lasts 2/3 of a colour cycle.
+const ntscSamplesPerPixel = 8
+
+// An NTSCConverter converts frames to images by simulating the NES's NTSC
+// composite video output, in the spirit of Blargg's nes_ntsc. It outputs
+// images of NTSCWidth x 240px.
+//
+// Each scanline is generated as a composite signal from the pixels' colour
+// indexes and emphasis bits (as for the "composite" palette), then decoded
+// over a colour cycle at each output pixel. Colours therefore bleed between
+// neighbouring pixels, and fine luminance patterns decode as artifact
+// colours.
+//
+// Each scanline starts 4 phases of the colour subcarrier later than the last,
+// and the phase of each frame alternates, assuming rendering is enabled so odd
+// frames are a dot shorter. Chroma left in the decoded luminance (see
+// Crosstalk) forms a pattern of dots which crawls between frames.
+type NTSCConverter struct {
+	// Amount of chroma decoded as luminance, from 0 to 1.
+	Crosstalk float64
+
+	// If true each frame is decoded with the phases of both frames and
+	// averaged, removing dot crawl, as nes_ntsc's merge_fields option.
+	MergeFields bool
+
+	// Composite signal for each pixel value at each phase.
+	signal [512][12]float32
+
+	// Cosine and sine of the subcarrier at each phase, for decoding I and Q.
+	cos [12]float32
+	sin [12]float32
+
+	// Composite signal for a scanline, and running sums of its luma, I and Q.
+	samples []float32
+	sums    [][3]float32
+
+	frame uint64
+	img   *image.RGBA
+}
+
+// NewNTSCConverter returns an NTSCConverter with moderate crosstalk.
+func NewNTSCConverter() *NTSCConverter {
+	c := &NTSCConverter{
+		Crosstalk: 0.3,
+		samples:   make([]float32, FrameWidth*ntscSamplesPerPixel+24),
+		sums:      make([][3]float32, FrameWidth*ntscSamplesPerPixel+25),
+		img:       image.NewRGBA(image.Rect(0, 0, NTSCWidth, FrameHeight)),
+	}
+
+	for pixel := range c.signal {
+		for phase, level := range compositeSignal(uint16(pixel)) {
+			c.signal[pixel][phase] = float32(level)
+		}
+	}
+
+	for phase := range c.cos {
+		var angle float64 = chromaAngle(float64(phase))
+		c.cos[phase] = float32(math.Cos(angle))
+		c.sin[phase] = float32(math.Sin(angle))
+	}
+
+	return c
+}
+
+// Convert returns frame as an image. The image is reused by later calls.
+func (c *NTSCConverter) Convert(frame *FrameBuffer) *image.RGBA {
+	var fields []int = []int{int(c.frame % 2)}
+	if c.MergeFields {
+		fields = []int{0, 1}
+	}
+
+	for y := 0; y < FrameHeight; y++ {
+		var row []uint16 = frame[y*FrameWidth : (y+1)*FrameWidth]
+		var yiq [NTSCWidth][3]float32
+
+		for _, field := range fields {
+			var phase int = (y*4 + field*4) % 12
+
+			c.generate(row, phase)
+			c.decode(&yiq, float32(len(fields)))
+		}
+
+		var pix []byte = c.img.Pix[y*c.img.Stride:]
+		for x := range yiq {
+			colour := yiqToRGB(float64(yiq[x][0]), float64(yiq[x][1]), float64(yiq[x][2]))
+			pix[x*4] = colour.R
+			pix[x*4+1] = colour.G
+			pix[x*4+2] = colour.B
+			pix[x*4+3] = colour.A
+		}
+	}
+
+	c.frame++
+
+	return c.img
+}
+
+// Generates the composite signal for a row of pixels, starting at phase, and
+// the running sums used to decode it.
+//
+// The signal is padded by a colour cycle at each end, continuing the edge
+// pixels' signals.
+func (c *NTSCConverter) generate(row []uint16, phase int) {
+	const padding = 12
+	var length int = FrameWidth * ntscSamplesPerPixel
+
+	var p int = (phase + 12*padding - padding) % 12
+	var sums [3]float32
+
+	for n := -padding; n < length+padding; n++ {
+		// Position of a sample with the same phase within the row.
+		var x int = n
+		if n < 0 {
+			x += padding
+		} else if n >= length {
+			x -= padding
+		}
+
+		var sample float32 = c.signal[row[x/ntscSamplesPerPixel]&0x1FF][p]
+
+		sums[0] += sample
+		sums[1] += sample * c.cos[p]
+		sums[2] += sample * c.sin[p]
+
+		c.samples[n+padding] = sample
+		c.sums[n+padding+1] = sums
+
+		if p++; p == 12 {
+			p = 0
+		}
+	}
+}
+
+// Decodes the signal in c.samples over a colour cycle centred on each output
+// pixel. The YIQ colours, divided by scale, are added to yiq.
+func (c *NTSCConverter) decode(yiq *[NTSCWidth][3]float32, scale float32) {
+	var crosstalk float32 = float32(c.Crosstalk)
+
+	for x := range yiq {
+		// Index of the centre sample in the padded signal.
+		var centre int = x*ntscSamplesPerPixel/3 + 12
+
+		var start *[3]float32 = &c.sums[centre-6]
+		var end *[3]float32 = &c.sums[centre+6]
+
+		var luma float32 = (end[0] - start[0]) / 12
+
+		// Chroma not removed from the luminance.
+		luma += crosstalk * (c.samples[centre] - luma)
+
+		// Demodulating the chroma halves its amplitude.
+		yiq[x][0] += luma / scale
+		yiq[x][1] += (end[1] - start[1]) / 6 / scale
+		yiq[x][2] += (end[2] - start[2]) / 6 / scale
+	}
+}
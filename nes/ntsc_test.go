@@ -0,0 +1,60 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNTSCConverter(t *testing.T) {
+	c := NewNTSCConverter()
+	c.Crosstalk = 0
+
+	// A solid colour decodes as the composite palette's colour.
+	var frame FrameBuffer
+	for i := range frame {
+		frame[i] = 0x16
+	}
+
+	img := c.Convert(&frame)
+	if img.Rect.Dx() != NTSCWidth || img.Rect.Dy() != FrameHeight {
+		t.Fatalf("image is %v, expected %dx%d\n", img.Rect, NTSCWidth, FrameHeight)
+	}
+
+	var expected = compositePalette(0)[0x16]
+	for _, x := range []int{0, 100, NTSCWidth - 1} {
+		colour := img.RGBAAt(x, 100)
+		if diff(colour.R, expected.R) > 2 || diff(colour.G, expected.G) > 2 || diff(colour.B, expected.B) > 2 {
+			t.Errorf("pixel (%d, 100) is %v, expected %v\n", x, colour, expected)
+		}
+	}
+
+	// Alternating white and black pixels form a pattern of chroma dots, which
+	// crawls between frames unless fields are merged.
+	for i := range frame {
+		frame[i] = 0x30 - 0x21*uint16(i%2)
+	}
+
+	c.Crosstalk = 1
+	first := append([]byte(nil), c.Convert(&frame).Pix...)
+	second := c.Convert(&frame).Pix
+
+	if bytes.Equal(first, second) {
+		t.Errorf("no dot crawl between frames\n")
+	}
+
+	c.MergeFields = true
+	first = append([]byte(nil), c.Convert(&frame).Pix...)
+	second = c.Convert(&frame).Pix
+
+	if !bytes.Equal(first, second) {
+		t.Errorf("dot crawl between frames with merged fields\n")
+	}
+}
+
+func diff(a uint8, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+
+	return int(b - a)
+}
@@ -0,0 +1,423 @@
+package nes
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Size of a frame in pixels.
+const (
+	FrameWidth  = 256
+	FrameHeight = 240
+)
+
+// FrameBuffer is a frame of NES colours as output by the PPU, in rows from the
+// top left.
+//
+// Each pixel is a 6 bit colour index ($00-$3F, as stored in palette RAM), with
+// the red, green and blue colour emphasis bits in bits 6-8. These are the mask
+// register ($2001) bits 5-7, with red and green swapped on PAL.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_palettes
+type FrameBuffer [FrameWidth * FrameHeight]uint16
+
+// A Palette maps each FrameBuffer pixel value (colour index and emphasis bits)
+// to a colour.
+type Palette [512]color.RGBA
+
+// Factor by which colour emphasis attenuates the other colour channels.
+//
+// http://wiki.nesdev.com/w/index.php/NTSC_video#Color_Tint_Bits
+const emphasisAttenuation = 0.816328
+
+// NewPalette returns a Palette with the given colours for each colour index.
+// Colours with emphasis are approximated by attenuating the channels which
+// aren't emphasised.
+func NewPalette(colours [64]color.RGBA) *Palette {
+	var p Palette
+
+	for i := range p {
+		var colour color.RGBA = colours[i&0x3F]
+		var emphasis int = i >> 6
+
+		var channels [3]*uint8 = [3]*uint8{&colour.R, &colour.G, &colour.B}
+
+		for bit, channel := range channels {
+			// Each emphasised channel attenuates the other two.
+			for other := 0; other < 3; other++ {
+				if other != bit && emphasis&(1<<uint(other)) != 0 {
+					*channel = uint8(float64(*channel) * emphasisAttenuation)
+				}
+			}
+		}
+
+		p[i] = colour
+	}
+
+	return &p
+}
+
+// DefaultPalette returns the default Palette, the "2c02" preset.
+func DefaultPalette() *Palette {
+	return NewPalette(defaultColours)
+}
+
+// Built-in palettes, by name.
+var palettePresets = map[string]func() *Palette{
+	"2c02":      DefaultPalette,
+	"2c03":      rgbPalette,
+	"2c05":      rgbPalette,
+	"pal":       func() *Palette { return compositePalette(15) },
+	"composite": func() *Palette { return compositePalette(0) },
+}
+
+// PaletteNames returns the names of the built-in palettes:
+//
+//	2c02       the NTSC PPU, as used by default
+//	2c03       the RGB PPU used in arcade machines and the Famicom Titler
+//	2c05       the RGB PPU used in some Vs. System games (as 2c03)
+//	pal        an approximation of the PAL PPU (2C07), as composite with the
+//	           colour phases rotated by 15 degrees
+//	composite  generated from the NTSC PPU's composite video signal levels
+func PaletteNames() []string {
+	var names []string
+	for name := range palettePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// PresetPalette returns the built-in palette name, see PaletteNames.
+func PresetPalette(name string) (*Palette, error) {
+	preset, ok := palettePresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown palette %q", name)
+	}
+
+	return preset(), nil
+}
+
+// LoadPalette reads the .pal file filename.
+func LoadPalette(filename string) (*Palette, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	palette, err := ReadPalette(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return palette, nil
+}
+
+// ReadPalette reads a palette in .pal format: 3 bytes (red, green, blue) for
+// each colour index, either 192 bytes for the 64 colour indexes, or 1536 bytes
+// for all 512 combinations of colour index and emphasis. Emphasis is
+// approximated as for NewPalette for 192 byte palettes.
+//
+// Palettes of 1536 bytes order colours as FrameBuffer pixel values, i.e. with
+// the emphasis bits above the colour index.
+func ReadPalette(r io.Reader) (*Palette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Palette
+
+	switch len(data) {
+	case 64 * 3:
+		var colours [64]color.RGBA
+		for i := range colours {
+			colours[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF}
+		}
+
+		return NewPalette(colours), nil
+	case 512 * 3:
+		for i := range p {
+			p[i] = color.RGBA{data[i*3], data[i*3+1], data[i*3+2], 0xFF}
+		}
+
+		return &p, nil
+	default:
+		return nil, fmt.Errorf("palette is %d bytes, expected 192 or 1536", len(data))
+	}
+}
+
+// Returns the palette of the RGB PPUs (2C03 and 2C05). Each colour channel has
+// 3 bits, given here as octal digits. Emphasis sets a channel to its maximum
+// instead of attenuating the others.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_palettes#2C03_and_2C05
+func rgbPalette() *Palette {
+	levels := [64]uint16{
+		0333, 0014, 0006, 0326, 0403, 0503, 0510, 0420, 0320, 0120, 0031, 0040, 0022, 0000, 0000, 0000,
+		0555, 0036, 0027, 0407, 0507, 0704, 0700, 0630, 0430, 0140, 0040, 0053, 0044, 0000, 0000, 0000,
+		0777, 0357, 0447, 0637, 0707, 0737, 0740, 0750, 0660, 0360, 0070, 0276, 0077, 0000, 0000, 0000,
+		0777, 0567, 0657, 0757, 0747, 0755, 0764, 0772, 0773, 0572, 0473, 0276, 0467, 0000, 0000, 0000,
+	}
+
+	var p Palette
+
+	for i := range p {
+		var level uint16 = levels[i&0x3F]
+		var rgb [3]uint16 = [3]uint16{level >> 6, level >> 3 & 7, level & 7}
+
+		for channel := range rgb {
+			if i>>6&(1<<uint(channel)) != 0 {
+				rgb[channel] = 7
+			}
+		}
+
+		p[i] = color.RGBA{uint8(rgb[0] * 255 / 7), uint8(rgb[1] * 255 / 7), uint8(rgb[2] * 255 / 7), 0xFF}
+	}
+
+	return &p
+}
+
+// Returns a palette generated by decoding the composite video signal of the NTSC
+// PPU, with colour phases rotated by hueShift degrees.
+//
+// Each colour is a square wave between a low and high voltage level over the
+// 12 phases of the colour subcarrier, which is decoded to YIQ and then RGB.
+// Emphasis attenuates the signal during the phases of the emphasised colours.
+//
+// http://wiki.nesdev.com/w/index.php/NTSC_video
+func compositePalette(hueShift float64) *Palette {
+	var p Palette
+
+	for i := range p {
+		var signal [12]float64 = compositeSignal(uint16(i))
+		var y, iValue, q float64
+
+		for phase, level := range signal {
+			var angle float64 = chromaAngle(float64(phase) + hueShift/30)
+
+			y += level
+			iValue += level * math.Cos(angle)
+			q += level * math.Sin(angle)
+		}
+
+		// Demodulating the chroma halves its amplitude.
+		p[i] = yiqToRGB(y/12, iValue/6, q/6)
+	}
+
+	return &p
+}
+
+// Returns the angle of the colour subcarrier at phase (0-12), such that I is
+// decoded with its cosine, and Q its sine.
+func chromaAngle(phase float64) float64 {
+	return math.Pi * (phase + 3.5) / 6
+}
+
+// Gamma correction from the TV's 2.2 to sRGB, for levels 0-1 in steps of
+// 1/1024.
+var gammaTable [1025]uint8
+
+func init() {
+	for i := range gammaTable {
+		gammaTable[i] = uint8(math.Pow(float64(i)/1024, 2.2/1.8)*255 + 0.5)
+	}
+}
+
+// Converts a YIQ colour, where Y is 0 for black and 1 for white, to RGB.
+func yiqToRGB(y float64, i float64, q float64) color.RGBA {
+	rgb := [3]float64{
+		y + 0.946882*i + 0.623557*q,
+		y - 0.274788*i - 0.635691*q,
+		y - 1.108545*i + 1.709007*q,
+	}
+
+	var result [3]uint8
+	for channel, value := range rgb {
+		if value < 0 {
+			value = 0
+		} else if value > 1 {
+			value = 1
+		}
+
+		result[channel] = gammaTable[int(value*1024)]
+	}
+
+	return color.RGBA{result[0], result[1], result[2], 0xFF}
+}
+
+// Returns the composite signal for a FrameBuffer pixel value over each phase
+// of the colour subcarrier, where 0 is black and 1 is white.
+func compositeSignal(pixel uint16) [12]float64 {
+	// Signal levels for luminance 0-3, low then high.
+	levels := [8]float64{0.350, 0.518, 0.962, 1.550, 1.094, 1.506, 1.962, 1.962}
+
+	const black = 0.518
+	const white = 1.962
+	const attenuation = 0.746
+
+	var hue int = int(pixel & 0x0F)
+	var luminance int = int(pixel>>4) & 0x3
+	if hue >= 0x0E {
+		luminance = 1
+	}
+
+	var low float64 = levels[luminance]
+	var high float64 = levels[luminance+4]
+
+	switch {
+	case hue == 0x0:
+		low = high
+	case hue >= 0x0D:
+		high = low
+	}
+
+	// Returns true if phase is in the high half of the wave for hue.
+	inPhase := func(hue int, phase int) bool {
+		return (hue+phase)%12 < 6
+	}
+
+	var result [12]float64
+
+	for phase := range result {
+		var level float64 = low
+		if inPhase(hue, phase) {
+			level = high
+		}
+
+		// Red, green and blue emphasis are in phase with hues $C, $4 and $8.
+		if hue < 0x0E && (pixel&0x40 != 0 && inPhase(0xC, phase) ||
+			pixel&0x80 != 0 && inPhase(0x4, phase) ||
+			pixel&0x100 != 0 && inPhase(0x8, phase)) {
+			level *= attenuation
+		}
+
+		result[phase] = (level - black) / (white - black)
+	}
+
+	return result
+}
+
+// A Converter converts frames to images, e.g. by looking up each pixel in a
+// Palette.
+type Converter interface {
+	// Convert returns frame as an image. The image may be reused by later
+	// calls.
+	Convert(frame *FrameBuffer) *image.RGBA
+}
+
+// Palette returns the palette used by the PPU's Converter if it's a
+// PaletteConverter, or else DefaultPalette. For the PPU viewers.
+func (p *PPU) Palette() *Palette {
+	if converter, ok := p.Converter.(*PaletteConverter); ok {
+		return converter.Palette
+	}
+
+	return DefaultPalette()
+}
+
+// A PaletteConverter converts frames to 256x240px images by looking up each
+// pixel in a Palette.
+type PaletteConverter struct {
+	Palette *Palette
+
+	img *image.RGBA
+}
+
+// NewPaletteConverter returns a PaletteConverter using palette.
+func NewPaletteConverter(palette *Palette) *PaletteConverter {
+	return &PaletteConverter{
+		Palette: palette,
+		img:     image.NewRGBA(image.Rect(0, 0, FrameWidth, FrameHeight)),
+	}
+}
+
+// Convert returns frame as an image. The image is reused by later calls.
+func (c *PaletteConverter) Convert(frame *FrameBuffer) *image.RGBA {
+	var pix []byte = c.img.Pix
+
+	for i, pixel := range frame {
+		colour := c.Palette[pixel&0x1FF]
+		pix[i*4] = colour.R
+		pix[i*4+1] = colour.G
+		pix[i*4+2] = colour.B
+		pix[i*4+3] = colour.A
+	}
+
+	return c.img
+}
+
+// NES fixed 64 colour palette.
+var defaultColours = [64]color.RGBA{
+	/* 0x00 */ {0x75, 0x75, 0x75, 0xFF},
+	/* 0x01 */ {0x27, 0x1B, 0x8F, 0xFF},
+	/* 0x02 */ {0x00, 0x00, 0xAB, 0xFF},
+	/* 0x03 */ {0x47, 0x00, 0x9F, 0xFF},
+	/* 0x04 */ {0x8F, 0x00, 0x77, 0xFF},
+	/* 0x05 */ {0xAB, 0x00, 0x13, 0xFF},
+	/* 0x06 */ {0xA7, 0x00, 0x00, 0xFF},
+	/* 0x07 */ {0x7F, 0x0B, 0x00, 0xFF},
+	/* 0x08 */ {0x43, 0x2F, 0x00, 0xFF},
+	/* 0x09 */ {0x00, 0x47, 0x00, 0xFF},
+	/* 0x0A */ {0x00, 0x51, 0x00, 0xFF},
+	/* 0x0B */ {0x00, 0x3F, 0x17, 0xFF},
+	/* 0x0C */ {0x1B, 0x3F, 0x5F, 0xFF},
+	/* 0x0D */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x0E */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x0F */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x10 */ {0xBC, 0xBC, 0xBC, 0xFF},
+	/* 0x11 */ {0x00, 0x73, 0xEF, 0xFF},
+	/* 0x12 */ {0x23, 0x3B, 0xEF, 0xFF},
+	/* 0x13 */ {0x83, 0x00, 0xF3, 0xFF},
+	/* 0x14 */ {0xBF, 0x00, 0xBF, 0xFF},
+	/* 0x15 */ {0xE7, 0x00, 0x5B, 0xFF},
+	/* 0x16 */ {0xDB, 0x2B, 0x00, 0xFF},
+	/* 0x17 */ {0xCB, 0x4F, 0x0F, 0xFF},
+	/* 0x18 */ {0x8B, 0x73, 0x00, 0xFF},
+	/* 0x19 */ {0x00, 0x97, 0x00, 0xFF},
+	/* 0x1A */ {0x00, 0xAB, 0x00, 0xFF},
+	/* 0x1B */ {0x00, 0x93, 0x3B, 0xFF},
+	/* 0x1C */ {0x00, 0x83, 0x8B, 0xFF},
+	/* 0x1D */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x1E */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x1F */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x20 */ {0xFF, 0xFF, 0xFF, 0xFF},
+	/* 0x21 */ {0x3F, 0xBF, 0xFF, 0xFF},
+	/* 0x22 */ {0x5F, 0x97, 0xFF, 0xFF},
+	/* 0x23 */ {0xA7, 0x8B, 0xFD, 0xFF},
+	/* 0x24 */ {0xF7, 0x7B, 0xFF, 0xFF},
+	/* 0x25 */ {0xFF, 0x77, 0xB7, 0xFF},
+	/* 0x26 */ {0xFF, 0x77, 0x63, 0xFF},
+	/* 0x27 */ {0xFF, 0x9B, 0x3B, 0xFF},
+	/* 0x28 */ {0xF3, 0xBF, 0x3F, 0xFF},
+	/* 0x29 */ {0x83, 0xD3, 0x13, 0xFF},
+	/* 0x2A */ {0x4F, 0xDF, 0x4B, 0xFF},
+	/* 0x2B */ {0x58, 0xF8, 0x98, 0xFF},
+	/* 0x2C */ {0x00, 0xEB, 0xDB, 0xFF},
+	/* 0x2D */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x2E */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x2F */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x30 */ {0xFF, 0xFF, 0xFF, 0xFF},
+	/* 0x31 */ {0xAB, 0xE7, 0xFF, 0xFF},
+	/* 0x32 */ {0xC7, 0xD7, 0xFF, 0xFF},
+	/* 0x33 */ {0xD7, 0xCB, 0xFF, 0xFF},
+	/* 0x34 */ {0xFF, 0xC7, 0xFF, 0xFF},
+	/* 0x35 */ {0xFF, 0xC7, 0xDB, 0xFF},
+	/* 0x36 */ {0xFF, 0xBF, 0xB3, 0xFF},
+	/* 0x37 */ {0xFF, 0xDB, 0xAB, 0xFF},
+	/* 0x38 */ {0xFF, 0xE7, 0xA3, 0xFF},
+	/* 0x39 */ {0xE3, 0xFF, 0xA3, 0xFF},
+	/* 0x3A */ {0xAB, 0xF3, 0xBF, 0xFF},
+	/* 0x3B */ {0xB3, 0xFF, 0xCF, 0xFF},
+	/* 0x3C */ {0x9F, 0xFF, 0xF3, 0xFF},
+	/* 0x3D */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x3E */ {0x00, 0x00, 0x00, 0xFF},
+	/* 0x3F */ {0x00, 0x00, 0x00, 0xFF},
+}
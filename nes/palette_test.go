@@ -0,0 +1,76 @@
+package nes
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+)
+
+func TestReadPalette(t *testing.T) {
+	data := make([]byte, 64*3)
+	copy(data[0x16*3:], []byte{0xC0, 0x20, 0x10})
+
+	palette, err := ReadPalette(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if palette[0x16] != (color.RGBA{0xC0, 0x20, 0x10, 0xFF}) {
+		t.Errorf("colour $16 is %v\n", palette[0x16])
+	}
+
+	// Red emphasis attenuates green and blue.
+	if palette[0x16|0x40] != (color.RGBA{0xC0, 0x1A, 0x0D, 0xFF}) {
+		t.Errorf("colour $16 with red emphasis is %v\n", palette[0x16|0x40])
+	}
+
+	data = make([]byte, 512*3)
+	copy(data[(0x16|0x40)*3:], []byte{0x01, 0x02, 0x03})
+
+	palette, err = ReadPalette(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if palette[0x16|0x40] != (color.RGBA{0x01, 0x02, 0x03, 0xFF}) {
+		t.Errorf("colour $16 with red emphasis is %v\n", palette[0x16|0x40])
+	}
+
+	if _, err = ReadPalette(bytes.NewReader(data[:100])); err == nil {
+		t.Errorf("read palette of invalid size\n")
+	}
+}
+
+func TestPresetPalette(t *testing.T) {
+	for _, name := range PaletteNames() {
+		palette, err := PresetPalette(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// $0F is black, $30 white and $16 red in every palette.
+		black, white, red := palette[0x0F], palette[0x30], palette[0x16]
+
+		if black.R > 0x10 || black.G > 0x10 || black.B > 0x10 {
+			t.Errorf("%s: colour $0F is %v, expected black\n", name, black)
+		}
+
+		if white.R < 0xF0 || white.G < 0xF0 || white.B < 0xF0 {
+			t.Errorf("%s: colour $30 is %v, expected white\n", name, white)
+		}
+
+		if red.R < 0x80 || red.G > red.R/2 || red.B > red.R/2 {
+			t.Errorf("%s: colour $16 is %v, expected red\n", name, red)
+		}
+	}
+
+	// The RGB PPUs set emphasised channels to maximum.
+	palette, _ := PresetPalette("2c03")
+	if palette[0x16|0x80] != (color.RGBA{0xFF, 0xFF, 0x00, 0xFF}) {
+		t.Errorf("2c03: colour $16 with green emphasis is %v\n", palette[0x16|0x80])
+	}
+
+	if _, err := PresetPalette("unknown"); err == nil {
+		t.Errorf("found unknown palette\n")
+	}
+}
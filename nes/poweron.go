@@ -0,0 +1,57 @@
+package nes
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// A RAMPattern is the contents of RAM at power-on. Real RAM powers on with an
+// unpredictable, chip-dependent pattern, which games shouldn't rely on.
+//
+// http://wiki.nesdev.com/w/index.php/CPU_power_up_state
+type RAMPattern int
+
+const (
+	RAMZeros    RAMPattern = iota // All $00.
+	RAMOnes                       // All $FF.
+	RAMHardware                   // Alternating runs of 4 $00 and 4 $FF bytes, as seen on many consoles.
+	RAMRandom                     // Random bytes, from Console.Seed.
+)
+
+// Names of the patterns, for ParseRAMPattern.
+var ramPatternNames = map[string]RAMPattern{
+	"zeros":    RAMZeros,
+	"ones":     RAMOnes,
+	"hardware": RAMHardware,
+	"random":   RAMRandom,
+}
+
+// ParseRAMPattern returns the pattern called name: "zeros", "ones",
+// "hardware" or "random".
+func ParseRAMPattern(name string) (RAMPattern, error) {
+	pattern, ok := ramPatternNames[name]
+	if !ok {
+		return RAMZeros, fmt.Errorf("unknown RAM pattern %q", name)
+	}
+
+	return pattern, nil
+}
+
+// Fills ram with the pattern, using random for RAMRandom.
+func (r RAMPattern) fill(ram []byte, random *rand.Rand) {
+	if r == RAMRandom {
+		random.Read(ram)
+		return
+	}
+
+	for i := range ram {
+		switch r {
+		case RAMOnes:
+			ram[i] = 0xFF
+		case RAMHardware:
+			ram[i] = byte(0 - i>>2&0x1)
+		default:
+			ram[i] = 0x00
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package nes
+
+// A minimal protocol buffer encoder for writing pprof profiles.
+//
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+type protoBuffer []byte
+
+// Appends a varint.
+func (b *protoBuffer) varint(value uint64) {
+	for value >= 0x80 {
+		*b = append(*b, byte(value)|0x80)
+		value >>= 7
+	}
+
+	*b = append(*b, byte(value))
+}
+
+// Appends an integer field. Zero values are omitted.
+func (b *protoBuffer) uint64(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+
+	b.varint(uint64(field) << 3)
+	b.varint(value)
+}
+
+// Appends a length delimited field.
+func (b *protoBuffer) bytes(field int, value []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(value)))
+	*b = append(*b, value...)
+}
+
+// Appends a packed repeated integer field.
+func (b *protoBuffer) packed(field int, values []uint64) {
+	var packed protoBuffer
+	for _, value := range values {
+		packed.varint(value)
+	}
+
+	b.bytes(field, packed)
+}
+
+// Appends a message field.
+func (b *protoBuffer) message(field int, encode func(m *protoBuffer)) {
+	var m protoBuffer
+	encode(&m)
+
+	b.bytes(field, m)
+}
@@ -3,20 +3,38 @@ package nes
 import (
 	"fmt"
 	"image"
-	"image/color"
+	"math/rand"
 )
 
 // PPU implements the NES Picture Processing Unit.
 type PPU struct {
 	Console *Console
 
-	// Screen image, 256x240px.
-	img *image.RGBA
+	// Pixels of the frame being drawn. The frame is complete when Step
+	// returns an image.
+	Pixels FrameBuffer
 
-	// NES fixed 64 colour palette.
-	palette [64]color.RGBA
+	// Converts completed frames to the images returned by Step. A
+	// PaletteConverter using DefaultPalette by default.
+	Converter Converter
 
-	// Scanline (0-261).
+	// True if the red and green emphasis bits of the mask register are
+	// swapped, as on the PAL and Dendy PPUs. Set by Console.SetRegion.
+	SwapEmphasis bool
+
+	// If true, all sprites on a scanline are drawn rather than the first 8,
+	// reducing flicker. Sprite evaluation and the overflow flag are
+	// unaffected.
+	NoSpriteLimit bool
+
+	// Layer toggles for debugging: if set, the background, sprites, or
+	// individual sprites (bit n for sprite n) aren't drawn. Hidden layers
+	// still cause sprite 0 hits.
+	HideBackground bool
+	HideSprites    bool
+	HiddenSprites  uint64
+
+	// Scanline (0-261, or 0-311 for PAL and Dendy).
 	Scanline int
 
 	// Tick (0-340).
@@ -34,6 +52,21 @@ type PPU struct {
 	// Sprite RAM.
 	sprRAM [256]byte
 
+	// Secondary OAM: the sprites found by sprite evaluation for the next
+	// scanline, and their numbers in sprite RAM.
+	secondaryOAM        [32]byte
+	secondaryOAMSprites [8]int
+
+	// Sprite evaluation state: the number of sprites in secondary OAM, the
+	// sprite (n) and byte (m) of sprite RAM being read, and whether
+	// evaluation has finished. spriteOverflowN is the sprite the overflow
+	// search started at.
+	spriteCount     int
+	spriteN         int
+	spriteM         int
+	spriteEvalDone  bool
+	spriteOverflowN int
+
 	// PPU Control Register 1 ($2000).
 	spriteTableAddress     uint16
 	backgroundTableAddress uint16
@@ -57,44 +90,126 @@ type PPU struct {
 	flagSprite0Hit         bool
 	flagVBlankOutstanding  bool
 
+	// NMI output: true while the VBlank flag and NMI enable are both set.
+	// When it is set, the NMI is requested from the CPU nmiDelay ticks later
+	// if it's still set.
+	nmiOutput bool
+	nmiDelay  int
+
+	// True if the status register was read on the tick before VBlank starts,
+	// which suppresses the VBlank flag and NMI for the frame.
+	suppressVBlank bool
+
 	// Internal registers.
 	v uint16 // Current VRAM address (15 bits).
 	t uint16 // Temporary VRAM address. (15 bits).
 	x byte   // Fine X scroll (3 bits).
 	w byte   // First or second write toggle (0=first, 1=second).
 
-	// A complete scanline of foreground pixels (i.e. sprites).
-	fgPixels         [256]*color.RGBA
+	// A complete scanline of foreground pixels (i.e. sprites), as palette
+	// RAM offsets ($10-$1F). Offsets with the low 2 bits 0 are transparent.
+	fgPixels         [256]byte
 	fgPixelIsSprite0 [256]bool
 	fgPixelIsInFront [256]bool
-
-	// The next 16 pixels of background.
-	bgPixels [16]*color.RGBA
+	fgPixelIsHidden  [256]bool
+
+	// Background tile bytes fetched for the next tile.
+	nametableLatch   byte
+	attributeLatch   byte
+	patternLowLatch  byte
+	patternHighLatch byte
+
+	// Background shift registers: the pattern and attribute bits of the
+	// current tile (high byte) and the next tile (low byte). The pixel drawn
+	// is selected from the high byte by fine X.
+	patternLowShift    uint16
+	patternHighShift   uint16
+	attributeLowShift  uint16
+	attributeHighShift uint16
 
 	// Sprite IO address.
 	sprIOAddress byte
 
 	// PPUDATA read buffer.
 	readBuffer byte
+
+	// I/O latch (open bus), and the frame each of its bits was last
+	// refreshed.
+	ioLatch          byte
+	ioLatchRefreshed [8]uint64
 }
 
+// Number of frames an I/O latch bit retains its value without being
+// refreshed (around 600ms).
+//
+// http://wiki.nesdev.com/w/index.php/Open_bus_behavior#PPU_open_bus
+const ioLatchDecayFrames = 36
+
 const BackgroundPaletteAddress = 0x3F00
 const SpritePaletteAddress = 0x3F10
 
 // NewPPU constructs and returns a PPU for the given console.
 func NewPPU(console *Console) *PPU {
 	p := &PPU{
-		Console:  console,
-		Scanline: 241,
-		Tick:     0,
-		img:      image.NewRGBA(image.Rect(0, 0, 256, 240))}
+		Console:   console,
+		Scanline:  241,
+		Tick:      0,
+		Converter: NewPaletteConverter(DefaultPalette())}
 
-	p.setupPalette()
+	p.flagColourMode = true
 	p.flagShowBackground = true
 
 	return p
 }
 
+// Resets the PPU as the console's reset button does: the control, mask and
+// scroll registers, write toggle and read buffer are cleared. VRAM, palette
+// RAM and OAM are kept.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_power_up_state
+func (p *PPU) reset() {
+	p.SetControlRegister(0)
+	p.SetMaskRegister(0)
+
+	p.t = 0
+	p.x = 0
+	p.w = 0
+	p.readBuffer = 0
+}
+
+// Returns the PPU to its state from NewPPU, with RAM and OAM filled with
+// pattern, keeping its settings (Converter, SwapEmphasis and the debugging
+// options) and its cycle and frame counters. The PPU is then advanced
+// alignment dots, shifting its clock against the CPU's.
+func (p *PPU) powerOn(pattern RAMPattern, random *rand.Rand, alignment int) {
+	var q *PPU = NewPPU(p.Console)
+
+	q.Converter = p.Converter
+	q.SwapEmphasis = p.SwapEmphasis
+	q.NoSpriteLimit = p.NoSpriteLimit
+	q.HideBackground = p.HideBackground
+	q.HideSprites = p.HideSprites
+	q.HiddenSprites = p.HiddenSprites
+
+	q.Frame = p.Frame
+	q.numCycles = p.numCycles
+
+	pattern.fill(q.ram[:], random)
+	pattern.fill(q.sprRAM[:], random)
+
+	for i := 0; i < alignment; i++ {
+		q.incrementTick()
+	}
+
+	*p = *q
+}
+
+// SetPalette sets the palette used to convert frames to images, replacing
+// Converter with a PaletteConverter.
+func (p *PPU) SetPalette(palette *Palette) {
+	p.Converter = NewPaletteConverter(palette)
+}
+
 // Step runs the PPU for one cycle.
 //
 // Returns the total number of cycles run in the PPU's lifetime. An image is
@@ -104,6 +219,8 @@ func (p *PPU) Step() (uint64, *image.RGBA) {
 
 	var outputImage *image.RGBA = nil
 
+	var region Region = p.Console.region
+
 	// True if rendering is enabled.
 	var isRendering bool = p.flagShowBackground || p.flagShowSprites
 
@@ -111,55 +228,99 @@ func (p *PPU) Step() (uint64, *image.RGBA) {
 	var isVisible = p.Scanline <= 239
 
 	// True if this is the interrupt assert scanline.
-	var isVBlankLine bool = p.Scanline == 241
+	var isVBlankLine bool = p.Scanline == region.VBlankScanline()
 
 	// True if this is the prerender scanline.
-	var isPrerender bool = p.Scanline == 261
+	var isPrerender bool = p.Scanline == region.PrerenderScanline()
+
+	// True if this scanline fetches background tiles.
+	var isFetchLine bool = isRendering && (isVisible || isPrerender)
 
-	// True if a pixel should be drawn this tick.
-	var isDrawing bool = isRendering && isVisible &&
+	// True if background tiles are fetched this tick.
+	var isFetching bool = isFetchLine &&
 		((p.Tick >= 1 && p.Tick <= 256) || (p.Tick >= 321 && p.Tick <= 336))
 
-	// True if a background tile should be fetched this tick.
-	var isFetching bool = isRendering && (isVisible || isPrerender) &&
-		((p.Tick >= 1 && p.Tick <= 256) || (p.Tick >= 321 && p.Tick <= 336)) &&
-		p.Tick%8 == 0
+	// Shift the background shift registers.
+	if isFetchLine && ((p.Tick >= 2 && p.Tick <= 257) || (p.Tick >= 322 && p.Tick <= 337)) {
+		p.shiftBackground()
+	}
+
+	// Fetch background tiles, a byte every 2 ticks.
+	//
+	// http://wiki.nesdev.com/w/index.php/PPU_rendering#Cycles_1-256
+	if isFetching {
+		switch p.Tick % 8 {
+		case 1:
+			p.reloadBackground()
+			p.fetchNametableByte()
+		case 3:
+			p.fetchAttributeByte()
+		case 5:
+			p.patternLowLatch = p.fetchPatternByte(0)
+		case 7:
+			p.patternHighLatch = p.fetchPatternByte(8)
+		case 0:
+			if p.Tick == 256 {
+				// Horizontal bits are set on tick 257.
+				p.incrementY()
+			} else {
+				p.incrementCoarseX()
+			}
+		}
+	} else if isFetchLine && (p.Tick == 337 || p.Tick == 339) {
+		// Unused nametable fetches.
+		p.fetchNametableByte()
+	}
 
 	// Draw pixels.
-	if isDrawing {
+	if isVisible && p.Tick >= 1 && p.Tick <= 256 {
 		p.drawPixel()
 	}
 
-	// Fetch background tiles.
-	if isFetching {
-		p.loadTile()
+	// Request an NMI once the NMI output has been set long enough for a
+	// status register read not to cancel it.
+	if p.nmiDelay > 0 {
+		p.nmiDelay--
+		if p.nmiDelay == 0 && p.nmiOutput {
+			p.Console.CPU.triggerNMI(region.cpuCycles(p.numCycles))
 
-		if p.Tick == 256 {
-			// Horizontal bits are set on tick 257.
-			p.incrementY()
-		} else {
-			p.incrementCoarseX()
+			if p.Console.EventViewer != nil {
+				p.Console.EventViewer.nmi()
+			}
 		}
 	}
 
 	// Interrupt generation.
 	if isVBlankLine && p.Tick == 1 {
-		// Generate interrupt.
-		p.flagVBlankOutstanding = true
-		if p.flagNMIOnVBlank {
-			p.Console.CPU.NMI()
+		if !p.suppressVBlank {
+			p.flagVBlankOutstanding = true
+			p.updateNMI()
 		}
-		outputImage = p.img
+		p.suppressVBlank = false
+
+		outputImage = p.Converter.Convert(&p.Pixels)
 	} else if isPrerender && p.Tick == 1 {
 		// Clear flags.
 		p.flagVBlankOutstanding = false
 		p.flagScanlineSpritesMax = false
 		p.flagSprite0Hit = false
+		p.updateNMI()
+	}
+
+	// Evaluate sprites for the next scanline.
+	if isRendering && isVisible {
+		if p.Tick >= 1 && p.Tick <= 64 && p.Tick%2 == 0 {
+			p.secondaryOAM[p.Tick/2-1] = 0xFF
+		} else if p.Tick == 65 {
+			p.startSpriteEvaluation()
+		} else if p.Tick >= 66 && p.Tick <= 256 && p.Tick%2 == 0 {
+			p.evaluateSprite()
+		}
 	}
 
 	// Load sprites.
 	if isRendering && p.Tick == 257 {
-		p.loadSprites()
+		p.loadSprites(isVisible)
 	}
 
 	// For scanline counting mappers.
@@ -180,65 +341,143 @@ func (p *PPU) Step() (uint64, *image.RGBA) {
 	return p.numCycles, outputImage
 }
 
-func (p *PPU) loadTile() {
-	// Load the tile's attribute bits.
+// Fetches the nametable byte of the tile at v.
+func (p *PPU) fetchNametableByte() {
+	p.nametableLatch = p.read(0x2000 | (p.v & 0x0FFF))
+}
+
+// Fetches the attribute bits of the tile at v.
+func (p *PPU) fetchAttributeByte() {
 	var attributeAddress uint16 = 0x23C0 | (p.v & 0x0C00) | ((p.v >> 4) & 0x38) |
 		((p.v >> 2) & 0x07)
 	var shift uint16 = p.v&0x2 | ((p.v & 0x40) >> 4)
-	var attributeBits byte = (p.read(attributeAddress) >> shift) & 0x3
+	p.attributeLatch = (p.read(attributeAddress) >> shift) & 0x3
+}
 
-	// Load the tile's pattern index.
-	var patternIndex byte = p.read(0x2000 | (p.v & 0x0FFF))
+// Fetches a pattern byte of the tile at v: the low bit plane if plane is 0,
+// or the high bit plane if plane is 8.
+func (p *PPU) fetchPatternByte(plane uint16) byte {
+	var address uint16 = p.backgroundTableAddress + uint16(p.nametableLatch)*16 +
+		(p.v&0x7000)>>12 + plane
 
-	// Build 8 pixel strip of the tile.
-	var newPixels [8]*color.RGBA = p.pixelStrip(patternIndex, uint16(attributeBits),
-		false, int(p.v&0x7000)>>12)
+	if p.Console.CDL != nil {
+		p.Console.CDL.chrAccess(address, CDLDrawn)
+	}
 
-	// Add pixels to the bgPixels shift register.
-	copy(p.bgPixels[8:], newPixels[:])
+	return p.read(address)
 }
 
-func (p *PPU) drawPixel() {
-	// Select background pixel, move up remaining pixels in shift register.
-	var bgPixel *color.RGBA = p.bgPixels[p.x]
+// Loads the fetched tile into the low bytes of the background shift
+// registers.
+func (p *PPU) reloadBackground() {
+	p.patternLowShift = p.patternLowShift&0xFF00 | uint16(p.patternLowLatch)
+	p.patternHighShift = p.patternHighShift&0xFF00 | uint16(p.patternHighLatch)
 
-	// Move the shift register along.
-	copy(p.bgPixels[p.x:], p.bgPixels[p.x+1:])
+	p.attributeLowShift &= 0xFF00
+	if p.attributeLatch&0x1 != 0 {
+		p.attributeLowShift |= 0xFF
+	}
 
-	// X coordinate (0-255).
-	var x int = p.Tick - 1
+	p.attributeHighShift &= 0xFF00
+	if p.attributeLatch&0x2 != 0 {
+		p.attributeHighShift |= 0xFF
+	}
+}
 
-	// Nothing to render here.
-	if x > 256 {
-		return
+// Shifts the background shift registers by a pixel.
+func (p *PPU) shiftBackground() {
+	p.patternLowShift <<= 1
+	p.patternHighShift <<= 1
+	p.attributeLowShift <<= 1
+	p.attributeHighShift <<= 1
+}
+
+// Returns the background pixel selected by fine X from the shift registers,
+// as a palette RAM offset.
+func (p *PPU) backgroundPixel() byte {
+	var bit uint = 15 - uint(p.x)
+
+	var index byte = byte(p.patternLowShift>>bit&0x1) | byte(p.patternHighShift>>bit&0x1)<<1
+	if index == 0 {
+		return 0
 	}
 
-	// Get the foreground pixel (if any), choose the final pixel to render.
-	var colour color.RGBA
+	var attributeBits byte = byte(p.attributeLowShift>>bit&0x1) | byte(p.attributeHighShift>>bit&0x1)<<1
+
+	return attributeBits<<2 | index
+}
 
-	// Clipping.
-	var showSprites bool = x >= 8 || !p.flagClipSprites
-	var showBackground bool = x >= 8 || !p.flagClipBackground
-	var isBorder bool = x < 8 || x > 247 || p.Scanline < 8 || p.Scanline > 231
+// Draws the pixel for the current tick (1-256). The mask register is applied
+// as the pixel is drawn, so changes to it take effect at the next pixel.
+func (p *PPU) drawPixel() {
+	// X coordinate (0-255).
+	var x int = p.Tick - 1
 
-	if isBorder {
-		colour = p.palette[0x3F] // black
-	} else if showSprites && p.fgPixels[x] != nil && (p.fgPixelIsInFront[x] || bgPixel == nil) {
-		colour = *p.fgPixels[x]
-	} else if showBackground && bgPixel != nil {
-		colour = *bgPixel
+	// Get the background and foreground (if any) pixels, choose the final
+	// pixel to render.
+	var colour byte
+	var bgPixel byte = p.backgroundPixel()
+	var fgPixel byte = p.fgPixels[x]
+	var isBgOpaque bool = bgPixel&0x3 != 0
+	var isFgOpaque bool = fgPixel&0x3 != 0
+
+	// Layers enabled, and clipping.
+	var showSprites bool = p.flagShowSprites && (x >= 8 || !p.flagClipSprites)
+	var showBackground bool = p.flagShowBackground && (x >= 8 || !p.flagClipBackground)
+
+	// Pixels drawn, after the layer toggles.
+	var isBgVisible bool = showBackground && isBgOpaque && !p.HideBackground
+	var isFgVisible bool = showSprites && isFgOpaque && !p.HideSprites && !p.fgPixelIsHidden[x]
+
+	if isFgVisible && (p.fgPixelIsInFront[x] || !isBgVisible) {
+		colour = p.read(BackgroundPaletteAddress+uint16(fgPixel)) & 0x3F
+	} else if isBgVisible {
+		colour = p.read(BackgroundPaletteAddress+uint16(bgPixel)) & 0x3F
 	} else {
-		colour = p.palette[p.read(BackgroundPaletteAddress) & 0x3F]
+		colour = p.read(BackgroundPaletteAddress) & 0x3F
+	}
+
+	// Greyscale selects the grey column of the palette.
+	if !p.flagColourMode {
+		colour &= 0x30
 	}
 
 	// Sprite 0 hit?
 	if showSprites && showBackground {
-		if p.fgPixels[x] != nil && bgPixel != nil && p.fgPixelIsSprite0[x] && x < 255 {
+		if isFgOpaque && isBgOpaque && p.fgPixelIsSprite0[x] && x < 255 {
 			p.flagSprite0Hit = true
 		}
 	}
 
-	p.img.Set(x, p.Scanline, colour)
+	p.Pixels[p.Scanline*FrameWidth+x] = uint16(colour) | p.emphasis()<<6
+}
+
+// Returns the colour emphasis bits of the mask register, ordered red, green,
+// blue from bit 0.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_registers#Color_Control
+func (p *PPU) emphasis() uint16 {
+	var result uint16
+
+	var red bool = p.flagRedEmphasis
+	var green bool = p.flagGreenEmphasis
+	if p.SwapEmphasis {
+		red, green = green, red
+	}
+
+	if red {
+		result |= 0x1
+	}
+
+	if green {
+		result |= 0x2
+	}
+
+	if p.flagBlueEmphasis {
+		result |= 0x4
+	}
+
+	return result
 }
 
 // String returns a description of the PPU as a string.
@@ -304,15 +543,48 @@ func (p *PPU) copyVerticalBitsToV() {
 	p.v = (p.v & 0x041F) | (p.t &^ 0x041F)
 }
 
+// Number of ticks between the NMI output being set and the NMI being requested
+// from the CPU. Reading the status register on the tick VBlank starts, or the
+// tick after, clears the VBlank flag before the NMI is requested.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_frame_timing#VBL_Flag_Timing
+const nmiDelay = 2
+
+// Updates the NMI output from the VBlank flag and NMI enable. Setting it
+// requests an NMI, so enabling NMIs during VBlank (again) causes one.
+func (p *PPU) updateNMI() {
+	var output bool = p.flagVBlankOutstanding && p.flagNMIOnVBlank
+
+	if output && !p.nmiOutput {
+		p.nmiDelay = nmiDelay
+	}
+
+	p.nmiOutput = output
+}
+
+// Advances Tick, Scanline and Frame by a tick.
+//
+// On odd frames with rendering enabled, the last tick of the prerender
+// scanline is skipped (NTSC only).
+//
+// http://wiki.nesdev.com/w/index.php/PPU_frame_timing#Even.2FOdd_Frames
 func (p *PPU) incrementTick() {
 	p.Tick++
 
+	var region Region = p.Console.region
+
 	isOddFrame := p.Frame&0x1 != 0
+	isRendering := p.flagShowBackground || p.flagShowSprites
+	isSkipped := isOddFrame && isRendering && region == RegionNTSC
 
-	if p.Scanline == 261 && (p.Tick == 341 || (p.Tick == 340 && isOddFrame)) {
+	if p.Scanline == region.PrerenderScanline() && (p.Tick == 341 || (p.Tick == 340 && isSkipped)) {
 		p.Scanline = 0
 		p.Tick = 0
 		p.Frame++
+
+		if p.Console.EventViewer != nil {
+			p.Console.EventViewer.startFrame()
+		}
 	} else if p.Tick == 341 {
 		p.Scanline++
 		p.Tick = 0
@@ -340,6 +612,7 @@ func (p *PPU) SetControlRegister(value byte) {
 
 	p.flagLargeSprites = value&0x20 != 0
 	p.flagNMIOnVBlank = value&0x80 != 0
+	p.updateNMI()
 }
 
 // SetMaskRegister sets the value of the mask register ($2001).
@@ -371,7 +644,10 @@ func (p *PPU) WriteSPR(value byte) {
 
 // ReadSPR reads and returns the byte at the current sprite address.
 func (p *PPU) ReadSPR() byte {
-	return p.sprRAM[p.sprIOAddress]
+	var result byte = p.sprRAM[p.sprIOAddress]
+	p.refreshLatch(result, 0xFF)
+
+	return result
 }
 
 // WriteScroll ($2005): Scroll position write register (two values).
@@ -412,8 +688,81 @@ func (p *PPU) WriteDataAddress(value byte) {
 	}
 }
 
+// ReadRegister reads the PPU register at address ($2000-$3FFF).
+//
+// Write only registers return the contents of the I/O latch.
+func (p *PPU) ReadRegister(address uint16) byte {
+	var result byte
+
+	switch address & 0x7 {
+	case 2:
+		result = p.StatusRegister()
+	case 4:
+		result = p.ReadSPR()
+	case 7:
+		result = p.ReadData()
+	default:
+		result = p.latch()
+	}
+
+	return result
+}
+
+// WriteRegister writes value to the PPU register at address ($2000-$3FFF).
+//
+// All writes, including those to read only registers, fill the I/O latch.
+func (p *PPU) WriteRegister(address uint16, value byte) {
+	p.refreshLatch(value, 0xFF)
+
+	switch address & 0x7 {
+	case 0x0:
+		p.SetControlRegister(value)
+	case 0x1:
+		p.SetMaskRegister(value)
+	case 0x3:
+		p.SetSPRAddress(value)
+	case 0x4:
+		p.WriteSPR(value)
+	case 0x5:
+		p.WriteScroll(value)
+	case 0x6:
+		p.WriteDataAddress(value)
+	case 0x7:
+		p.WriteData(value)
+	}
+}
+
+// Returns the I/O latch contents, with bits not refreshed recently decayed to
+// 0.
+func (p *PPU) latch() byte {
+	var result byte = p.ioLatch
+
+	for i := uint(0); i < 8; i++ {
+		if p.Frame-p.ioLatchRefreshed[i] > ioLatchDecayFrames {
+			result &^= 1 << i
+		}
+	}
+
+	return result
+}
+
+// Sets the I/O latch bits selected by mask to value.
+func (p *PPU) refreshLatch(value byte, mask byte) {
+	p.ioLatch = (p.latch() &^ mask) | (value & mask)
+
+	for i := uint(0); i < 8; i++ {
+		if mask&(1<<i) != 0 {
+			p.ioLatchRefreshed[i] = p.Frame
+		}
+	}
+}
+
 // WriteData writes a byte to PPU RAM ($2007).
 func (p *PPU) WriteData(value byte) {
+	if p.Console.Debugger != nil {
+		p.Console.Debugger.ppuAccess(p.v, value, AccessWrite)
+	}
+
 	p.write(p.v, value)
 
 	if p.flagIncrementBy32 {
@@ -428,12 +777,23 @@ func (p *PPU) ReadData() byte {
 	previousValue := p.readBuffer
 	p.readBuffer = p.read(p.v)
 
+	if p.Console.Debugger != nil {
+		p.Console.Debugger.ppuAccess(p.v, p.readBuffer, AccessRead)
+	}
+
+	if p.Console.CDL != nil && p.v&0x3FFF < 0x2000 {
+		p.Console.CDL.chrAccess(p.v&0x3FFF, CDLRead)
+	}
+
 	var result byte
 
 	if p.v&0x3FFF <= 0x3EFF {
 		result = previousValue
+		p.refreshLatch(result, 0xFF)
 	} else {
-		result = p.readBuffer
+		// Palette reads are 6 bits, the top 2 bits come from the I/O latch.
+		result = p.readBuffer&0x3F | p.latch()&0xC0
+		p.refreshLatch(result, 0x3F)
 	}
 
 	if p.flagIncrementBy32 {
@@ -446,8 +806,29 @@ func (p *PPU) ReadData() byte {
 }
 
 // StatusRegister returns the value of the status register ($2002).
+//
+// Reading the status register clears the VBlank flag and the write toggle.
+// The low 5 bits are filled from the I/O latch. Reading it on the tick before
+// VBlank starts suppresses VBlank for the frame.
 func (p *PPU) StatusRegister() byte {
-	var result byte
+	var result byte = p.status()
+	p.refreshLatch(result, 0xE0)
+
+	if p.Scanline == p.Console.region.VBlankScanline() && p.Tick == 0 {
+		p.suppressVBlank = true
+	}
+
+	p.flagVBlankOutstanding = false
+	p.updateNMI()
+
+	// w:                  = 0
+	p.w = 0
+
+	return result
+}
+
+func (p *PPU) status() byte {
+	var result byte = p.latch() & 0x1F
 
 	if p.flagScanlineSpritesMax {
 		result |= 0x20
@@ -459,89 +840,171 @@ func (p *PPU) StatusRegister() byte {
 
 	if p.flagVBlankOutstanding {
 		result |= 0x80
-		p.flagVBlankOutstanding = false
 	}
 
-	// w:                  = 0
-	p.w = 0
-
 	return result
 }
 
-func (p *PPU) loadSprites() {
-	for i := range p.fgPixels {
-		p.fgPixels[i] = nil
-		p.fgPixelIsSprite0[i] = false
-		p.fgPixelIsInFront[i] = false
-	}
-
-	if !p.flagShowSprites || p.Scanline == 0 {
-		return
-	}
-
+// Returns the row of a sprite with Y coordinate y which is drawn on the next
+// scanline, and true if the sprite is on the next scanline.
+func (p *PPU) spriteRow(y byte) (int, bool) {
 	spriteHeight := 8
 	if p.flagLargeSprites {
 		spriteHeight = 16
 	}
 
-	numSprites := 0
-	for i := 0; i < len(p.sprRAM); i += 4 {
-		y := int(p.sprRAM[i]) + 1
-		x := int(p.sprRAM[i+3])
+	row := p.Scanline - int(y)
 
-		if y >= 0xF0 || p.Scanline+1 < y || p.Scanline+1 >= y+spriteHeight {
-			continue
-		}
+	return row, row >= 0 && row < spriteHeight
+}
+
+// Resets the sprite evaluation state, at tick 65.
+func (p *PPU) startSpriteEvaluation() {
+	p.spriteCount = 0
+	p.spriteN = 0
+	p.spriteM = 0
+	p.spriteEvalDone = false
+	p.spriteOverflowN = 64
+}
 
-		numSprites++
-		if numSprites > 8 {
+// Runs one read and write of sprite evaluation, on even ticks 66-256.
+//
+// Sprites on the next scanline are copied from sprite RAM to secondary OAM.
+// Once 8 have been found, the search for a 9th (to set the overflow flag)
+// increments both the sprite and byte indexes, so it reads the wrong bytes
+// as Y coordinates, as the hardware does.
+//
+// http://wiki.nesdev.com/w/index.php/PPU_sprite_evaluation
+func (p *PPU) evaluateSprite() {
+	if p.spriteEvalDone {
+		return
+	}
+
+	var value byte = p.sprRAM[p.spriteN*4+p.spriteM]
+	_, inRange := p.spriteRow(value)
+
+	if p.spriteCount == 8 {
+		// Overflow search.
+		if inRange {
 			p.flagScanlineSpritesMax = true
-			break
+			p.spriteEvalDone = true
+			return
 		}
 
-		yOffset := p.Scanline + 1 - y
-		patternIndex := p.sprRAM[i+1]
+		p.spriteM = (p.spriteM + 1) & 0x3
+		p.nextSprite()
+		return
+	}
+
+	p.secondaryOAM[p.spriteCount*4+p.spriteM] = value
+
+	if p.spriteM == 0 && !inRange {
+		p.nextSprite()
+		return
+	}
+
+	p.secondaryOAMSprites[p.spriteCount] = p.spriteN
 
-		attributes := p.sprRAM[i+2]
-		flipH := attributes&0x40 != 0
-		flipV := attributes&0x80 != 0
-		inFront := attributes&0x20 == 0
+	p.spriteM++
+	if p.spriteM == 4 {
+		p.spriteM = 0
+		p.spriteCount++
+		p.nextSprite()
 
-		if flipV {
-			yOffset = spriteHeight - 1 - yOffset
+		if p.spriteCount == 8 {
+			p.spriteOverflowN = p.spriteN
 		}
+	}
+}
 
-		paletteBits := uint16(attributes & 0x3)
-		var fgPixels [8]*color.RGBA = p.pixelStrip(patternIndex, paletteBits, true, yOffset)
+// Moves sprite evaluation to the next sprite in sprite RAM.
+func (p *PPU) nextSprite() {
+	p.spriteN++
+	if p.spriteN == 64 {
+		p.spriteEvalDone = true
+	}
+}
 
-		for k := 0; k < 8; k++ {
-			pk := k
-			if flipH {
-				pk = 7 - k
-			}
+// Loads the foreground pixels for the next scanline from the sprites in
+// secondary OAM, at tick 257. If isVisible is false there are none.
+func (p *PPU) loadSprites(isVisible bool) {
+	for i := range p.fgPixels {
+		p.fgPixels[i] = 0
+		p.fgPixelIsSprite0[i] = false
+		p.fgPixelIsInFront[i] = false
+		p.fgPixelIsHidden[i] = false
+	}
+
+	if !isVisible {
+		return
+	}
+
+	for i := 0; i < p.spriteCount; i++ {
+		var sprite []byte = p.secondaryOAM[i*4 : i*4+4]
+		p.loadSprite(sprite, p.secondaryOAMSprites[i])
+	}
 
-			if x+k > 0xFF {
-				break
+	// Sprites beyond the 8 sprite limit.
+	if p.NoSpriteLimit {
+		for n := p.spriteOverflowN; n < 64; n++ {
+			var sprite []byte = p.sprRAM[n*4 : n*4+4]
+			if _, inRange := p.spriteRow(sprite[0]); inRange {
+				p.loadSprite(sprite, n)
 			}
+		}
+	}
+}
+
+// Draws the pixels of sprite number n on the next scanline into the foreground
+// pixels, behind any sprites already drawn.
+func (p *PPU) loadSprite(sprite []byte, n int) {
+	y := sprite[0]
+	patternIndex := sprite[1]
+	attributes := sprite[2]
+	x := int(sprite[3])
+
+	spriteHeight := 8
+	if p.flagLargeSprites {
+		spriteHeight = 16
+	}
 
-			pos := x + k
-			if p.fgPixels[pos] == nil && fgPixels[pk] != nil {
-				p.fgPixels[pos] = fgPixels[pk]
+	yOffset, _ := p.spriteRow(y)
 
-				if i == 0 {
-					p.fgPixelIsSprite0[pos] = true
-				}
+	flipH := attributes&0x40 != 0
+	flipV := attributes&0x80 != 0
+	inFront := attributes&0x20 == 0
 
-				p.fgPixelIsInFront[pos] = inFront
-			}
+	if flipV {
+		yOffset = spriteHeight - 1 - yOffset
+	}
+
+	paletteBits := uint16(attributes & 0x3)
+	var fgPixels [8]byte = p.pixelStrip(patternIndex, paletteBits, true, yOffset)
+
+	for k := 0; k < 8; k++ {
+		pk := k
+		if flipH {
+			pk = 7 - k
+		}
+
+		if x+k > 0xFF {
+			break
+		}
+
+		pos := x + k
+		if p.fgPixels[pos]&0x3 == 0 && fgPixels[pk]&0x3 != 0 {
+			p.fgPixels[pos] = fgPixels[pk]
+			p.fgPixelIsSprite0[pos] = n == 0
+			p.fgPixelIsInFront[pos] = inFront
+			p.fgPixelIsHidden[pos] = p.HiddenSprites&(1<<uint(n)) != 0
 		}
 	}
 }
 
-func (p *PPU) pixelStrip(patternIndex byte, attributeBits uint16, isForeground bool, yOffset int) [8]*color.RGBA {
+// Returns 8 pixels of a tile's pattern as palette RAM offsets.
+func (p *PPU) pixelStrip(patternIndex byte, attributeBits uint16, isForeground bool, yOffset int) [8]byte {
 	var baseAddress uint16
-	var basePaletteAddress uint16
-	var showPixels bool
+	var basePaletteOffset byte
 
 	if isForeground {
 		if p.flagLargeSprites {
@@ -560,24 +1023,28 @@ func (p *PPU) pixelStrip(patternIndex byte, attributeBits uint16, isForeground b
 		} else {
 			baseAddress = p.spriteTableAddress
 		}
-		basePaletteAddress = SpritePaletteAddress
-		showPixels = p.flagShowSprites
+		basePaletteOffset = SpritePaletteAddress - BackgroundPaletteAddress
 	} else {
 		baseAddress = p.backgroundTableAddress
-		basePaletteAddress = BackgroundPaletteAddress
-		showPixels = p.flagShowBackground
+		basePaletteOffset = 0
 	}
 
-	var result [8]*color.RGBA
+	var result [8]byte
 
-	low := p.read(baseAddress + uint16(patternIndex)*16 + uint16(yOffset))
-	high := p.read(baseAddress + uint16(patternIndex)*16 + uint16(yOffset) + 8)
+	var lowAddress uint16 = baseAddress + uint16(patternIndex)*16 + uint16(yOffset)
+	low := p.read(lowAddress)
+	high := p.read(lowAddress + 8)
+
+	if p.Console.CDL != nil {
+		p.Console.CDL.chrAccess(lowAddress, CDLDrawn)
+		p.Console.CDL.chrAccess(lowAddress+8, CDLDrawn)
+	}
 
 	for i := 0; i < 8; i++ {
 		low2 := (low>>uint(7-i))&0x1 != 0
 		high2 := (high>>uint(7-i))&0x1 != 0
 
-		var index uint16
+		var index byte
 		if high2 {
 			index |= 0x2
 		}
@@ -585,11 +1052,8 @@ func (p *PPU) pixelStrip(patternIndex byte, attributeBits uint16, isForeground b
 			index |= 0x1
 		}
 
-		if index == 0 || !showPixels {
-			result[i] = nil
-		} else {
-			palette_index := p.read(basePaletteAddress+attributeBits<<2+index) & 0x3F;
-			result[i] = &p.palette[palette_index]
+		if index != 0 {
+			result[i] = basePaletteOffset + byte(attributeBits)<<2 + index
 		}
 	}
 
@@ -649,6 +1113,48 @@ func (p *PPU) read(address uint16) byte {
 	return result
 }
 
+// Peek reads a byte from the PPU address space ($0000-$3FFF) without side
+// effects.
+func (p *PPU) Peek(address uint16) byte {
+	address = p.mapAddress(address)
+
+	var result byte
+
+	switch {
+	case address < 0x2000:
+		result = p.Console.Cart.Peek(address, true)
+	default:
+		result = p.ram[address]
+	}
+
+	return result
+}
+
+// PeekRegister returns the value a CPU read of the PPU register at address
+// ($2000-$3FFF) would return, without side effects.
+//
+// Write only registers return the contents of the I/O latch.
+func (p *PPU) PeekRegister(address uint16) byte {
+	var result byte
+
+	switch address & 0x7 {
+	case 2:
+		result = p.status()
+	case 4:
+		result = p.sprRAM[p.sprIOAddress]
+	case 7:
+		if p.v&0x3FFF <= 0x3EFF {
+			result = p.readBuffer
+		} else {
+			result = p.Peek(p.v)&0x3F | p.latch()&0xC0
+		}
+	default:
+		result = p.latch()
+	}
+
+	return result
+}
+
 func (p *PPU) write(address uint16, value byte) {
 	address = p.mapAddress(address)
 	
@@ -659,72 +1165,3 @@ func (p *PPU) write(address uint16, value byte) {
 		p.ram[address] = value
 	}
 }
-
-func (p *PPU) setupPalette() {
-	p.palette = [64]color.RGBA{
-		/* 0x00 */ {0x75, 0x75, 0x75, 0xFF},
-		/* 0x01 */ {0x27, 0x1B, 0x8F, 0xFF},
-		/* 0x02 */ {0x00, 0x00, 0xAB, 0xFF},
-		/* 0x03 */ {0x47, 0x00, 0x9F, 0xFF},
-		/* 0x04 */ {0x8F, 0x00, 0x77, 0xFF},
-		/* 0x05 */ {0xAB, 0x00, 0x13, 0xFF},
-		/* 0x06 */ {0xA7, 0x00, 0x00, 0xFF},
-		/* 0x07 */ {0x7F, 0x0B, 0x00, 0xFF},
-		/* 0x08 */ {0x43, 0x2F, 0x00, 0xFF},
-		/* 0x09 */ {0x00, 0x47, 0x00, 0xFF},
-		/* 0x0A */ {0x00, 0x51, 0x00, 0xFF},
-		/* 0x0B */ {0x00, 0x3F, 0x17, 0xFF},
-		/* 0x0C */ {0x1B, 0x3F, 0x5F, 0xFF},
-		/* 0x0D */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x0E */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x0F */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x10 */ {0xBC, 0xBC, 0xBC, 0xFF},
-		/* 0x11 */ {0x00, 0x73, 0xEF, 0xFF},
-		/* 0x12 */ {0x23, 0x3B, 0xEF, 0xFF},
-		/* 0x13 */ {0x83, 0x00, 0xF3, 0xFF},
-		/* 0x14 */ {0xBF, 0x00, 0xBF, 0xFF},
-		/* 0x15 */ {0xE7, 0x00, 0x5B, 0xFF},
-		/* 0x16 */ {0xDB, 0x2B, 0x00, 0xFF},
-		/* 0x17 */ {0xCB, 0x4F, 0x0F, 0xFF},
-		/* 0x18 */ {0x8B, 0x73, 0x00, 0xFF},
-		/* 0x19 */ {0x00, 0x97, 0x00, 0xFF},
-		/* 0x1A */ {0x00, 0xAB, 0x00, 0xFF},
-		/* 0x1B */ {0x00, 0x93, 0x3B, 0xFF},
-		/* 0x1C */ {0x00, 0x83, 0x8B, 0xFF},
-		/* 0x1D */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x1E */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x1F */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x20 */ {0xFF, 0xFF, 0xFF, 0xFF},
-		/* 0x21 */ {0x3F, 0xBF, 0xFF, 0xFF},
-		/* 0x22 */ {0x5F, 0x97, 0xFF, 0xFF},
-		/* 0x23 */ {0xA7, 0x8B, 0xFD, 0xFF},
-		/* 0x24 */ {0xF7, 0x7B, 0xFF, 0xFF},
-		/* 0x25 */ {0xFF, 0x77, 0xB7, 0xFF},
-		/* 0x26 */ {0xFF, 0x77, 0x63, 0xFF},
-		/* 0x27 */ {0xFF, 0x9B, 0x3B, 0xFF},
-		/* 0x28 */ {0xF3, 0xBF, 0x3F, 0xFF},
-		/* 0x29 */ {0x83, 0xD3, 0x13, 0xFF},
-		/* 0x2A */ {0x4F, 0xDF, 0x4B, 0xFF},
-		/* 0x2B */ {0x58, 0xF8, 0x98, 0xFF},
-		/* 0x2C */ {0x00, 0xEB, 0xDB, 0xFF},
-		/* 0x2D */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x2E */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x2F */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x30 */ {0xFF, 0xFF, 0xFF, 0xFF},
-		/* 0x31 */ {0xAB, 0xE7, 0xFF, 0xFF},
-		/* 0x32 */ {0xC7, 0xD7, 0xFF, 0xFF},
-		/* 0x33 */ {0xD7, 0xCB, 0xFF, 0xFF},
-		/* 0x34 */ {0xFF, 0xC7, 0xFF, 0xFF},
-		/* 0x35 */ {0xFF, 0xC7, 0xDB, 0xFF},
-		/* 0x36 */ {0xFF, 0xBF, 0xB3, 0xFF},
-		/* 0x37 */ {0xFF, 0xDB, 0xAB, 0xFF},
-		/* 0x38 */ {0xFF, 0xE7, 0xA3, 0xFF},
-		/* 0x39 */ {0xE3, 0xFF, 0xA3, 0xFF},
-		/* 0x3A */ {0xAB, 0xF3, 0xBF, 0xFF},
-		/* 0x3B */ {0xB3, 0xFF, 0xCF, 0xFF},
-		/* 0x3C */ {0x9F, 0xFF, 0xF3, 0xFF},
-		/* 0x3D */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x3E */ {0x00, 0x00, 0x00, 0xFF},
-		/* 0x3F */ {0x00, 0x00, 0x00, 0xFF},
-	}
-}
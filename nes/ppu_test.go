@@ -0,0 +1,621 @@
+package nes
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+// Runs console until the PPU completes a frame, and returns the image.
+func runFrame(t *testing.T, console *Console) *image.RGBA {
+	for i := 0; i < 100000; i++ {
+		img, err := console.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if img != nil {
+			return img
+		}
+	}
+
+	t.Fatalf("no frame output\n")
+	return nil
+}
+
+func TestPPUIndexedOutput(t *testing.T) {
+	console := newTestConsole([]byte{0x4C, 0x00, 0x80}) // JMP $8000
+	var ppu *PPU = console.PPU
+
+	// Tile 1 is solid colour 1, drawn at tiles (0, 0) and (1, 1).
+	for i := 0; i < 8; i++ {
+		console.Cart.CHR[0][16+i] = 0xFF
+	}
+	ppu.write(0x2000, 0x01)
+	ppu.write(0x2021, 0x01)
+	ppu.write(0x3F00, 0x0F)
+	ppu.write(0x3F01, 0x16)
+	ppu.SetMaskRegister(0x2A) // Show background, including the left 8 pixels, red emphasis.
+
+	// The PPU starts in vblank, so the first frame is blank.
+	runFrame(t, console)
+	img := runFrame(t, console)
+	var palette *Palette = DefaultPalette()
+
+	tests := []struct {
+		x, y     int
+		expected uint16
+	}{
+		{0, 0, 0x16 | 0x1<<6},     // Tile 1, at the edge.
+		{7, 0, 0x16 | 0x1<<6},     // Tile 1, at the edge.
+		{0, 7, 0x16 | 0x1<<6},     // Tile 1, at the edge.
+		{8, 8, 0x16 | 0x1<<6},     // Tile 1.
+		{15, 15, 0x16 | 0x1<<6},   // Tile 1.
+		{16, 8, 0x0F | 0x1<<6},    // Tile 0, backdrop colour.
+		{255, 239, 0x0F | 0x1<<6}, // Tile 0, at the edge.
+	}
+
+	for _, test := range tests {
+		var pixel uint16 = ppu.Pixels[test.y*FrameWidth+test.x]
+		if pixel != test.expected {
+			t.Errorf("pixel (%d, %d) is $%03X, expected $%03X\n", test.x, test.y, pixel, test.expected)
+		}
+
+		if img.RGBAAt(test.x, test.y) != palette[test.expected] {
+			t.Errorf("image pixel (%d, %d) is %v, expected %v\n",
+				test.x, test.y, img.RGBAAt(test.x, test.y), palette[test.expected])
+		}
+	}
+}
+
+func TestPPUGreyscaleAndEmphasis(t *testing.T) {
+	console := newTestConsole([]byte{0x4C, 0x00, 0x80}) // JMP $8000
+	var ppu *PPU = console.PPU
+
+	ppu.write(0x3F00, 0x16)
+
+	tests := []struct {
+		mask         byte
+		swap         bool
+		expected     uint16
+		expectedRGBA [3]uint8
+	}{
+		{0x08, false, 0x16, [3]uint8{0xDB, 0x2B, 0x00}},
+		{0x09, false, 0x10, [3]uint8{0xBC, 0xBC, 0xBC}},          // Greyscale.
+		{0x28, false, 0x16 | 0x1<<6, [3]uint8{0xDB, 0x23, 0x00}}, // Red.
+		{0x48, false, 0x16 | 0x2<<6, [3]uint8{0xB2, 0x2B, 0x00}}, // Green.
+		{0x48, true, 0x16 | 0x1<<6, [3]uint8{0xDB, 0x23, 0x00}},  // PAL red.
+		{0xE9, false, 0x10 | 0x7<<6, [3]uint8{0x7C, 0x7C, 0x7C}}, // All, greyscale.
+	}
+
+	for _, test := range tests {
+		ppu.SetMaskRegister(test.mask)
+		ppu.SwapEmphasis = test.swap
+
+		runFrame(t, console)
+		img := runFrame(t, console)
+
+		var pixel uint16 = ppu.Pixels[100*FrameWidth+100]
+		if pixel != test.expected {
+			t.Errorf("mask $%02X: pixel is $%03X, expected $%03X\n", test.mask, pixel, test.expected)
+		}
+
+		// Including at the edges of the frame.
+		if ppu.Pixels[0] != test.expected {
+			t.Errorf("mask $%02X: pixel (0, 0) is $%03X, expected $%03X\n", test.mask, ppu.Pixels[0], test.expected)
+		}
+
+		colour := img.RGBAAt(100, 100)
+		if [3]uint8{colour.R, colour.G, colour.B} != test.expectedRGBA {
+			t.Errorf("mask $%02X: colour is %v, expected %v\n", test.mask, colour, test.expectedRGBA)
+		}
+	}
+}
+
+// Returns a console with tile 1 solid colour 1, and all sprites off screen.
+func newSpriteTestConsole() *Console {
+	console := newTestConsole([]byte{0x4C, 0x00, 0x80}) // JMP $8000
+	var ppu *PPU = console.PPU
+
+	for i := 0; i < 8; i++ {
+		console.Cart.CHR[0][16+i] = 0xFF
+	}
+
+	for i := range ppu.sprRAM {
+		ppu.sprRAM[i] = 0xFF
+	}
+
+	ppu.write(0x3F00, 0x0F)
+	ppu.write(0x3F11, 0x16)
+	ppu.SetMaskRegister(0x1E) // Show background and sprites, no clipping.
+
+	return console
+}
+
+func TestPPUSpriteOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		sprites  [][4]byte
+		expected bool
+	}{
+		{
+			"8 sprites",
+			[][4]byte{{50}, {50}, {50}, {50}, {50}, {50}, {50}, {50}},
+			false,
+		},
+		{
+			"9 sprites",
+			[][4]byte{{50}, {50}, {50}, {50}, {50}, {50}, {50}, {50}, {50}},
+			true,
+		},
+		{
+			// Sprite 9's tile index is read as a Y coordinate.
+			"false positive",
+			[][4]byte{{50}, {50}, {50}, {50}, {50}, {50}, {50}, {50}, {0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 50, 0xFF, 0xFF}},
+			true,
+		},
+		{
+			// Sprite 9's Y coordinate is skipped.
+			"false negative",
+			[][4]byte{{50}, {50}, {50}, {50}, {50}, {50}, {50}, {50}, {0xFF, 0xFF, 0xFF, 0xFF}, {50, 0xFF, 0xFF, 0xFF}},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		console := newSpriteTestConsole()
+		for i, sprite := range test.sprites {
+			copy(console.PPU.sprRAM[i*4:], sprite[:])
+		}
+
+		runFrame(t, console)
+		runFrame(t, console)
+
+		var overflow bool = console.PPU.status()&0x20 != 0
+		if overflow != test.expected {
+			t.Errorf("%s: sprite overflow is %v, expected %v\n", test.name, overflow, test.expected)
+		}
+	}
+}
+
+func TestPPUNoSpriteLimit(t *testing.T) {
+	for _, noSpriteLimit := range []bool{false, true} {
+		console := newSpriteTestConsole()
+		var ppu *PPU = console.PPU
+		ppu.NoSpriteLimit = noSpriteLimit
+
+		// 10 sprites in a row.
+		for i := 0; i < 10; i++ {
+			copy(ppu.sprRAM[i*4:], []byte{50, 0x01, 0x00, byte(8 + i*16)})
+		}
+
+		runFrame(t, console)
+		runFrame(t, console)
+
+		for i := 0; i < 10; i++ {
+			var expected uint16 = 0x0F
+			if i < 8 || noSpriteLimit {
+				expected = 0x16
+			}
+
+			var pixel uint16 = ppu.Pixels[55*FrameWidth+8+i*16]
+			if pixel != expected {
+				t.Errorf("NoSpriteLimit=%v: sprite %d pixel is $%03X, expected $%03X\n",
+					noSpriteLimit, i, pixel, expected)
+			}
+		}
+	}
+}
+
+// Runs the PPU alone until it has run the given scanline and tick.
+func runPPUTo(ppu *PPU, scanline int, tick int) {
+	for ppu.Scanline != scanline || ppu.Tick != tick {
+		ppu.Step()
+	}
+}
+
+func TestPPUMidScanlineWrites(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ppu *PPU)
+
+		// Pixels on scanline 100, and the expected colours.
+		pixels   []int
+		expected []uint16
+	}{
+		{
+			"background disabled",
+			func(ppu *PPU) { ppu.SetMaskRegister(0x16) },
+			[]int{128, 129, 130},
+			[]uint16{0x16, 0x16, 0x0F},
+		},
+		{
+			"fine X scroll",
+			func(ppu *PPU) { ppu.WriteScroll(0x03) },
+			[]int{129, 132, 133, 135},
+			[]uint16{0x16, 0x16, 0x0F, 0x0F},
+		},
+	}
+
+	for _, test := range tests {
+		console := newTestConsole([]byte{0x4C, 0x00, 0x80}) // JMP $8000
+		var ppu *PPU = console.PPU
+
+		// Tile 1 is solid colour 1, drawn at x=128-135.
+		for i := 0; i < 8; i++ {
+			console.Cart.CHR[0][16+i] = 0xFF
+		}
+		for row := 0; row < 30; row++ {
+			ppu.write(0x2000+uint16(row*32+16), 0x01)
+		}
+		ppu.write(0x3F00, 0x0F)
+		ppu.write(0x3F01, 0x16)
+		ppu.SetMaskRegister(0x1E)
+
+		// Write after drawing pixel 129 of scanline 100.
+		runPPUTo(ppu, 100, 130)
+		test.write(ppu)
+		runPPUTo(ppu, 241, 0)
+
+		for i, x := range test.pixels {
+			var pixel uint16 = ppu.Pixels[100*FrameWidth+x]
+			if pixel != test.expected[i] {
+				t.Errorf("%s: pixel %d is $%03X, expected $%03X\n", test.name, x, pixel, test.expected[i])
+			}
+		}
+
+		// The previous scanline is unaffected.
+		if ppu.Pixels[99*FrameWidth+127] != 0x0F || ppu.Pixels[99*FrameWidth+135] != 0x16 {
+			t.Errorf("%s: scanline 99 affected\n", test.name)
+		}
+	}
+}
+
+func TestPPUOddFrameSkip(t *testing.T) {
+	for _, mask := range []byte{0x00, 0x08} {
+		console := newTestConsole(nil)
+		var ppu *PPU = console.PPU
+		ppu.SetMaskRegister(mask)
+
+		// Ticks in each of 4 frames.
+		var frames []int
+		var ticks int
+
+		runPPUTo(ppu, 0, 0)
+		for len(frames) < 4 {
+			ppu.Step()
+			ticks++
+
+			if ppu.Scanline == 0 && ppu.Tick == 0 {
+				frames = append(frames, ticks)
+				ticks = 0
+			}
+		}
+
+		for i, ticks := range frames {
+			// Frame is incremented at the start of each frame, the first
+			// frame counted is frame 1.
+			var expected int = 341 * 262
+			if mask != 0 && i%2 == 0 {
+				expected--
+			}
+
+			if ticks != expected {
+				t.Errorf("mask $%02X: frame %d has %d ticks, expected %d\n", mask, i+1, ticks, expected)
+			}
+		}
+	}
+}
+
+func TestPPURegions(t *testing.T) {
+	tests := []struct {
+		region    Region
+		cpuCycles uint64 // CPU cycles in 2 frames.
+	}{
+		{RegionPAL, 2 * 312 * 341 * 5 / 16},
+		{RegionDendy, 2 * 312 * 341 / 3},
+	}
+
+	for _, test := range tests {
+		console := newTestConsole(nil)
+		console.SetRegion(test.region)
+		console.frameDuration = 0
+
+		var ppu *PPU = console.PPU
+		ppu.SetMaskRegister(0x08)
+
+		if !ppu.SwapEmphasis {
+			t.Errorf("%s: emphasis bits not swapped\n", test.region)
+		}
+
+		// Every frame is the same length, with VBlank starting on the
+		// region's VBlank scanline.
+		runPPUTo(ppu, 0, 0)
+
+		var ticks, vblanks int
+		for frames := 0; frames < 2; {
+			ppu.Step()
+			ticks++
+
+			if ppu.flagVBlankOutstanding {
+				if ppu.Scanline != test.region.VBlankScanline() || ppu.Tick != 1 {
+					t.Errorf("%s: VBlank started at %d:%d, expected %d:1\n", test.region, ppu.Scanline, ppu.Tick, test.region.VBlankScanline())
+				}
+
+				ppu.flagVBlankOutstanding = false
+				vblanks++
+			}
+
+			if ppu.Scanline == 0 && ppu.Tick == 0 {
+				if ticks != 341*test.region.Scanlines() {
+					t.Errorf("%s: frame has %d ticks, expected %d\n", test.region, ticks, 341*test.region.Scanlines())
+				}
+
+				ticks = 0
+				frames++
+			}
+		}
+
+		if vblanks != 2 {
+			t.Errorf("%s: VBlank started %d times in 2 frames\n", test.region, vblanks)
+		}
+
+		// The CPU runs at the region's clock ratio.
+		runFrame(t, console)
+		var start uint64 = console.CPU.NumCycles
+		runFrame(t, console)
+		runFrame(t, console)
+
+		var cycles uint64 = console.CPU.NumCycles - start
+		if cycles+7 < test.cpuCycles || cycles > test.cpuCycles+7 {
+			t.Errorf("%s: 2 frames took %d CPU cycles, expected %d\n", test.region, cycles, test.cpuCycles)
+		}
+	}
+}
+
+func TestPPUVBlankRace(t *testing.T) {
+	tests := []struct {
+		tick           int // Tick of scanline 241 the status register is read after.
+		expectedStatus bool
+		expectedFlag   bool // VBlank flag set on a later tick.
+		expectedNMI    bool
+	}{
+		{339, false, true, true}, // Scanline 240.
+		{0, false, false, false},
+		{1, true, false, false},
+		{2, true, false, false},
+		{3, true, false, true},
+	}
+
+	for _, test := range tests {
+		console := newTestConsole(nil)
+		var ppu *PPU = console.PPU
+		ppu.SetControlRegister(0x80)
+
+		// Run to the frame after the first.
+		runPPUTo(ppu, 0, 0)
+		console.CPU.nmiPending = false
+
+		if test.tick == 339 {
+			runPPUTo(ppu, 240, test.tick)
+		} else {
+			runPPUTo(ppu, 241, test.tick)
+		}
+
+		var status bool = ppu.StatusRegister()&0x80 != 0
+		if status != test.expectedStatus {
+			t.Errorf("tick %d: read VBlank flag %v, expected %v\n", test.tick, status, test.expectedStatus)
+		}
+
+		runPPUTo(ppu, 241, 10)
+
+		if ppu.flagVBlankOutstanding != test.expectedFlag {
+			t.Errorf("tick %d: VBlank flag %v, expected %v\n", test.tick, ppu.flagVBlankOutstanding, test.expectedFlag)
+		}
+
+		if console.CPU.nmiPending != test.expectedNMI {
+			t.Errorf("tick %d: NMI %v, expected %v\n", test.tick, console.CPU.nmiPending, test.expectedNMI)
+		}
+	}
+}
+
+func TestPPUNMIEnable(t *testing.T) {
+	console := newTestConsole(nil)
+	var ppu *PPU = console.PPU
+	var cpu *CPU = console.CPU
+
+	runPPUTo(ppu, 245, 0)
+	if cpu.nmiPending {
+		t.Fatalf("NMI with NMIs disabled\n")
+	}
+
+	// Enabling NMIs during VBlank causes an NMI, after the delay.
+	ppu.SetControlRegister(0x80)
+	ppu.Step()
+	ppu.Step()
+	if !cpu.nmiPending {
+		t.Fatalf("no NMI after enabling NMIs in VBlank\n")
+	}
+
+	// As does enabling them again.
+	cpu.nmiPending = false
+	ppu.SetControlRegister(0x80)
+	runPPUTo(ppu, 246, 0)
+	if cpu.nmiPending {
+		t.Fatalf("NMI after writing $2000 without toggling NMI enable\n")
+	}
+
+	ppu.SetControlRegister(0x00)
+	ppu.SetControlRegister(0x80)
+	runPPUTo(ppu, 247, 0)
+	if !cpu.nmiPending {
+		t.Fatalf("no NMI after toggling NMI enable in VBlank\n")
+	}
+
+	// But not after the VBlank flag is cleared.
+	cpu.nmiPending = false
+	ppu.StatusRegister()
+	ppu.SetControlRegister(0x00)
+	ppu.SetControlRegister(0x80)
+	runPPUTo(ppu, 248, 0)
+	if cpu.nmiPending {
+		t.Fatalf("NMI after VBlank flag cleared\n")
+	}
+}
+
+// Runs a blargg test ROM, and returns its result code (0 for success) and
+// output text.
+//
+// Tests which report via PRG RAM are run until they finish, pressing reset when
+// they ask for it. Older tests, which store their result code at $F8 (1 for
+// success), are run for a fixed number of frames.
+//
+// http://wiki.nesdev.com/w/index.php/Emulator_tests
+func runTestROM(filename string, usesPRGRAM bool) (byte, string, error) {
+	cart, err := LoadCartridge(filename)
+	if err != nil {
+		return 0, "", err
+	}
+
+	console := NewConsole(cart)
+	console.frameDuration = 0
+
+	// Frame to press reset on, or -1.
+	var resetFrame int = -1
+
+	for frame := 0; frame < 60*30; {
+		img, err := console.Step()
+		if err != nil {
+			return 0, "", err
+		}
+
+		if img != nil {
+			frame++
+		}
+
+		var sram []byte = cart.SRAM[0]
+		if !usesPRGRAM || sram[1] != 0xDE || sram[2] != 0xB0 || sram[3] != 0x61 {
+			continue
+		}
+
+		// $81: press reset after at least 100ms.
+		if sram[0] == 0x81 && resetFrame == -1 {
+			resetFrame = frame + 6
+		} else if frame == resetFrame {
+			console.Reset()
+			resetFrame = -1
+		}
+
+		if sram[0] < 0x80 {
+			var end int = bytes.IndexByte(sram[4:], 0)
+			if end == -1 {
+				end = len(sram) - 4
+			}
+
+			return sram[0], strings.TrimSpace(string(sram[4 : 4+end])), nil
+		}
+	}
+
+	if usesPRGRAM {
+		return 0xFF, "timed out", nil
+	}
+
+	var result byte = console.CPU.RAM[0xF8]
+	if result == 1 {
+		return 0, "passed", nil
+	}
+
+	return result, fmt.Sprintf("failed #%d", result), nil
+}
+
+func TestPPUVBlankNMITestROMs(t *testing.T) {
+	tests := []struct {
+		filename   string
+		usesPRGRAM bool
+	}{
+		{"vbl_nmi_timing/1.frame_basics.nes", false},
+		{"vbl_nmi_timing/2.vbl_timing.nes", false},
+		{"vbl_nmi_timing/3.even_odd_frames.nes", false},
+		{"vbl_nmi_timing/4.vbl_clear_timing.nes", false},
+		{"vbl_nmi_timing/5.nmi_suppression.nes", false},
+		{"vbl_nmi_timing/6.nmi_disable.nes", false},
+		{"vbl_nmi_timing/7.nmi_timing.nes", false},
+		{"ppu_vbl_nmi/rom_singles/01-vbl_basics.nes", true},
+		{"ppu_vbl_nmi/rom_singles/02-vbl_set_time.nes", true},
+		{"ppu_vbl_nmi/rom_singles/03-vbl_clear_time.nes", true},
+		{"ppu_vbl_nmi/rom_singles/04-nmi_control.nes", true},
+		{"ppu_vbl_nmi/rom_singles/05-nmi_timing.nes", true},
+		{"ppu_vbl_nmi/rom_singles/06-suppression.nes", true},
+		{"ppu_vbl_nmi/rom_singles/07-nmi_on_timing.nes", true},
+		{"ppu_vbl_nmi/rom_singles/08-nmi_off_timing.nes", true},
+		{"ppu_vbl_nmi/rom_singles/09-even_odd_frames.nes", true},
+		{"ppu_vbl_nmi/rom_singles/10-even_odd_timing.nes", true},
+	}
+
+	for _, test := range tests {
+		result, text, err := runTestROM("test_roms/"+test.filename, test.usesPRGRAM)
+
+		if err != nil {
+			t.Errorf("%s: %s\n", test.filename, err)
+		} else if result != 0 {
+			t.Errorf("%s: %s\n", test.filename, text)
+		} else {
+			t.Logf("%s: %s\n", test.filename, text)
+		}
+	}
+}
+
+func TestPPULayerToggles(t *testing.T) {
+	tests := []struct {
+		name           string
+		hideBackground bool
+		hideSprites    bool
+		hiddenSprites  uint64
+
+		// Colours of sprite 0 (in front of the background), sprite 1 (in
+		// front of the backdrop), and the background.
+		expected [3]uint16
+	}{
+		{"all shown", false, false, 0, [3]uint16{0x16, 0x16, 0x2A}},
+		{"background hidden", true, false, 0, [3]uint16{0x16, 0x16, 0x0F}},
+		{"sprites hidden", false, true, 0, [3]uint16{0x2A, 0x0F, 0x2A}},
+		{"sprite 0 hidden", false, false, 0x1, [3]uint16{0x2A, 0x16, 0x2A}},
+		{"only sprite 0 shown", false, false, ^uint64(0x1), [3]uint16{0x16, 0x0F, 0x2A}},
+	}
+
+	for _, test := range tests {
+		console := newSpriteTestConsole()
+		var ppu *PPU = console.PPU
+
+		// Background tile 1 at x=128-135, sprites at (128, 51) and (160, 51).
+		for row := 0; row < 30; row++ {
+			ppu.write(0x2000+uint16(row*32+16), 0x01)
+		}
+		ppu.write(0x3F01, 0x2A)
+		copy(ppu.sprRAM[0:], []byte{50, 0x01, 0x00, 128})
+		copy(ppu.sprRAM[4:], []byte{50, 0x01, 0x00, 160})
+
+		ppu.HideBackground = test.hideBackground
+		ppu.HideSprites = test.hideSprites
+		ppu.HiddenSprites = test.hiddenSprites
+
+		runFrame(t, console)
+		runFrame(t, console)
+
+		pixels := [3]uint16{
+			ppu.Pixels[55*FrameWidth+130],
+			ppu.Pixels[55*FrameWidth+162],
+			ppu.Pixels[100*FrameWidth+130],
+		}
+		if pixels != test.expected {
+			t.Errorf("%s: got pixels %03X, expected %03X\n", test.name, pixels, test.expected)
+		}
+
+		// Hidden layers still cause sprite 0 hits.
+		if !ppu.flagSprite0Hit {
+			t.Errorf("%s: no sprite 0 hit\n", test.name)
+		}
+	}
+}
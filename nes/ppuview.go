@@ -0,0 +1,172 @@
+package nes
+
+import (
+	"image"
+	"image/color"
+)
+
+// Sizes of the PPU viewer images, in pixels.
+const (
+	NametablesWidth   = 512
+	NametablesHeight  = 480
+	PatternTableSize  = 128
+	PaletteRAMWidth   = 256
+	PaletteRAMHeight  = 32
+	OAMWidth          = 64
+	OAMHeight         = 128
+	paletteSwatchSize = 16
+)
+
+// NametablesImage returns the four nametables ($2000, $2400, $2800, $2C00,
+// after mirroring) as a NametablesWidth x NametablesHeight image, using the
+// background pattern table and palettes.
+//
+// The screen area at the current scroll position (from the temporary VRAM
+// address and fine X scroll, as used for the next frame) is outlined.
+func (p *PPU) NametablesImage(palette *Palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, NametablesWidth, NametablesHeight))
+
+	for nametable := 0; nametable < 4; nametable++ {
+		var base uint16 = 0x2000 + uint16(nametable)*0x400
+		var left int = (nametable % 2) * 256
+		var top int = (nametable / 2) * 240
+
+		for row := 0; row < 30; row++ {
+			for column := 0; column < 32; column++ {
+				var patternIndex byte = p.Peek(base + uint16(row*32+column))
+
+				var attribute byte = p.Peek(base + 0x3C0 + uint16(row/4*8+column/4))
+				var shift uint = uint(row&0x2)<<1 | uint(column&0x2)
+				var paletteIndex int = int(attribute>>shift) & 0x3
+
+				var address uint16 = p.backgroundTableAddress + uint16(patternIndex)*16
+				p.drawTile(img, left+column*8, top+row*8, address, paletteIndex, palette, false, false)
+			}
+		}
+	}
+
+	// Outline the screen area.
+	var scrollX int = int(p.t&0x1F)*8 + int(p.x) + int(p.t>>10&0x1)*256
+	var scrollY int = int(p.t>>5&0x1F)*8 + int(p.t>>12&0x7) + int(p.t>>11&0x1)*240
+
+	for i := 0; i < FrameWidth; i++ {
+		invertPixel(img, (scrollX+i)%NametablesWidth, scrollY%NametablesHeight)
+		invertPixel(img, (scrollX+i)%NametablesWidth, (scrollY+FrameHeight-1)%NametablesHeight)
+	}
+
+	for i := 1; i < FrameHeight-1; i++ {
+		invertPixel(img, scrollX%NametablesWidth, (scrollY+i)%NametablesHeight)
+		invertPixel(img, (scrollX+FrameWidth-1)%NametablesWidth, (scrollY+i)%NametablesHeight)
+	}
+
+	return img
+}
+
+// PatternTableImage returns pattern table 0 ($0000) or 1 ($1000) as a
+// PatternTableSize square image of 16x16 tiles, coloured with palette RAM
+// palette paletteIndex (0-3 background, 4-7 sprites).
+func (p *PPU) PatternTableImage(table int, paletteIndex int, palette *Palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, PatternTableSize, PatternTableSize))
+
+	for tile := 0; tile < 256; tile++ {
+		var address uint16 = uint16(table&0x1)*0x1000 + uint16(tile)*16
+		p.drawTile(img, tile%16*8, tile/16*8, address, paletteIndex&0x7, palette, false, false)
+	}
+
+	return img
+}
+
+// PaletteRAMImage returns the 32 entries of palette RAM ($3F00-$3F1F) as a
+// PaletteRAMWidth x PaletteRAMHeight image: background palettes on the top
+// row, sprite palettes on the bottom.
+func (p *PPU) PaletteRAMImage(palette *Palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, PaletteRAMWidth, PaletteRAMHeight))
+
+	for i := 0; i < 32; i++ {
+		var colour color.RGBA = palette[p.Peek(BackgroundPaletteAddress+uint16(i))&0x3F]
+
+		for y := 0; y < paletteSwatchSize; y++ {
+			for x := 0; x < paletteSwatchSize; x++ {
+				img.SetRGBA(i%16*paletteSwatchSize+x, i/16*paletteSwatchSize+y, colour)
+			}
+		}
+	}
+
+	return img
+}
+
+// OAMImage returns the 64 sprites in sprite RAM as an OAMWidth x OAMHeight
+// image, in an 8x8 grid of 8x16px cells, with their palettes and flipping.
+// 8x8 sprites fill the top of their cells.
+func (p *PPU) OAMImage(palette *Palette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, OAMWidth, OAMHeight))
+
+	for i := 0; i < 64; i++ {
+		var sprite []byte = p.sprRAM[i*4 : i*4+4]
+		var patternIndex byte = sprite[1]
+		var attributes byte = sprite[2]
+		var paletteIndex int = 4 + int(attributes&0x3)
+		var flipH bool = attributes&0x40 != 0
+		var flipV bool = attributes&0x80 != 0
+
+		var left int = i % 8 * 8
+		var top int = i / 8 * 16
+
+		if !p.flagLargeSprites {
+			var address uint16 = p.spriteTableAddress + uint16(patternIndex)*16
+			p.drawTile(img, left, top, address, paletteIndex, palette, flipH, flipV)
+			continue
+		}
+
+		// 8x16 sprites use the pattern table selected by bit 0, and the
+		// tiles are swapped when flipped vertically.
+		var address uint16 = uint16(patternIndex&0x1)*0x1000 + uint16(patternIndex&^0x1)*16
+		var first, second int = top, top + 8
+		if flipV {
+			first, second = second, first
+		}
+
+		p.drawTile(img, left, first, address, paletteIndex, palette, flipH, flipV)
+		p.drawTile(img, left, second, address+16, paletteIndex, palette, flipH, flipV)
+	}
+
+	return img
+}
+
+// Draws the 8x8 tile at address in the pattern tables at (x, y) in img, with
+// palette RAM palette paletteIndex (0-7). Pixels of colour 0 use the backdrop
+// colour.
+func (p *PPU) drawTile(img *image.RGBA, x int, y int, address uint16, paletteIndex int, palette *Palette, flipH bool, flipV bool) {
+	for row := 0; row < 8; row++ {
+		var low byte = p.Peek(address + uint16(row))
+		var high byte = p.Peek(address + uint16(row) + 8)
+
+		var dy int = row
+		if flipV {
+			dy = 7 - row
+		}
+
+		for column := 0; column < 8; column++ {
+			var bit uint = uint(7 - column)
+			var index int = int(low>>bit&0x1) | int(high>>bit&0x1)<<1
+
+			var paletteAddress uint16 = BackgroundPaletteAddress
+			if index != 0 {
+				paletteAddress += uint16(paletteIndex*4 + index)
+			}
+
+			var dx int = column
+			if flipH {
+				dx = 7 - column
+			}
+
+			img.SetRGBA(x+dx, y+dy, palette[p.Peek(paletteAddress)&0x3F])
+		}
+	}
+}
+
+// Inverts the colour of the pixel at (x, y) in img.
+func invertPixel(img *image.RGBA, x int, y int) {
+	var colour color.RGBA = img.RGBAAt(x, y)
+	img.SetRGBA(x, y, color.RGBA{^colour.R, ^colour.G, ^colour.B, 0xFF})
+}
@@ -0,0 +1,70 @@
+package nes
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestPPUViewers(t *testing.T) {
+	console := newTestConsole(nil)
+	var ppu *PPU = console.PPU
+	var palette *Palette = DefaultPalette()
+
+	// Tile 1 is solid colour 1.
+	for i := 0; i < 8; i++ {
+		console.Cart.CHR[0][16+i] = 0xFF
+	}
+
+	ppu.write(0x2021, 0x01) // Tile (1, 1) of nametable 0.
+	ppu.write(0x3F00, 0x0F)
+	ppu.write(0x3F01, 0x16)
+	ppu.write(0x3F15, 0x2A)
+	copy(ppu.sprRAM[4:], []byte{50, 0x01, 0x01, 100}) // Sprite 1: tile 1, palette 5.
+
+	// Scroll to (16, 0) in nametable 1.
+	ppu.SetControlRegister(0x01)
+	ppu.WriteScroll(16)
+	ppu.WriteScroll(0)
+
+	logger := NewCodeDataLogger(console)
+
+	invert := func(c color.RGBA) color.RGBA {
+		return color.RGBA{^c.R, ^c.G, ^c.B, 0xFF}
+	}
+
+	nametables := ppu.NametablesImage(palette)
+	patterns := ppu.PatternTableImage(0, 0, palette)
+	paletteRAM := ppu.PaletteRAMImage(palette)
+	oam := ppu.OAMImage(palette)
+
+	tests := []struct {
+		name     string
+		actual   color.RGBA
+		expected color.RGBA
+	}{
+		{"nametable tile", nametables.RGBAAt(8, 8), palette[0x16]},
+		{"nametable backdrop", nametables.RGBAAt(20, 8), palette[0x0F]},
+		{"scroll outline left", nametables.RGBAAt(256+16, 100), invert(palette[0x0F])},
+		{"scroll outline right, wrapped", nametables.RGBAAt(15, 100), invert(palette[0x0F])},
+		{"inside scroll outline", nametables.RGBAAt(256+17, 100), palette[0x0F]},
+		{"pattern table tile 1", patterns.RGBAAt(8, 0), palette[0x16]},
+		{"pattern table tile 0", patterns.RGBAAt(0, 0), palette[0x0F]},
+		{"palette RAM $3F01", paletteRAM.RGBAAt(16, 0), palette[0x16]},
+		{"palette RAM $3F15", paletteRAM.RGBAAt(5*16, 16), palette[0x2A]},
+		{"OAM sprite 1", oam.RGBAAt(8, 0), palette[0x2A]},
+		{"OAM sprite 0", oam.RGBAAt(0, 0), palette[0x0F]},
+	}
+
+	for _, test := range tests {
+		if test.actual != test.expected {
+			t.Errorf("%s: got %v, expected %v\n", test.name, test.actual, test.expected)
+		}
+	}
+
+	// The viewers read without side effects.
+	for i, flags := range logger.CHR {
+		if flags != 0 {
+			t.Fatalf("CHR byte $%04X logged as %02X by viewers\n", i, flags)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package nes
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FrameStats are the CPU cycles used in a PPU frame.
+type FrameStats struct {
+	Frame uint64
+
+	// Cycles executed in the frame.
+	Cycles uint64
+
+	// Cycles executed in the NMI handler, including the routines it calls.
+	NMICycles uint64
+
+	// Cycles executed in the NMI handler before the end of vblank. If this is
+	// less than NMICycles, the handler overran vblank.
+	NMIVBlankCycles uint64
+}
+
+// Overran returns true if the NMI handler was still running after vblank.
+func (f *FrameStats) Overran() bool {
+	return f.NMICycles > f.NMIVBlankCycles
+}
+
+// A Profiler attributes the CPU cycles used by each instruction executed by a
+// Console to the instruction's PC, and to the call stack leading to it.
+//
+// Call stacks are tracked through JSR, RTS, RTI and interrupts. A call returns
+// when the stack pointer rises back to its level before the call, so code
+// which pushes an address and uses RTS as an indirect jump doesn't confuse the
+// call stack.
+//
+// Profiles are written in pprof format, for use with `go tool pprof`. Each
+// routine is named by the label at its entry point, or its address.
+type Profiler struct {
+	// Labels used to name routines, may be nil.
+	Labels Labeler
+
+	// Cycles used in each frame since the Profiler was attached.
+	Frames []FrameStats
+
+	console *Console
+
+	// Active calls, outermost first.
+	stack []profileCall
+
+	// Entry point of the outermost routine, i.e. the PC when the Profiler was
+	// attached.
+	rootEntry uint16
+
+	// Number of NMI handler calls on stack.
+	nmiDepth int
+
+	// Instruction being executed.
+	pc     uint16
+	sp     byte
+	opcode byte
+	cycles uint64
+
+	samples   map[string]*profileSample
+	functions map[profileFunction]uint64
+	locations map[profileLocation]uint64
+}
+
+// A call to a routine, by JSR or an interrupt.
+type profileCall struct {
+	// Address of the JSR, or the interrupted instruction.
+	site uint16
+
+	// Entry point of the called routine.
+	entry uint16
+
+	// Stack pointer before the call.
+	sp byte
+
+	nmi bool
+}
+
+// Cycles and instructions executed by a call stack.
+type profileSample struct {
+	locations    []uint64
+	cycles       uint64
+	instructions uint64
+}
+
+// A routine, identified by its entry point and the PRG ROM offset mapped
+// there (or -1).
+type profileFunction struct {
+	entry     uint16
+	prgOffset int
+}
+
+// An instruction in a routine.
+type profileLocation struct {
+	pc       uint16
+	function uint64
+}
+
+// NewProfiler returns a Profiler, and attaches it to console. Profiling stops
+// when console.Profiler is set to nil.
+func NewProfiler(console *Console) *Profiler {
+	p := &Profiler{
+		console:   console,
+		rootEntry: console.CPU.PC,
+		samples:   make(map[string]*profileSample),
+		functions: make(map[profileFunction]uint64),
+		locations: make(map[profileLocation]uint64),
+	}
+	console.Profiler = p
+
+	return p
+}
+
+// Called before each CPU step.
+func (p *Profiler) beforeStep() {
+	var cpu *CPU = p.console.CPU
+
+	p.pc = cpu.PC
+	p.sp = cpu.SP
+	p.cycles = cpu.NumCycles
+
+	// The CPU takes a pending NMI or IRQ before the instruction.
+	if cpu.isNMIDue() {
+		p.call(cpu.PC, p.peek16(NMIVector), cpu.SP, true)
+
+		p.pc = p.peek16(NMIVector)
+		p.sp = cpu.SP - 3
+	} else if !cpu.flagInterruptDisable && cpu.Bus.IRQ() {
+		p.call(cpu.PC, p.peek16(InterruptVector), cpu.SP, false)
+
+		p.pc = p.peek16(InterruptVector)
+		p.sp = cpu.SP - 3
+	}
+
+	p.opcode = cpu.Bus.Peek(p.pc)
+}
+
+// Called after each CPU step.
+func (p *Profiler) afterStep() {
+	var cpu *CPU = p.console.CPU
+	var ppu *PPU = p.console.PPU
+	var region Region = p.console.region
+	var cycles uint64 = cpu.NumCycles - p.cycles
+
+	p.sample(cycles)
+
+	if len(p.Frames) == 0 || p.Frames[len(p.Frames)-1].Frame != ppu.Frame {
+		p.Frames = append(p.Frames, FrameStats{Frame: ppu.Frame})
+	}
+
+	var frame *FrameStats = &p.Frames[len(p.Frames)-1]
+	frame.Cycles += cycles
+
+	if p.nmiDepth > 0 {
+		frame.NMICycles += cycles
+
+		if ppu.Scanline >= region.VBlankScanline() && ppu.Scanline < region.PrerenderScanline() {
+			frame.NMIVBlankCycles += cycles
+		}
+	}
+
+	if p.opcode == 0x20 {
+		p.call(p.pc, cpu.PC, p.sp, false)
+	}
+
+	p.unwind()
+}
+
+// Records a call to entry from site.
+func (p *Profiler) call(site uint16, entry uint16, sp byte, nmi bool) {
+	p.stack = append(p.stack, profileCall{site: site, entry: entry, sp: sp, nmi: nmi})
+
+	if nmi {
+		p.nmiDepth++
+	}
+}
+
+// Removes calls which have returned, i.e. the stack pointer has risen to its
+// level before the call.
+func (p *Profiler) unwind() {
+	var sp byte = p.console.CPU.SP
+
+	for len(p.stack) > 0 && p.stack[len(p.stack)-1].sp <= sp {
+		if p.stack[len(p.stack)-1].nmi {
+			p.nmiDepth--
+		}
+
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// Attributes cycles to the instruction at p.pc and the current call stack.
+func (p *Profiler) sample(cycles uint64) {
+	var locations []uint64 = make([]uint64, 0, len(p.stack)+1)
+	var pc uint16 = p.pc
+
+	// Innermost call first.
+	for i := len(p.stack); i >= 0; i-- {
+		var entry uint16 = p.rootEntry
+		if i > 0 {
+			entry = p.stack[i-1].entry
+		}
+
+		locations = append(locations, p.location(pc, entry))
+
+		if i > 0 {
+			pc = p.stack[i-1].site
+		}
+	}
+
+	var key string = fmt.Sprint(locations)
+
+	s, ok := p.samples[key]
+	if !ok {
+		s = &profileSample{locations: locations}
+		p.samples[key] = s
+	}
+
+	s.cycles += cycles
+	s.instructions++
+}
+
+// Returns the location ID of the instruction at pc, in the routine at entry.
+func (p *Profiler) location(pc uint16, entry uint16) uint64 {
+	f := profileFunction{entry: entry, prgOffset: p.console.Cart.PRGOffset(entry)}
+
+	function, ok := p.functions[f]
+	if !ok {
+		function = uint64(len(p.functions) + 1)
+		p.functions[f] = function
+	}
+
+	l := profileLocation{pc: pc, function: function}
+
+	id, ok := p.locations[l]
+	if !ok {
+		id = uint64(len(p.locations) + 1)
+		p.locations[l] = id
+	}
+
+	return id
+}
+
+func (p *Profiler) peek16(address uint16) uint16 {
+	var bus Bus = p.console.CPU.Bus
+
+	return uint16(bus.Peek(address)) | uint16(bus.Peek(address+1))<<8
+}
+
+// Returns the name of a routine.
+func (p *Profiler) functionName(f profileFunction) string {
+	if p.Labels != nil {
+		if name, ok := p.Labels.Label(f.entry); ok {
+			return name
+		}
+	}
+
+	if f.prgOffset != -1 && len(p.console.Cart.PRG) > 2 {
+		return fmt.Sprintf("$%04X (bank %d)", f.entry, f.prgOffset/0x4000)
+	}
+
+	return fmt.Sprintf("$%04X", f.entry)
+}
+
+// WriteProfile writes the profile in gzipped pprof format, with samples of
+// CPU cycles and instructions.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	var b protoBuffer
+
+	indexes := map[string]uint64{"": 0}
+	var stringTable []string = []string{""}
+
+	str := func(s string) uint64 {
+		if _, ok := indexes[s]; !ok {
+			indexes[s] = uint64(len(stringTable))
+			stringTable = append(stringTable, s)
+		}
+
+		return indexes[s]
+	}
+
+	valueType := func(kind string, unit string) func(m *protoBuffer) {
+		return func(m *protoBuffer) {
+			m.uint64(1, str(kind))
+			m.uint64(2, str(unit))
+		}
+	}
+
+	// sample_type
+	b.message(1, valueType("cycles", "count"))
+	b.message(1, valueType("instructions", "count"))
+
+	// sample, sorted for reproducible output.
+	var keys []string
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var totalCycles uint64
+
+	for _, key := range keys {
+		var s *profileSample = p.samples[key]
+		totalCycles += s.cycles
+
+		b.message(2, func(m *protoBuffer) {
+			m.packed(1, s.locations)
+			m.packed(2, []uint64{s.cycles, s.instructions})
+		})
+	}
+
+	// mapping: the CPU address space.
+	b.message(3, func(m *protoBuffer) {
+		m.uint64(1, 1)
+		m.uint64(3, 0x10000)
+		m.uint64(5, str("6502"))
+		m.uint64(7, 1)
+	})
+
+	// location
+	locations := make([]profileLocation, len(p.locations))
+	for l, id := range p.locations {
+		locations[id-1] = l
+	}
+
+	for i, l := range locations {
+		b.message(4, func(m *protoBuffer) {
+			m.uint64(1, uint64(i+1))
+			m.uint64(2, 1)
+			m.uint64(3, uint64(l.pc))
+			m.message(4, func(line *protoBuffer) {
+				line.uint64(1, l.function)
+			})
+		})
+	}
+
+	// function
+	functions := make([]profileFunction, len(p.functions))
+	for f, id := range p.functions {
+		functions[id-1] = f
+	}
+
+	for i, f := range functions {
+		var name uint64 = str(p.functionName(f))
+
+		b.message(5, func(m *protoBuffer) {
+			m.uint64(1, uint64(i+1))
+			m.uint64(2, name)
+			m.uint64(3, name)
+		})
+	}
+
+	// duration_nanos, period_type and period
+	b.uint64(10, uint64(float64(totalCycles)*1e9/p.console.region.CPUFrequency()))
+	b.message(11, valueType("cycles", "count"))
+	b.uint64(12, 1)
+
+	// default_sample_type
+	b.uint64(14, str("cycles"))
+
+	// string_table, written last as the other fields add to it.
+	for _, s := range stringTable {
+		b.bytes(6, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(b); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// WriteFrameReport writes a table of the cycles used in each frame, and in its
+// NMI handler, followed by a summary of the NMI handler's use of vblank.
+func (p *Profiler) WriteFrameReport(w io.Writer) error {
+	var report strings.Builder
+	var vblankCycles uint64 = p.console.region.VBlankCycles()
+
+	fmt.Fprintf(&report, "%8s %8s %8s %8s\n", "frame", "cycles", "nmi", "vblank")
+
+	var worst *FrameStats
+	var overruns int
+
+	for i := range p.Frames {
+		var f *FrameStats = &p.Frames[i]
+
+		var overrun string
+		if f.Overran() {
+			overrun = " overrun"
+			overruns++
+		}
+
+		fmt.Fprintf(&report, "%8d %8d %8d %7d%%%s\n",
+			f.Frame, f.Cycles, f.NMICycles, f.NMICycles*100/vblankCycles, overrun)
+
+		if worst == nil || f.NMICycles > worst.NMICycles {
+			worst = f
+		}
+	}
+
+	if worst != nil {
+		fmt.Fprintf(&report, "\nlongest NMI handler: %d cycles (%d%% of %d vblank cycles) in frame %d\n",
+			worst.NMICycles, worst.NMICycles*100/vblankCycles, vblankCycles, worst.Frame)
+		fmt.Fprintf(&report, "NMI handler overran vblank in %d of %d frames\n", overruns, len(p.Frames))
+	}
+
+	_, err := io.WriteString(w, report.String())
+	return err
+}
@@ -0,0 +1,108 @@
+package nes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	program := make([]byte, 0x30)
+	copy(program, []byte{
+		0x20, 0x10, 0x80, // $8000 JSR $8010
+		0x4C, 0x00, 0x80, // $8003 JMP $8000
+	})
+	copy(program[0x10:], []byte{
+		0xA2, 0x05, // $8010 LDX #$05
+		0xCA,       // $8012 DEX
+		0xD0, 0xFD, // $8013 BNE $8012
+		0x60, //       $8015 RTS
+	})
+	copy(program[0x20:], []byte{
+		0xA9, 0x00, // $8020 LDA #$00
+		0x40, //       $8022 RTI
+	})
+
+	console := newTestConsole(program)
+	console.Cart.PRG[1][0x3FFA] = 0x20
+	console.Cart.PRG[1][0x3FFB] = 0x80
+	console.PPU.flagNMIOnVBlank = true
+
+	profiler := NewProfiler(console)
+
+	for len(profiler.Frames) < 3 {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cycles are attributed to call stacks.
+	var total uint64
+	var dex uint64
+
+	for _, s := range profiler.samples {
+		total += s.cycles
+
+		var pcs []uint16
+		for _, id := range s.locations {
+			for l, lid := range profiler.locations {
+				if lid == id {
+					pcs = append(pcs, l.pc)
+				}
+			}
+		}
+
+		if pcs[0] == 0x8012 && len(pcs) == 2 && pcs[1] == 0x8000 {
+			dex += s.cycles
+		}
+	}
+
+	if total != console.CPU.NumCycles {
+		t.Errorf("profiled %d cycles, expected %d\n", total, console.CPU.NumCycles)
+	}
+
+	if dex == 0 {
+		t.Errorf("no cycles attributed to DEX called from $8000\n")
+	}
+
+	// The NMI handler runs LDA and RTI once per frame, after the 7 cycle
+	// interrupt sequence.
+	var frame FrameStats = profiler.Frames[1]
+	if frame.NMICycles != 15 || frame.NMIVBlankCycles != 15 || frame.Overran() {
+		t.Errorf("got NMI stats %+v, expected 15 cycles in vblank\n", frame)
+	}
+
+	if len(profiler.stack) > 2 {
+		t.Errorf("call stack has %d calls, expected at most 2\n", len(profiler.stack))
+	}
+
+	var report bytes.Buffer
+	if err := profiler.WriteFrameReport(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(report.String(), "longest NMI handler: 15 cycles") {
+		t.Errorf("unexpected frame report:\n%s", report.String())
+	}
+
+	var profile bytes.Buffer
+	if err := profiler.WriteProfile(&profile); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := gzip.NewReader(&profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(data, []byte("$8010")) || !bytes.Contains(data, []byte("cycles")) {
+		t.Errorf("profile missing function name or sample type\n")
+	}
+}
@@ -0,0 +1,155 @@
+package nes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Region is a console's video standard, which determines its CPU and PPU
+// timing, and the APU's period tables (for an APU, which the console doesn't
+// have yet).
+//
+// http://wiki.nesdev.com/w/index.php/Cycle_reference_chart
+type Region int
+
+const (
+	RegionNTSC  Region = iota // North America and Japan (RP2C02 PPU).
+	RegionPAL                 // Europe and Australia (RP2C07 PPU).
+	RegionDendy               // Famiclones such as the Dendy (UA6538 PPU).
+)
+
+// ParseRegion returns the region called name: "ntsc", "pal" or "dendy" (in
+// any case).
+func ParseRegion(name string) (Region, error) {
+	for _, region := range []Region{RegionNTSC, RegionPAL, RegionDendy} {
+		if strings.EqualFold(name, region.String()) {
+			return region, nil
+		}
+	}
+
+	return RegionNTSC, fmt.Errorf("unknown region %q", name)
+}
+
+// String returns the name of the region, e.g. "NTSC".
+func (r Region) String() string {
+	switch r {
+	case RegionPAL:
+		return "PAL"
+	case RegionDendy:
+		return "Dendy"
+	default:
+		return "NTSC"
+	}
+}
+
+// CPUFrequency returns the number of CPU cycles per second.
+func (r Region) CPUFrequency() float64 {
+	switch r {
+	case RegionPAL:
+		return 26601712.5 / 16
+	case RegionDendy:
+		return 26601712.5 / 15
+	default:
+		return 236250000.0 / 11 / 12
+	}
+}
+
+// FrameRate returns the number of frames per second, e.g. around 60.1 for
+// NTSC and 50.0 for PAL.
+func (r Region) FrameRate() float64 {
+	return r.CPUFrequency() * float64(r.ppuCycles(1000)) / 1000 / float64(r.Scanlines()*341)
+}
+
+// Scanlines returns the number of scanlines in a frame, including the
+// prerender scanline: 262 for NTSC, and 312 for PAL and Dendy.
+func (r Region) Scanlines() int {
+	if r == RegionNTSC {
+		return 262
+	}
+
+	return 312
+}
+
+// VBlankScanline returns the scanline on which vblank starts. Dendy has 50
+// idle scanlines after the picture, so vblank is as short as NTSC's.
+func (r Region) VBlankScanline() int {
+	if r == RegionDendy {
+		return 291
+	}
+
+	return 241
+}
+
+// PrerenderScanline returns the last scanline of a frame, on which vblank
+// ends.
+func (r Region) PrerenderScanline() int {
+	return r.Scanlines() - 1
+}
+
+// VBlankCycles returns the approximate number of CPU cycles in vblank.
+func (r Region) VBlankCycles() uint64 {
+	var scanlines int = r.PrerenderScanline() - r.VBlankScanline()
+
+	return r.cpuCycles(uint64(scanlines * 341))
+}
+
+// NoisePeriods returns the APU noise channel's timer periods in CPU cycles,
+// indexed by the period written to $400E. Dendy uses the NTSC table.
+//
+// http://wiki.nesdev.com/w/index.php/APU_Noise
+func (r Region) NoisePeriods() [16]int {
+	if r == RegionPAL {
+		return [16]int{4, 8, 14, 30, 60, 88, 118, 148, 188, 236, 354, 472, 708, 944, 1890, 3778}
+	}
+
+	return [16]int{4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068}
+}
+
+// DMCRates returns the APU DMC channel's output periods in CPU cycles, indexed
+// by the rate written to $4010. Dendy uses the NTSC table.
+//
+// http://wiki.nesdev.com/w/index.php/APU_DMC
+func (r Region) DMCRates() [16]int {
+	if r == RegionPAL {
+		return [16]int{398, 354, 316, 298, 276, 236, 210, 198, 176, 148, 132, 118, 98, 78, 66, 50}
+	}
+
+	return [16]int{428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54}
+}
+
+// FrameCounterSteps returns the CPU cycles, counted from the reset of the APU
+// frame counter, on which each step of its 4-step or 5-step sequence happens.
+// Dendy uses the NTSC timing.
+//
+// http://wiki.nesdev.com/w/index.php/APU_Frame_Counter
+func (r Region) FrameCounterSteps(fiveStep bool) []int {
+	switch {
+	case r == RegionPAL && fiveStep:
+		return []int{8313, 16627, 24939, 33253, 41565}
+	case r == RegionPAL:
+		return []int{8313, 16627, 24939, 33253}
+	case fiveStep:
+		return []int{7457, 14913, 22371, 29829, 37281}
+	default:
+		return []int{7457, 14913, 22371, 29829}
+	}
+}
+
+// Returns the number of PPU cycles in cpuCycles CPU cycles: 3.2 per CPU cycle
+// for PAL, and 3 otherwise.
+func (r Region) ppuCycles(cpuCycles uint64) uint64 {
+	if r == RegionPAL {
+		return cpuCycles * 16 / 5
+	}
+
+	return cpuCycles * 3
+}
+
+// Returns the CPU cycle during which PPU cycle ppuCycles happens.
+func (r Region) cpuCycles(ppuCycles uint64) uint64 {
+	if r == RegionPAL {
+		return ppuCycles * 5 / 16
+	}
+
+	return ppuCycles / 3
+}
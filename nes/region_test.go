@@ -0,0 +1,91 @@
+package nes
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRegionFrameRates(t *testing.T) {
+	tests := []struct {
+		region    Region
+		frameRate float64
+	}{
+		{RegionNTSC, 60.0988},
+		{RegionPAL, 50.0070},
+		{RegionDendy, 50.0070},
+	}
+
+	for _, test := range tests {
+		if rate := test.region.FrameRate(); math.Abs(rate-test.frameRate) > 0.001 {
+			t.Errorf("%s: frame rate is %.4f, expected %.4f\n", test.region, rate, test.frameRate)
+		}
+
+		region, err := ParseRegion(test.region.String())
+		if err != nil || region != test.region {
+			t.Errorf("ParseRegion(%q) = %s, %v\n", test.region.String(), region, err)
+		}
+	}
+
+	if _, err := ParseRegion("secam"); err == nil {
+		t.Errorf("parsed unknown region\n")
+	}
+}
+
+func TestRegionAPUTables(t *testing.T) {
+	tests := []struct {
+		region      Region
+		noise       int
+		dmc         int
+		fiveStepEnd int
+	}{
+		{RegionNTSC, 4068, 54, 37281},
+		{RegionPAL, 3778, 50, 41565},
+		{RegionDendy, 4068, 54, 37281},
+	}
+
+	for _, test := range tests {
+		if noise := test.region.NoisePeriods()[15]; noise != test.noise {
+			t.Errorf("%s: noise period 15 is %d, expected %d\n", test.region, noise, test.noise)
+		}
+
+		if dmc := test.region.DMCRates()[15]; dmc != test.dmc {
+			t.Errorf("%s: DMC rate 15 is %d, expected %d\n", test.region, dmc, test.dmc)
+		}
+
+		steps := test.region.FrameCounterSteps(true)
+		if len(steps) != 5 || steps[4] != test.fiveStepEnd || len(test.region.FrameCounterSteps(false)) != 4 {
+			t.Errorf("%s: frame counter steps are %v, expected 5 ending at %d\n", test.region, steps, test.fiveStepEnd)
+		}
+	}
+}
+
+func TestCartridgeRegion(t *testing.T) {
+	tests := []struct {
+		control2 byte
+		timing   byte
+		expected Region
+	}{
+		{0x00, 0x01, RegionNTSC}, // iNES 1.0 ignores byte 12.
+		{0x08, 0x00, RegionNTSC},
+		{0x08, 0x01, RegionPAL},
+		{0x08, 0x02, RegionNTSC}, // Multiple region.
+		{0x08, 0x03, RegionDendy},
+	}
+
+	for _, test := range tests {
+		var rom []byte = make([]byte, 16+16384+8192)
+		copy(rom, "NES\x1a\x01\x01")
+		rom[7] = test.control2
+		rom[12] = test.timing
+
+		cart, err := ReadCartridge(bytes.NewReader(rom))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cart.Region != test.expected {
+			t.Errorf("header $%02X, $%02X: region is %s, expected %s\n", test.control2, test.timing, cart.Region, test.expected)
+		}
+	}
+}
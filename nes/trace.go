@@ -0,0 +1,206 @@
+package nes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TraceFormat selects the line format written by a Tracer.
+type TraceFormat int
+
+const (
+	// Format of nestest.log, e.g.
+	// C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD CYC:  0 SL:241
+	TraceNestest TraceFormat = iota
+
+	// Format of FCEUX's trace logger with the frame and cycle counts, registers
+	// and processor status logged, e.g.
+	// f0      c0           A:00 X:00 Y:00 S:FD P:nvUbdIzc  $C000:4C F5 C5  JMP $C5F5
+	//
+	// FCEUX's annotations of the memory accessed are not written.
+	TraceFCEUX
+
+	// Format of Mesen's trace logger, with the format
+	// [PC,4h]  [ByteCode,15h] [Disassembly][Align,48] A:[A,2h] X:[X,2h] Y:[Y,2h] P:[P,2h] SP:[SP,2h] CYC:[Cycle,3] SL:[Scanline,3] FC:[FrameCount] CPU Cycle:[CycleCount]
+	// e.g.
+	// C000  $4C $F5 $C5     JMP $C5F5                  A:00 X:00 Y:00 P:24 SP:FD CYC:0   SL:241 FC:0 CPU Cycle:0
+	TraceMesen
+)
+
+// A Tracer writes a line to a log for every instruction executed by a Console,
+// showing the CPU registers, PPU position and CPU cycle count.
+//
+// Lines are only written for instructions matching all of the filters.
+type Tracer struct {
+	Format TraceFormat
+
+	// Labels used to name addresses, may be nil.
+	Labels Labeler
+
+	// Only trace instructions with MinPC <= PC <= MaxPC.
+	MinPC uint16
+	MaxPC uint16
+
+	// Only trace instructions in this 16k PRG bank, unless -1.
+	Bank int
+
+	// Only trace instructions from this PPU frame onwards.
+	AfterFrame uint64
+
+	console *Console
+	writer  io.Writer
+}
+
+// NewTracer returns a Tracer writing to writer in the given format, and
+// attaches it to console. Tracing stops when console.Tracer is set to nil.
+//
+// The Tracer initially traces every instruction.
+func NewTracer(console *Console, writer io.Writer, format TraceFormat) *Tracer {
+	t := &Tracer{
+		Format:  format,
+		MaxPC:   0xFFFF,
+		Bank:    -1,
+		console: console,
+		writer:  writer,
+	}
+	console.Tracer = t
+
+	return t
+}
+
+// Traces the instruction about to be executed.
+func (t *Tracer) trace() error {
+	var cpu *CPU = t.console.CPU
+	var ppu *PPU = t.console.PPU
+
+	if cpu.PC < t.MinPC || cpu.PC > t.MaxPC || ppu.Frame < t.AfterFrame {
+		return nil
+	}
+
+	if t.Bank != -1 {
+		offset := t.console.Cart.PRGOffset(cpu.PC)
+		if offset == -1 || offset/0x4000 != t.Bank {
+			return nil
+		}
+	}
+
+	var instruction *DisassembledInstruction = NewDisassembler(cpu.Bus.Peek, t.Labels).Disassemble(cpu.PC)
+	var line string
+
+	switch t.Format {
+	case TraceFCEUX:
+		line = fmt.Sprintf("f%-6d c%-11d A:%02X X:%02X Y:%02X S:%02X P:%s  $%04X:%-10s%s\n",
+			ppu.Frame,
+			cpu.NumCycles,
+			cpu.A,
+			cpu.X,
+			cpu.Y,
+			cpu.SP,
+			flagString(cpu.P()),
+			cpu.PC,
+			fmt.Sprintf("% X", instruction.Bytes),
+			instruction.Assembly())
+	case TraceMesen:
+		var bytes []string
+		for _, b := range instruction.Bytes {
+			bytes = append(bytes, fmt.Sprintf("$%02X", b))
+		}
+
+		line = fmt.Sprintf("%04X  %-15s %-26s A:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%-3d SL:%-3d FC:%d CPU Cycle:%d\n",
+			cpu.PC,
+			strings.Join(bytes, " "),
+			instruction.Assembly(),
+			cpu.A,
+			cpu.X,
+			cpu.Y,
+			cpu.P(),
+			cpu.SP,
+			ppu.Tick,
+			ppu.Scanline,
+			ppu.Frame,
+			cpu.NumCycles)
+	default:
+		var scanline int = ppu.Scanline
+		if scanline == t.console.region.PrerenderScanline() {
+			scanline = -1
+		}
+
+		line = fmt.Sprintf("%-47s A:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%3d SL:%d\n",
+			instruction.String()+t.annotate(instruction),
+			cpu.A,
+			cpu.X,
+			cpu.Y,
+			cpu.P(),
+			cpu.SP,
+			ppu.Tick,
+			scanline)
+	}
+
+	_, err := io.WriteString(t.writer, line)
+
+	return err
+}
+
+// Returns the nestest.log style annotation of the memory an instruction
+// accesses, e.g. " = 00" for "LDA $00 = 00".
+func (t *Tracer) annotate(i *DisassembledInstruction) string {
+	var cpu *CPU = t.console.CPU
+	var peek func(address uint16) byte = cpu.Bus.Peek
+
+	var peek16 = func(address uint16, wrap bool) uint16 {
+		var high uint16 = address + 1
+		if wrap {
+			high = address&0xFF00 | (address+1)&0x00FF
+		}
+
+		return uint16(peek(address)) | uint16(peek(high))<<8
+	}
+
+	switch i.Opcode.Mode {
+	case ZeroPage, Absolute:
+		if i.Opcode.Name == "JMP" || i.Opcode.Name == "JSR" {
+			return ""
+		}
+
+		return fmt.Sprintf(" = %02X", peek(i.Target))
+	case ZeroPageX:
+		address := uint16(byte(i.Target) + cpu.X)
+		return fmt.Sprintf(" @ %02X = %02X", address, peek(address))
+	case ZeroPageY:
+		address := uint16(byte(i.Target) + cpu.Y)
+		return fmt.Sprintf(" @ %02X = %02X", address, peek(address))
+	case AbsoluteX:
+		address := i.Target + uint16(cpu.X)
+		return fmt.Sprintf(" @ %04X = %02X", address, peek(address))
+	case AbsoluteY:
+		address := i.Target + uint16(cpu.Y)
+		return fmt.Sprintf(" @ %04X = %02X", address, peek(address))
+	case Indirect:
+		return fmt.Sprintf(" = %04X", peek16(i.Target, true))
+	case IndirectX:
+		pointer := uint16(byte(i.Target) + cpu.X)
+		address := peek16(pointer, true)
+		return fmt.Sprintf(" @ %02X = %04X = %02X", pointer, address, peek(address))
+	case IndirectY:
+		base := peek16(i.Target, true)
+		address := base + uint16(cpu.Y)
+		return fmt.Sprintf(" = %04X @ %04X = %02X", base, address, peek(address))
+	}
+
+	return ""
+}
+
+// Returns the processor status p as a string, e.g. "nvUbdIzc". Set flags are
+// upper case.
+func flagString(p byte) string {
+	var result []byte = []byte("nvubdizc")
+
+	for i := range result {
+		if p&(0x80>>uint(i)) != 0 {
+			result[i] -= 'a' - 'A'
+		}
+	}
+
+	return string(result)
+}
@@ -0,0 +1,144 @@
+package nes
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTracerNestestFormat(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xA2, 0x02, // LDX #$02
+		0xBD, 0x00, 0x03, // LDA $0300,X
+		0x8D, 0x00, 0x02, // STA $0200
+	})
+	console.CPU.RAM[0x302] = 0x5A
+	console.CPU.RAM[0x200] = 0x11
+
+	var output bytes.Buffer
+	NewTracer(console, &output, TraceNestest)
+
+	for i := 0; i < 3; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(output.String(), "\n")
+
+	// The PPU starts at scanline 241, and runs 3 ticks per CPU cycle.
+	expected := []string{
+		"8000  A2 02     LDX #$02                        A:00 X:00 Y:00 P:24 SP:FD CYC:  0 SL:241",
+		"8002  BD 00 03  LDA $0300,X @ 0302 = 5A         A:00 X:02 Y:00 P:24 SP:FD CYC:  6 SL:241",
+		"8005  8D 00 02  STA $0200 = 11                  A:5A X:02 Y:00 P:24 SP:FD CYC: 18 SL:241",
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("\nActual  : %q\nExpected: %q\n", lines[i], expected[i])
+		}
+	}
+
+	// Can be parsed by the nestest.log reader used by the CPU tests.
+	for i := range expected {
+		if _, _, err := ReadNESTestLine(bufio.NewReader(strings.NewReader(lines[i] + "\n"))); err != nil {
+			t.Errorf("%q: %s\n", lines[i], err)
+		}
+	}
+}
+
+func TestTracerFCEUXFormat(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xA2, 0x02, //       LDX #$02
+		0xEA,             // NOP
+		0x4C, 0xF5, 0xC5, // JMP $C5F5
+	})
+
+	var output bytes.Buffer
+	NewTracer(console, &output, TraceFCEUX)
+
+	for i := 0; i < 3; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(output.String(), "\n")
+
+	expected := []string{
+		"f0      c0           A:00 X:00 Y:00 S:FD P:nvUbdIzc  $8000:A2 02     LDX #$02",
+		"f0      c2           A:00 X:02 Y:00 S:FD P:nvUbdIzc  $8002:EA        NOP",
+		"f0      c4           A:00 X:02 Y:00 S:FD P:nvUbdIzc  $8003:4C F5 C5  JMP $C5F5",
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("\nActual  : %q\nExpected: %q\n", lines[i], expected[i])
+		}
+	}
+}
+
+func TestTracerMesenFormat(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xA2, 0x02, //       LDX #$02
+		0xEA,             // NOP
+		0x4C, 0xF5, 0xC5, // JMP $C5F5
+	})
+
+	var output bytes.Buffer
+	NewTracer(console, &output, TraceMesen)
+
+	for i := 0; i < 3; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(output.String(), "\n")
+
+	expected := []string{
+		"8000  $A2 $02         LDX #$02                   A:00 X:00 Y:00 P:24 SP:FD CYC:0   SL:241 FC:0 CPU Cycle:0",
+		"8002  $EA             NOP                        A:00 X:02 Y:00 P:24 SP:FD CYC:6   SL:241 FC:0 CPU Cycle:2",
+		"8003  $4C $F5 $C5     JMP $C5F5                  A:00 X:02 Y:00 P:24 SP:FD CYC:12  SL:241 FC:0 CPU Cycle:4",
+	}
+
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("\nActual  : %q\nExpected: %q\n", lines[i], expected[i])
+		}
+	}
+}
+
+func TestTracerFilters(t *testing.T) {
+	console := newTestConsole([]byte{
+		0xEA,             // NOP
+		0xEA,             // NOP
+		0x4C, 0x00, 0x80, // JMP $8000
+	})
+
+	var output bytes.Buffer
+	tracer := NewTracer(console, &output, TraceFCEUX)
+	tracer.MinPC = 0x8001
+	tracer.MaxPC = 0x8001
+
+	for i := 0; i < 6; i++ {
+		if _, err := console.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := strings.Count(output.String(), "\n"); n != 2 {
+		t.Fatalf("traced %d lines, expected 2:\n%s", n, output.String())
+	}
+
+	output.Reset()
+	tracer.MinPC = 0
+	tracer.MaxPC = 0xFFFF
+	tracer.Bank = 1
+
+	console.Step()
+	if output.Len() != 0 {
+		t.Fatalf("traced instruction outside bank 1:\n%s", output.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/skip2/nes/filter"
+	"github.com/skip2/nes/nes"
+)
+
+// runScreenshot implements the "screenshot" command, which runs a ROM file
+// without the GUI and saves a frame as a PNG image.
+func runScreenshot(args []string) error {
+	flags := flag.NewFlagSet("screenshot", flag.ExitOnError)
+	aspect := flags.Bool("aspect", false, "stretch the frame to the 8:7 pixel aspect ratio of a TV")
+	frame := flags.Int("frame", 60, "number of the frame to save, from 1")
+	filterName := flags.String("filter", "", "upscaling filter: one of "+strings.Join(filter.Names(), ", "))
+	ntsc := flags.Bool("ntsc", false, "simulate NTSC composite video")
+	output := flags.String("o", "screenshot.png", "PNG file to write")
+	overscan := flags.String("overscan", "", overscanUsage)
+	regionName := flags.String("region", "auto", regionUsage)
+
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: nes screenshot [options] FILENAME.ROM")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *frame < 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	var f filter.Filter
+	if *filterName != "" {
+		var err error
+		f, err = filter.New(*filterName)
+		if err != nil {
+			return err
+		}
+	}
+
+	display, err := newDisplay(*overscan, *aspect)
+	if err != nil {
+		return err
+	}
+
+	cart, err := nes.LoadCartridge(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var console *nes.Console = nes.NewConsole(cart)
+
+	if err := setRegion(console, *regionName); err != nil {
+		return err
+	}
+
+	if *ntsc {
+		console.PPU.Converter = nes.NewNTSCConverter()
+	}
+
+	img, err := runFrames(console, *frame)
+	if err != nil {
+		return err
+	}
+
+	if f != nil {
+		img = f.Apply(img)
+	}
+
+	if display != nil {
+		img = display.Apply(img)
+	}
+
+	return writeFile(*output, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// Runs console until it has output frames frames, and returns the last.
+func runFrames(console *nes.Console, frames int) (*image.RGBA, error) {
+	var img *image.RGBA
+
+	for n := 0; n < frames; {
+		frame, err := console.Step()
+		if err != nil {
+			return nil, err
+		}
+
+		if frame != nil {
+			img = frame
+			n++
+		}
+	}
+
+	return img, nil
+}
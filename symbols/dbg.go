@@ -0,0 +1,357 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Line is a source line, and the code generated for it.
+type Line struct {
+	File string
+	Line int
+
+	// Location and size in bytes of the generated code in the CPU address
+	// space.
+	Address uint16
+	Size    int
+
+	// Offset of the code in PRG ROM, or -1 if unknown.
+	PRGOffset int
+}
+
+// DebugInfo is the source line and symbol information from a ca65/ld65 debug
+// info file, as written by ld65's --dbgfile option.
+//
+// See https://cc65.github.io/doc/debugging.html
+type DebugInfo struct {
+	// Source file names, as passed to the assembler.
+	Files []string
+
+	// Labels defined in the program.
+	Labels *Table
+
+	// PRGOffset maps a CPU address to the PRG ROM offset mapped there, or -1,
+	// e.g. Cartridge.PRGOffset. If set, lines in PRG ROM are only found while
+	// their bank is mapped. Labels.PRGOffset is set separately.
+	PRGOffset func(address uint16) int
+
+	// Lines generating code, sorted by Address.
+	lines []Line
+
+	// Largest Line.Size.
+	maxSize int
+}
+
+// LoadDebugInfo reads the debug info file filename.
+//
+// Relative source file names are interpreted relative to the directory
+// containing filename.
+func LoadDebugInfo(filename string) (*DebugInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	d, err := ReadDebugInfo(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	var dir string = filepath.Dir(filename)
+	var paths map[string]string = make(map[string]string)
+
+	for i, name := range d.Files {
+		if !filepath.IsAbs(name) {
+			paths[name] = filepath.Join(dir, name)
+			d.Files[i] = paths[name]
+		}
+	}
+
+	for i := range d.lines {
+		if path, ok := paths[d.lines[i].File]; ok {
+			d.lines[i].File = path
+		}
+	}
+
+	return d, nil
+}
+
+// A record in a debug info file, e.g. `file id=0,name="main.s"`, keyed by
+// attribute name.
+type dbgRecord map[string]string
+
+func (r dbgRecord) int(name string) (int, error) {
+	value, ok := r[name]
+	if !ok {
+		return 0, fmt.Errorf("missing attribute %q", name)
+	}
+
+	n, err := strconv.ParseInt(value, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+
+	return int(n), nil
+}
+
+// ReadDebugInfo reads a debug info file.
+func ReadDebugInfo(r io.Reader) (*DebugInfo, error) {
+	type seg struct {
+		// CPU address, and offset in PRG ROM or -1.
+		start, prgOffset int
+	}
+
+	type span struct {
+		seg, start, size int
+	}
+
+	files := make(map[int]string)
+	segs := make(map[int]seg)
+	spans := make(map[int]span)
+
+	var lines []dbgRecord
+	var syms []dbgRecord
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		kind, record, err := parseDbgRecord(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		var id int
+		if kind == "file" || kind == "seg" || kind == "span" {
+			id, err = record.int("id")
+		}
+
+		switch kind {
+		case "file":
+			files[id] = record["name"]
+		case "seg":
+			s := seg{prgOffset: -1}
+			s.start, err = record.int("start")
+
+			// Segments in the output file at $8000 and above are PRG ROM. The
+			// iNES header precedes the PRG ROM in .nes files.
+			if offset, ooffsErr := record.int("ooffs"); ooffsErr == nil && s.start >= 0x8000 {
+				if strings.HasSuffix(strings.ToLower(record["oname"]), ".nes") {
+					offset -= 16
+				}
+
+				s.prgOffset = offset
+			}
+
+			segs[id] = s
+		case "span":
+			var s span
+			if s.seg, err = record.int("seg"); err == nil {
+				if s.start, err = record.int("start"); err == nil {
+					s.size, err = record.int("size")
+				}
+			}
+			spans[id] = s
+		case "line":
+			lines = append(lines, record)
+		case "sym":
+			syms = append(syms, record)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	d := &DebugInfo{Labels: NewTable()}
+
+	for id := 0; id < len(files); id++ {
+		d.Files = append(d.Files, files[id])
+	}
+
+	for _, record := range lines {
+		// Lines of type 2 are macro expansions, report the invocation instead.
+		if record["type"] == "2" || record["span"] == "" {
+			continue
+		}
+
+		file, err := record.int("file")
+		if err != nil {
+			return nil, err
+		}
+
+		line, err := record.int("line")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, text := range strings.Split(record["span"], "+") {
+			id, err := strconv.Atoi(text)
+			s, ok := spans[id]
+			if err != nil || !ok {
+				return nil, fmt.Errorf("line %d references unknown span %q", line, text)
+			}
+
+			l := Line{
+				File:      files[file],
+				Line:      line,
+				Address:   uint16(segs[s.seg].start + s.start),
+				Size:      s.size,
+				PRGOffset: -1,
+			}
+
+			if segs[s.seg].prgOffset != -1 {
+				l.PRGOffset = segs[s.seg].prgOffset + s.start
+			}
+
+			d.lines = append(d.lines, l)
+
+			if s.size > d.maxSize {
+				d.maxSize = s.size
+			}
+		}
+	}
+
+	sort.SliceStable(d.lines, func(i, j int) bool {
+		return d.lines[i].Address < d.lines[j].Address
+	})
+
+	for _, record := range syms {
+		if record["type"] != "lab" {
+			continue
+		}
+
+		value, err := record.int("val")
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := record.int("seg")
+		if s, ok := segs[id]; err == nil && ok && s.prgOffset != -1 {
+			d.Labels.AddPRG(s.prgOffset+value-s.start, uint16(value), record["name"])
+		} else {
+			d.Labels.Add(uint16(value), record["name"])
+		}
+	}
+
+	return d, nil
+}
+
+// Parses a line of a debug info file into its kind (e.g. "file") and
+// attributes.
+func parseDbgRecord(text string) (string, dbgRecord, error) {
+	fields := strings.SplitN(strings.TrimSpace(text), "\t", 2)
+	if len(fields) != 2 {
+		return fields[0], nil, nil
+	}
+
+	record := make(dbgRecord)
+	attributes := fields[1]
+
+	for attributes != "" {
+		equals := strings.IndexByte(attributes, '=')
+		if equals == -1 {
+			return "", nil, fmt.Errorf("invalid attribute %q", attributes)
+		}
+
+		name := attributes[:equals]
+		attributes = attributes[equals+1:]
+
+		var value string
+		if strings.HasPrefix(attributes, "\"") {
+			end := strings.IndexByte(attributes[1:], '"')
+			if end == -1 {
+				return "", nil, fmt.Errorf("unterminated string in %q", text)
+			}
+
+			value = attributes[1 : end+1]
+			attributes = attributes[end+2:]
+		} else {
+			end := strings.IndexByte(attributes, ',')
+			if end == -1 {
+				end = len(attributes)
+			}
+
+			value = attributes[:end]
+			attributes = attributes[end:]
+		}
+
+		record[name] = value
+		attributes = strings.TrimPrefix(attributes, ",")
+	}
+
+	return fields[0], record, nil
+}
+
+// LineAt returns the source line which generated the code at address, if any.
+func (d *DebugInfo) LineAt(address uint16) (Line, bool) {
+	i := sort.Search(len(d.lines), func(i int) bool {
+		return d.lines[i].Address > address
+	})
+
+	for i--; i >= 0 && int(d.lines[i].Address)+d.maxSize > int(address); i-- {
+		if int(address) < int(d.lines[i].Address)+d.lines[i].Size && d.Mapped(d.lines[i]) {
+			return d.lines[i], true
+		}
+	}
+
+	return Line{}, false
+}
+
+// Mapped returns true if the code generated for line is currently mapped at
+// line.Address. This is always true if PRGOffset isn't set, or the line isn't
+// in PRG ROM.
+func (d *DebugInfo) Mapped(line Line) bool {
+	if d.PRGOffset == nil || line.PRGOffset == -1 {
+		return true
+	}
+
+	return d.PRGOffset(line.Address) == line.PRGOffset
+}
+
+// FindLine returns the code generated for the first line of file at or after
+// line which generated any code, or nil if there is no such line. A line may
+// generate code in several places, e.g. by being included in several banks.
+//
+// file matches a source file name if either is a path suffix of the other, so
+// absolute and relative names of the same file match.
+func (d *DebugInfo) FindLine(file string, line int) []Line {
+	var best int
+
+	for _, l := range d.lines {
+		if l.Line >= line && (best == 0 || l.Line < best) && sameFile(l.File, file) {
+			best = l.Line
+		}
+	}
+
+	var result []Line
+	for _, l := range d.lines {
+		if l.Line == best && sameFile(l.File, file) {
+			result = append(result, l)
+		}
+	}
+
+	return result
+}
+
+// Returns true if a and b name the same file, i.e. either is a path suffix of
+// the other.
+func sameFile(a string, b string) bool {
+	a = filepath.ToSlash(filepath.Clean(a))
+	b = filepath.ToSlash(filepath.Clean(b))
+
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+
+	return a == b || strings.HasSuffix(a, "/"+b)
+}
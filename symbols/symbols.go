@@ -0,0 +1,299 @@
+// Package symbols loads label files produced by NES development toolchains and
+// debuggers, mapping CPU addresses to symbolic names.
+//
+// The following formats are supported:
+// - VICE label files, as written by ld65 -Ln ("al 00C000 .reset")
+// - ca65/ld65 debug info files, as written by ld65 --dbgfile (see DebugInfo)
+// - FCEUX name lists, e.g. "game.nes.0.nl" for PRG bank 0 ("$C000#reset#")
+// - Mesen label files, e.g. "game.mlb" ("P:0010:reset")
+//
+// Labels in PRG ROM are bank-aware where the format allows: they're stored by
+// PRG ROM offset, and only found while their bank is mapped.
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Table maps CPU addresses to labels.
+type Table struct {
+	// PRGOffset maps a CPU address to the PRG ROM offset mapped there, or -1,
+	// e.g. Cartridge.PRGOffset. If set, PRG ROM labels are only found while
+	// their bank is mapped. Otherwise they're found at the CPU address they
+	// were defined at.
+	PRGOffset func(address uint16) int
+
+	// Labels by CPU address.
+	labels map[uint16]string
+
+	// PRG ROM labels by offset, and by the CPU address they were defined at.
+	prg          map[int]string
+	prgAddresses map[uint16]string
+}
+
+// NewTable returns an empty Table.
+func NewTable() *Table {
+	return &Table{
+		labels:       make(map[uint16]string),
+		prg:          make(map[int]string),
+		prgAddresses: make(map[uint16]string),
+	}
+}
+
+// Load reads the label files filenames into a new Table.
+func Load(filenames ...string) (*Table, error) {
+	t := NewTable()
+
+	for _, filename := range filenames {
+		if err := t.Load(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Load reads the label file filename into t. The format is chosen by the file
+// name: *.dbg files are debug info files, *.nl files FCEUX name lists, *.mlb
+// files Mesen label files, and others VICE label files.
+func (t *Table) Load(filename string) error {
+	var ext string = strings.ToLower(filepath.Ext(filename))
+
+	if ext == ".dbg" {
+		d, err := LoadDebugInfo(filename)
+		if err != nil {
+			return err
+		}
+
+		t.merge(d.Labels)
+		return nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch ext {
+	case ".nl":
+		var bank int
+		bank, err = fceuxBank(filename)
+		if err == nil {
+			err = t.ReadFCEUX(file, bank)
+		}
+	case ".mlb":
+		err = t.ReadMesen(file)
+	default:
+		err = t.ReadVICE(file)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return nil
+}
+
+// Add adds a label for address. An existing label for address is kept.
+func (t *Table) Add(address uint16, name string) {
+	if _, ok := t.labels[address]; !ok {
+		t.labels[address] = name
+	}
+}
+
+// AddPRG adds a label for the PRG ROM byte at offset, which is mapped at CPU
+// address in the label file's view of memory. An existing label is kept.
+func (t *Table) AddPRG(offset int, address uint16, name string) {
+	if _, ok := t.prg[offset]; !ok {
+		t.prg[offset] = name
+	}
+
+	if _, ok := t.prgAddresses[address]; !ok {
+		t.prgAddresses[address] = name
+	}
+}
+
+// Adds the labels from other.
+func (t *Table) merge(other *Table) {
+	for address, name := range other.labels {
+		t.Add(address, name)
+	}
+
+	for offset, name := range other.prg {
+		if _, ok := t.prg[offset]; !ok {
+			t.prg[offset] = name
+		}
+	}
+
+	for address, name := range other.prgAddresses {
+		if _, ok := t.prgAddresses[address]; !ok {
+			t.prgAddresses[address] = name
+		}
+	}
+}
+
+// Label returns the label at address, if any.
+func (t *Table) Label(address uint16) (string, bool) {
+	if t.PRGOffset != nil {
+		if offset := t.PRGOffset(address); offset != -1 {
+			if name, ok := t.prg[offset]; ok {
+				return name, true
+			}
+		}
+	} else if name, ok := t.prgAddresses[address]; ok {
+		return name, true
+	}
+
+	name, ok := t.labels[address]
+	return name, ok
+}
+
+// Len returns the number of labels.
+func (t *Table) Len() int {
+	return len(t.labels) + len(t.prg)
+}
+
+// ReadVICE reads a VICE label file, as written by ld65's -Ln option.
+//
+// Each line has the form "al 00C000 .reset". The leading "." of each name is
+// removed. Other VICE monitor commands are ignored. The labels aren't
+// bank-aware.
+func (t *Table) ReadVICE(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "al" {
+			continue
+		}
+
+		address, err := strconv.ParseUint(fields[1], 16, 32)
+		if err != nil || address > 0xFFFF {
+			return fmt.Errorf("line %d: invalid address %q", lineNumber, fields[1])
+		}
+
+		t.Add(uint16(address), strings.TrimPrefix(fields[2], "."))
+	}
+
+	return scanner.Err()
+}
+
+// Returns the bank number in the name of an FCEUX name list file, e.g. 2 for
+// "game.nes.2.nl", or -1 for "game.nes.ram.nl".
+func fceuxBank(filename string) (int, error) {
+	var name string = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	var bank string = strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+
+	if bank == "ram" {
+		return -1, nil
+	}
+
+	n, err := strconv.ParseUint(bank, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("no bank number in file name (expected e.g. game.nes.0.nl or game.nes.ram.nl)")
+	}
+
+	return int(n), nil
+}
+
+// ReadFCEUX reads an FCEUX name list file for the 16k PRG bank bank, or for RAM
+// if bank is -1.
+//
+// Each line has the form "$C000#reset#comment", or "$0300/10#buffer#" for an
+// array. Lines starting with "\" continue the previous comment.
+func (t *Table) ReadFCEUX(r io.Reader, bank int) error {
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line string = strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "$") {
+			continue
+		}
+
+		fields := strings.SplitN(line[1:], "#", 3)
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: expected $ADDRESS#NAME#", lineNumber)
+		}
+
+		// The array size, if any, is ignored.
+		var addressText string = strings.SplitN(fields[0], "/", 2)[0]
+
+		address, err := strconv.ParseUint(addressText, 16, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address %q", lineNumber, fields[0])
+		}
+
+		if fields[1] == "" {
+			continue
+		}
+
+		if bank == -1 || address < 0x8000 {
+			t.Add(uint16(address), fields[1])
+		} else {
+			t.AddPRG(bank*0x4000+int(address&0x3FFF), uint16(address), fields[1])
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ReadMesen reads a Mesen label file.
+//
+// Each line has the form "TYPE:ADDRESS[-END]:NAME[:COMMENT]", e.g.
+// "P:0010:reset". TYPE is one of P (PRG ROM offset), R (internal RAM), S (save
+// RAM), W (work RAM) or G (CPU address, e.g. registers), or the Mesen 2
+// equivalents NesPrgRom, NesInternalRam, NesSaveRam, NesWorkRam and
+// NesMemory. Other types, and comments without names, are ignored.
+//
+// PRG ROM labels are assumed to be mapped at $8000-$FFFF when no PRGOffset is
+// set.
+func (t *Table) ReadMesen(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line string = strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 4)
+		if len(fields) < 3 {
+			return fmt.Errorf("line %d: expected TYPE:ADDRESS:NAME", lineNumber)
+		}
+
+		var name string = fields[2]
+		if name == "" {
+			continue
+		}
+
+		// The end of a range, if any, is ignored.
+		var addressText string = strings.SplitN(fields[1], "-", 2)[0]
+
+		address, err := strconv.ParseUint(addressText, 16, 32)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address %q", lineNumber, fields[1])
+		}
+
+		switch fields[0] {
+		case "P", "NesPrgRom":
+			t.AddPRG(int(address), uint16(0x8000|address&0x7FFF), name)
+		case "R", "NesInternalRam":
+			t.Add(uint16(address&0x7FF), name)
+		case "S", "NesSaveRam", "W", "NesWorkRam":
+			t.Add(uint16(0x6000+address&0x1FFF), name)
+		case "G", "NesMemory":
+			if address <= 0xFFFF {
+				t.Add(uint16(address), name)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,189 @@
+package symbols
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadVICE(t *testing.T) {
+	input := `al 00C000 .reset
+al 00C010 .nmi
+al 000010 .ptr
+break C000
+`
+
+	table := NewTable()
+	if err := table.ReadVICE(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[uint16]string{
+		0xC000: "reset",
+		0xC010: "nmi",
+		0x0010: "ptr",
+	}
+
+	if table.Len() != len(expected) {
+		t.Fatalf("Len()=%d, expected %d\n", table.Len(), len(expected))
+	}
+
+	for address, name := range expected {
+		if actual, ok := table.Label(address); !ok || actual != name {
+			t.Errorf("Label(%04X)=%q, expected %q\n", address, actual, name)
+		}
+	}
+}
+
+// Debug info for a program at $8000:
+//
+//	reset:  LDX #$00   ; line 3
+//	loop:   INX        ; line 4
+//	        STX PTR    ; line 5
+//	        JMP loop   ; line 6
+const testDebugInfo = `version	major=2,minor=0
+info	csym=0,file=1,lib=0,line=5,mod=1,scope=1,seg=1,span=4,sym=3,type=1
+file	id=0,name="src/main.s",size=120,mtime=0x5F5E1000,mod=0
+line	id=0,file=0,line=3,span=0
+line	id=1,file=0,line=4,span=1
+line	id=2,file=0,line=5,span=2
+line	id=3,file=0,line=6,span=3
+line	id=4,file=0,line=1
+mod	id=0,name="main.o",file=0
+seg	id=0,name="CODE",start=0x008000,size=0x0008,addrsize=absolute,type=ro,oname="main.nes",ooffs=16
+span	id=0,seg=0,start=0,size=2
+span	id=1,seg=0,start=2,size=1
+span	id=2,seg=0,start=3,size=2
+span	id=3,seg=0,start=5,size=3
+sym	id=0,name="reset",addrsize=absolute,size=2,scope=0,def=0,val=0x8000,seg=0,type=lab
+sym	id=1,name="loop",addrsize=absolute,scope=0,def=1,ref=3,val=0x8002,seg=0,type=lab
+sym	id=2,name="PTR",addrsize=zeropage,scope=0,def=2,ref=2,val=0x10,type=equ
+`
+
+func TestReadDebugInfo(t *testing.T) {
+	d, err := ReadDebugInfo(strings.NewReader(testDebugInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Files) != 1 || d.Files[0] != "src/main.s" {
+		t.Fatalf("Files=%q, expected [src/main.s]\n", d.Files)
+	}
+
+	if name, _ := d.Labels.Label(0x8002); name != "loop" || d.Labels.Len() != 2 {
+		t.Fatalf("Label(8002)=%q Len()=%d, expected loop and 2\n", name, d.Labels.Len())
+	}
+
+	for address, expected := range map[uint16]int{0x8000: 3, 0x8001: 3, 0x8002: 4, 0x8004: 5, 0x8007: 6} {
+		line, ok := d.LineAt(address)
+		if !ok || line.Line != expected || line.File != "src/main.s" {
+			t.Errorf("LineAt(%04X)=%v, expected line %d\n", address, line, expected)
+		}
+	}
+
+	if line, ok := d.LineAt(0x8008); ok {
+		t.Errorf("LineAt(8008)=%v, expected no line\n", line)
+	}
+
+	if lines := d.FindLine("/home/user/game/src/main.s", 1); len(lines) != 1 ||
+		lines[0].Line != 3 || lines[0].Address != 0x8000 || lines[0].PRGOffset != 0 {
+		t.Errorf("FindLine(1)=%v, expected line 3 at $8000, PRG offset 0\n", lines)
+	}
+
+	if lines := d.FindLine("other.s", 3); lines != nil {
+		t.Errorf("FindLine(other.s)=%v, expected no line\n", lines)
+	}
+
+	// Map a different bank at $8000.
+	d.PRGOffset = func(address uint16) int {
+		return 0x4000 + int(address&0x3FFF)
+	}
+	d.Labels.PRGOffset = d.PRGOffset
+
+	if line, ok := d.LineAt(0x8002); ok {
+		t.Errorf("LineAt(8002)=%v with bank 1 mapped, expected no line\n", line)
+	}
+
+	if name, ok := d.Labels.Label(0x8002); ok {
+		t.Errorf("Label(8002)=%q with bank 1 mapped, expected no label\n", name)
+	}
+}
+
+func TestReadFCEUX(t *testing.T) {
+	input := `$C000#reset#Entry point
+\continued comment
+$8010#bank2_sub#
+$0300/10#buffer#
+`
+
+	table := NewTable()
+	if err := table.ReadFCEUX(strings.NewReader(input), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a mapping, labels are found at their CPU addresses.
+	for address, expected := range map[uint16]string{0xC000: "reset", 0x8010: "bank2_sub", 0x0300: "buffer"} {
+		if name, _ := table.Label(address); name != expected {
+			t.Errorf("Label(%04X)=%q, expected %q\n", address, name, expected)
+		}
+	}
+
+	// Bank 2 mapped at $8000, bank 3 at $C000.
+	table.PRGOffset = func(address uint16) int {
+		return 2*0x4000 + int(address-0x8000)
+	}
+
+	if name, _ := table.Label(0x8010); name != "bank2_sub" {
+		t.Errorf("Label(8010)=%q, expected bank2_sub\n", name)
+	}
+
+	if name, ok := table.Label(0xC000); ok {
+		t.Errorf("Label(C000)=%q, expected no label in bank 3\n", name)
+	}
+
+	if bank, err := fceuxBank("dir/game.nes.1f.nl"); bank != 0x1F || err != nil {
+		t.Errorf("fceuxBank()=%d, %v, expected 31\n", bank, err)
+	}
+
+	if bank, err := fceuxBank("game.nes.ram.nl"); bank != -1 || err != nil {
+		t.Errorf("fceuxBank()=%d, %v, expected -1\n", bank, err)
+	}
+}
+
+func TestReadMesen(t *testing.T) {
+	input := `P:4010:NMI_Handler:comment: with colons
+R:0010-0011:ptr
+NesSaveRam:0004:save_slot
+G:2000:PPUCTRL
+P:4020::comment only
+`
+
+	table := NewTable()
+	if err := table.ReadMesen(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	table.PRGOffset = func(address uint16) int {
+		if address < 0x8000 {
+			return -1
+		}
+
+		return 0x4000 + int(address&0x3FFF)
+	}
+
+	expected := map[uint16]string{
+		0x8010: "NMI_Handler",
+		0x0010: "ptr",
+		0x6004: "save_slot",
+		0x2000: "PPUCTRL",
+	}
+
+	if table.Len() != len(expected) {
+		t.Fatalf("Len()=%d, expected %d\n", table.Len(), len(expected))
+	}
+
+	for address, name := range expected {
+		if actual, ok := table.Label(address); !ok || actual != name {
+			t.Errorf("Label(%04X)=%q, expected %q\n", address, actual, name)
+		}
+	}
+}